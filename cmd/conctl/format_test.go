@@ -379,3 +379,15 @@ func TestFilterResponses(t *testing.T) {
 		})
 	}
 }
+
+func TestLineDiff(t *testing.T) {
+	if got := LineDiff("a\nb\n", "a\nb\n"); got != "" {
+		t.Errorf("identical input: got %q, want empty", got)
+	}
+
+	got := LineDiff("a\nb\nc\n", "a\nc\nd\n")
+	want := "  a\n- b\n  c\n+ d\n"
+	if got != want {
+		t.Errorf("LineDiff =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ConspiracyOS/agent-runner/internal/alert"
+	"github.com/ConspiracyOS/agent-runner/internal/config"
+	"github.com/ConspiracyOS/agent-runner/internal/contracts"
+	"github.com/ConspiracyOS/agent-runner/internal/redact"
+)
+
+func healthcheckUsage() {
+	fmt.Fprintln(os.Stderr, "usage: con healthcheck [flags]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Flags:")
+	fmt.Fprintln(os.Stderr, "  --contract <id>   Run only this contract, whatever its frequency (repeatable)")
+	fmt.Fprintln(os.Stderr, "  --scope <scope>   Run only contracts with this scope (system, agent:<name>)")
+	fmt.Fprintln(os.Stderr, "  --dry-run         Evaluate without dispatching actions, alerting or saving state")
+	fmt.Fprintln(os.Stderr, "  --json            Print the run (or --list) as JSON")
+	fmt.Fprintln(os.Stderr, "  --list            List detective and preventive contracts without running them")
+	os.Exit(1)
+}
+
+// healthcheckOptions are the parsed `con healthcheck` flags.
+type healthcheckOptions struct {
+	contracts []string
+	scope     string
+	dryRun    bool
+	json      bool
+	list      bool
+}
+
+// selective reports whether the run is restricted to chosen contracts,
+// which then run regardless of their frequency.
+func (o healthcheckOptions) selective() bool {
+	return len(o.contracts) > 0 || o.scope != ""
+}
+
+func parseHealthcheckArgs(args []string) (healthcheckOptions, error) {
+	var o healthcheckOptions
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--contract", "--scope":
+			if i+1 >= len(args) || args[i+1] == "" {
+				return o, fmt.Errorf("%s requires a value", args[i])
+			}
+			if args[i] == "--contract" {
+				o.contracts = append(o.contracts, args[i+1])
+			} else {
+				o.scope = args[i+1]
+			}
+			i++
+		case "--dry-run":
+			o.dryRun = true
+		case "--json":
+			o.json = true
+		case "--list":
+			o.list = true
+		case "-h", "--help":
+			healthcheckUsage()
+		default:
+			return o, fmt.Errorf("unknown flag %q", args[i])
+		}
+	}
+	return o, nil
+}
+
+// selectContracts applies --contract and --scope. A per-agent contract is
+// selected by its own ID ("CON-X@researcher") or its template's ("CON-X"),
+// and --scope agent:* selects every agent-scoped contract. Naming a
+// contract that is not loaded is an error, so a typo does not silently
+// run nothing.
+func selectContracts(all []contracts.Contract, o healthcheckOptions) ([]contracts.Contract, error) {
+	ids := map[string]bool{}
+	for _, id := range o.contracts {
+		ids[id] = false
+	}
+	var selected []contracts.Contract
+	for _, c := range all {
+		id := c.ID
+		if _, ok := ids[id]; !ok {
+			id = c.BaseID()
+		}
+		if _, ok := ids[id]; len(o.contracts) > 0 && !ok {
+			continue
+		}
+		ids[id] = true
+		if o.scope != "" && c.Scope != o.scope && (o.scope != "agent:*" || !strings.HasPrefix(c.Scope, "agent:")) {
+			continue
+		}
+		selected = append(selected, c)
+	}
+	for _, id := range o.contracts {
+		if !ids[id] {
+			return nil, fmt.Errorf("unknown contract %q", id)
+		}
+	}
+	return selected, nil
+}
+
+// contractEntry is one contract in `con healthcheck --list`.
+type contractEntry struct {
+	ID          string   `json:"id"`
+	Type        string   `json:"type"`
+	Scope       string   `json:"scope"`
+	Description string   `json:"description,omitempty"`
+	Frequency   string   `json:"frequency,omitempty"`
+	Checks      []string `json:"checks,omitempty"`
+	Mechanism   string   `json:"mechanism,omitempty"`
+	Agent       string   `json:"agent,omitempty"`
+	Enforcement string   `json:"enforcement,omitempty"`
+}
+
+func registry(cs []contracts.Contract) []contractEntry {
+	var entries []contractEntry
+	for _, c := range cs {
+		e := contractEntry{ID: c.ID, Type: c.Type, Scope: c.Scope, Description: c.Description,
+			Frequency: c.Frequency, Mechanism: c.Mechanism, Agent: c.Agent, Enforcement: c.Enforcement}
+		if e.Type == "" {
+			e.Type = "detective"
+		}
+		for _, ch := range c.Checks {
+			e.Checks = append(e.Checks, ch.Name)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// writeRegistry prints one line per contract: detective contracts show
+// their frequency and checks, preventive ones their mechanism and the
+// assertions that verify it.
+func writeRegistry(w io.Writer, entries []contractEntry) {
+	for _, e := range entries {
+		how := e.Frequency
+		if how == "" {
+			how = "every run"
+		}
+		if e.Type == "preventive" {
+			how = e.Mechanism
+			if len(e.Checks) == 0 {
+				how += " (registry only)"
+			}
+		}
+		if len(e.Checks) > 0 {
+			how += " " + strings.Join(e.Checks, ",")
+		}
+		fmt.Fprintf(w, "%-14s %-10s %-18s %-32s %s\n", e.ID, e.Type, e.Scope, how, e.Description)
+	}
+}
+
+// healthcheckReport is the --json form of a run.
+type healthcheckReport struct {
+	Timestamp  time.Time     `json:"timestamp"`
+	DryRun     bool          `json:"dry_run,omitempty"`
+	Passed     int           `json:"passed"`
+	Failed     int           `json:"failed"`
+	Skipped    int           `json:"skipped"`
+	NotDue     int           `json:"not_due"`
+	Blocked    int           `json:"blocked"`
+	Enforced   int           `json:"enforced"`
+	Drifted    int           `json:"drifted"`
+	Results    []checkReport `json:"results"`
+	Escalation string        `json:"escalation,omitempty"` // outcome of the sysadmin summary
+}
+
+type checkReport struct {
+	Contract   string   `json:"contract"`
+	Check      string   `json:"check"`
+	Status     string   `json:"status"` // pass | fail | enforced | drifted | blocked
+	Passed     bool     `json:"passed"`
+	Output     string   `json:"output,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	DurationMS int64    `json:"duration_ms"`
+	Transition string   `json:"transition,omitempty"`
+	BlockedBy  []string `json:"blocked_by,omitempty"`
+	Actions    []string `json:"actions,omitempty"`
+}
+
+func newReport(result contracts.RunResult, dryRun bool) *healthcheckReport {
+	r := &healthcheckReport{Timestamp: result.Timestamp, DryRun: dryRun, Passed: result.Passed,
+		Failed: result.Failed, Skipped: result.Skipped, NotDue: result.NotDue,
+		Enforced: result.Enforced, Drifted: result.Drifted, Blocked: result.Blocked, Results: []checkReport{}}
+	for _, cr := range result.Results {
+		c := checkReport{Contract: cr.ContractID, Check: cr.CheckName, Status: strings.ToLower(cr.Status()), Passed: cr.Passed,
+			Output: cr.Output, DurationMS: cr.Duration.Milliseconds(), Transition: string(cr.Transition), BlockedBy: cr.BlockedBy}
+		if cr.Error != nil {
+			c.Error = cr.Error.Error()
+		}
+		r.Results = append(r.Results, c)
+	}
+	return r
+}
+
+// plannedAction describes what a transition would dispatch, for --dry-run.
+func plannedAction(ch contracts.Check, t contracts.Transition) string {
+	action, escalate := ch.OnFail.Action, ch.OnFail.Escalate
+	kind := "on_fail"
+	if t == contracts.TransitionRecovered {
+		action, escalate = ch.OnRecover.Action, ch.OnRecover.Escalate
+		kind = "on_recover"
+	}
+	if action == "" {
+		action = "none"
+	}
+	s := fmt.Sprintf("would run %s: %s", kind, action)
+	if escalate != "" {
+		s += ", escalate to " + escalate
+	}
+	return s
+}
+
+func runHealthcheck(args []string) {
+	opts, err := parseHealthcheckArgs(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "healthcheck: %v\n", err)
+		healthcheckUsage()
+	}
+
+	contractsDir := "/srv/con/contracts"
+	if env := os.Getenv("CON_CONTRACTS_DIR"); env != "" {
+		contractsDir = env
+	}
+	logPath := "/srv/con/logs/audit/contracts.log"
+
+	// Contracts reference con.toml thresholds as ${contracts.system.*}
+	cfg := loadConfig()
+	loaded, err := contracts.LoadDir(contractsDir, cfg.ContractVars())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "healthcheck: loading contracts: %v\n", err)
+		os.Exit(1)
+	}
+
+	if opts.list {
+		// The registry shows agent:* and tier:<tier> contracts unexpanded
+		listed, err := selectContracts(loaded, opts)
+		if err != nil {
+			fail("healthcheck: %v", err)
+		}
+		entries := registry(listed)
+		if opts.json {
+			if entries == nil {
+				entries = []contractEntry{}
+			}
+			writeJSON(entries)
+			return
+		}
+		writeRegistry(os.Stdout, entries)
+		return
+	}
+
+	// One instance of each agent:* and tier:<tier> contract per agent
+	agents := contractAgents(cfg)
+	allContracts := contracts.Expand(loaded, agents)
+	selected, err := selectContracts(allContracts, opts)
+	if err != nil {
+		fail("healthcheck: %v", err)
+	}
+
+	if len(selected) == 0 {
+		if opts.json {
+			writeJSON(&healthcheckReport{Timestamp: time.Now(), DryRun: opts.dryRun, Results: []checkReport{}})
+			return
+		}
+		fmt.Println("healthcheck: no contracts found")
+		return
+	}
+
+	// Backstop for the whole run; each check also has its own timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	// Only run contracts whose frequency has elapsed since their last run,
+	// unless they were picked explicitly
+	state, err := contracts.LoadState(contracts.DefaultStatePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "healthcheck: %v (running all contracts)\n", err)
+	}
+	state.Prune(allContracts)
+	var result contracts.RunResult
+	if opts.selective() {
+		result = contracts.EvaluateNow(ctx, selected, contractsDir, &contracts.DefaultExecutor{}, state)
+	} else {
+		result = contracts.EvaluateDue(ctx, selected, contractsDir, &contracts.DefaultExecutor{}, state)
+	}
+
+	// A dry run leaves no trace: no audit log, actions, alerts or state
+	if !opts.dryRun {
+		if f, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err == nil {
+			contracts.WriteLog(result, f)
+			f.Close()
+		}
+	}
+
+	// Also write to stdout (for journalctl)
+	report := newReport(result, opts.dryRun)
+	if !opts.json {
+		contracts.WriteLog(result, os.Stdout)
+	}
+
+	// Dispatch on_fail when a check newly enters the failing state and
+	// on_recover when it passes again; a check that stays failing (or is
+	// below fail_after) is quiet. The dispatcher records what it halted in
+	// state so recovery restarts only those units.
+	cooldown, _ := time.ParseDuration(cfg.Contracts.System.EscalationCooldown) // validated by config
+	dispatcher := &contracts.Dispatcher{Executor: &contracts.DefaultExecutor{}, State: state, Agents: agents, EscalationCooldown: cooldown}
+	// Alert sinks reach humans even when every agent is halted
+	var notifier *alert.Notifier
+	if !opts.dryRun {
+		if notifier, err = alert.New(cfg.Alerts, alert.DefaultStatePath); err != nil {
+			fmt.Fprintf(os.Stderr, "healthcheck: alert sinks: %v\n", err)
+		} else {
+			notifier.Redactor = secretsRedactor(cfg)
+		}
+	}
+	for i, cr := range result.Results {
+		if cr.Transition == "" {
+			continue
+		}
+		for _, c := range selected {
+			for _, ch := range c.Checks {
+				if c.ID != cr.ContractID || ch.Name != cr.CheckName {
+					continue
+				}
+				var cmds []string
+				var err error
+				message := ch.OnFail.Message
+				if cr.Transition == contracts.TransitionRecovered {
+					message = ch.OnRecover.Message
+				}
+				switch {
+				case opts.dryRun:
+					cmds = []string{plannedAction(ch, cr.Transition)}
+				case cr.Transition == contracts.TransitionFailed:
+					cmds, err = dispatcher.Fail(ctx, c.ID, ch, c.Scope)
+				default:
+					cmds, err = dispatcher.Recover(ctx, c.ID, ch, c.Scope)
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "healthcheck: action dispatch for %s: %v (retrying next run)\n", c.ID, err)
+					state.Retry(c.ID, ch.Name, cr.Transition)
+				}
+				report.Results[i].Actions = append(report.Results[i].Actions, cmds...)
+				if !opts.json {
+					for _, cmd := range cmds {
+						fmt.Printf("  ACTION: %s\n", cmd)
+					}
+				}
+				if notifier != nil {
+					a := alert.Alert{System: cfg.System.Name, ContractID: c.ID, CheckName: ch.Name,
+						Status: string(cr.Transition), Message: message, Output: cr.Output, Time: result.Timestamp}
+					if err := notifier.Notify(ctx, a); err != nil {
+						fmt.Fprintf(os.Stderr, "healthcheck: %v\n", err)
+					}
+				}
+			}
+		}
+	}
+	if notifier != nil {
+		if err := notifier.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "healthcheck: saving alert state: %v\n", err)
+		}
+	}
+
+	// Meta-escalation: one summary task to sysadmin per distinct set of
+	// failing checks. While the same set keeps failing the pending task is
+	// updated with an occurrence count, and once taken it is repeated only
+	// after the cooldown.
+	if failing := state.FailingChecks(allContracts); len(failing) > 0 && !opts.dryRun {
+		msg := fmt.Sprintf("Healthcheck: %d contract check(s) failing: %s. Review audit log and fix.", len(failing), strings.Join(failing, ", "))
+		outcome, err := state.Escalate("sysadmin", "healthcheck:"+strings.Join(failing, ","), msg, cooldown, result.Timestamp)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "healthcheck: escalation failed: %v\n", err)
+		} else {
+			report.Escalation = string(outcome)
+			if outcome != contracts.EscalationSent && !opts.json {
+				fmt.Printf("  ESCALATION: %s (%s)\n", strings.Join(failing, ", "), outcome)
+			}
+		}
+	}
+	if !opts.dryRun {
+		if err := state.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "healthcheck: saving state: %v\n", err)
+		}
+	}
+
+	if opts.json {
+		writeJSON(report)
+	}
+	if result.Failed > 0 || result.Drifted > 0 {
+		os.Exit(1)
+	}
+}
+
+func writeJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fail("healthcheck: %v", err)
+	}
+}
+
+// contractAgents describes the configured agents for tier-aware halting.
+func contractAgents(cfg *config.Config) []contracts.AgentInfo {
+	var agents []contracts.AgentInfo
+	for _, a := range cfg.Agents {
+		r := cfg.ResolvedAgent(a.Name)
+		agents = append(agents, contracts.AgentInfo{Name: r.Name, Tier: r.Tier, Mode: r.Mode})
+	}
+	return agents
+}
+
+// secretsRedactor masks the secret values of every agent: check output can
+// show any of them, and alerts leave the box.
+func secretsRedactor(cfg *config.Config) *redact.Redactor {
+	values := map[string]string{}
+	for _, a := range cfg.Agents {
+		for _, name := range cfg.AgentSecrets(a.Name) {
+			if v := config.LookupSecret(a.Name, name); v != "" {
+				values[name] = v
+			}
+		}
+	}
+	return redact.New(values)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ConspiracyOS/agent-runner/internal/contracts"
+)
+
+func TestParseHealthcheckArgs(t *testing.T) {
+	o, err := parseHealthcheckArgs([]string{"--contract", "CON-SYS-001", "--contract", "CON-SYS-002", "--scope", "system", "--dry-run", "--json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o.contracts) != 2 || o.scope != "system" || !o.dryRun || !o.json || o.list {
+		t.Errorf("options = %+v", o)
+	}
+	if !o.selective() {
+		t.Error("--contract should make the run selective")
+	}
+
+	for _, bad := range [][]string{{"--contract"}, {"--scope", ""}, {"--verbose"}} {
+		if _, err := parseHealthcheckArgs(bad); err == nil {
+			t.Errorf("parseHealthcheckArgs(%q) should fail", bad)
+		}
+	}
+}
+
+func TestSelectContracts(t *testing.T) {
+	all := []contracts.Contract{
+		{ID: "CON-SYS-001", Scope: "system"},
+		{ID: "CON-AGENT-004", Scope: "agent:researcher"},
+		{ID: "CON-AGENT-005", Scope: "agent:concierge"},
+		{ID: "CON-AGENT-002@concierge", Scope: "agent:concierge"},
+		{ID: "CON-AGENT-002@researcher", Scope: "agent:researcher"},
+	}
+	ids := func(cs []contracts.Contract) string {
+		var s []string
+		for _, c := range cs {
+			s = append(s, c.ID)
+		}
+		return strings.Join(s, ",")
+	}
+
+	got, _ := selectContracts(all, healthcheckOptions{})
+	if ids(got) != "CON-SYS-001,CON-AGENT-004,CON-AGENT-005,CON-AGENT-002@concierge,CON-AGENT-002@researcher" {
+		t.Errorf("no filter = %s", ids(got))
+	}
+	got, _ = selectContracts(all, healthcheckOptions{scope: "agent:researcher"})
+	if ids(got) != "CON-AGENT-004,CON-AGENT-002@researcher" {
+		t.Errorf("--scope = %s", ids(got))
+	}
+	got, _ = selectContracts(all, healthcheckOptions{scope: "agent:*"})
+	if ids(got) != "CON-AGENT-004,CON-AGENT-005,CON-AGENT-002@concierge,CON-AGENT-002@researcher" {
+		t.Errorf("--scope agent:* = %s", ids(got))
+	}
+	got, _ = selectContracts(all, healthcheckOptions{contracts: []string{"CON-AGENT-002"}})
+	if ids(got) != "CON-AGENT-002@concierge,CON-AGENT-002@researcher" {
+		t.Errorf("--contract of a template = %s", ids(got))
+	}
+	got, _ = selectContracts(all, healthcheckOptions{contracts: []string{"CON-AGENT-002@researcher"}})
+	if ids(got) != "CON-AGENT-002@researcher" {
+		t.Errorf("--contract of an instance = %s", ids(got))
+	}
+	got, _ = selectContracts(all, healthcheckOptions{contracts: []string{"CON-SYS-001", "CON-AGENT-005"}})
+	if ids(got) != "CON-SYS-001,CON-AGENT-005" {
+		t.Errorf("--contract = %s", ids(got))
+	}
+	if _, err := selectContracts(all, healthcheckOptions{contracts: []string{"CON-NOPE-001"}}); err == nil {
+		t.Error("an unknown contract should be an error")
+	}
+}
+
+func TestWriteRegistry(t *testing.T) {
+	entries := registry([]contracts.Contract{
+		{ID: "CON-SYS-001", Type: "detective", Scope: "system", Frequency: "60s", Description: "Disk free",
+			Checks: []contracts.Check{{Name: "disk_free"}}},
+		{ID: "CON-NET-001", Type: "preventive", Scope: "agent:researcher", Mechanism: "nftables", Description: "No outbound SSH"},
+		{ID: "CON-OLD-001", Scope: "system"},
+	})
+	var buf bytes.Buffer
+	writeRegistry(&buf, entries)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("lines = %q", lines)
+	}
+	if !strings.Contains(lines[0], "60s disk_free") || !strings.Contains(lines[0], "Disk free") {
+		t.Errorf("detective line = %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "preventive") || !strings.Contains(lines[1], "nftables") {
+		t.Errorf("preventive line = %q", lines[1])
+	}
+	if !strings.Contains(lines[2], "detective") || !strings.Contains(lines[2], "every run") {
+		t.Errorf("untyped contract should list as detective: %q", lines[2])
+	}
+}
+
+func TestHealthcheckReportJSON(t *testing.T) {
+	result := contracts.RunResult{
+		Timestamp: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Passed:    1,
+		Failed:    1,
+		Results: []contracts.CheckResult{
+			{ContractID: "CON-SYS-001", CheckName: "disk_free", Passed: true, Duration: 3 * time.Millisecond},
+			{ContractID: "CON-SYS-002", CheckName: "mem_free", Output: "5% free", Error: errors.New("below threshold"),
+				Transition: contracts.TransitionFailed},
+		},
+	}
+	report := newReport(result, true)
+	report.Results[1].Actions = []string{plannedAction(contracts.Check{
+		OnFail: contracts.FailAction{Action: "halt_workers", Escalate: "sysadmin"},
+	}, contracts.TransitionFailed)}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	json.Unmarshal(data, &decoded)
+	if decoded["dry_run"] != true || decoded["failed"] != float64(1) {
+		t.Errorf("report = %s", data)
+	}
+	results := decoded["results"].([]any)
+	failed := results[1].(map[string]any)
+	if failed["error"] != "below threshold" || failed["transition"] != "failed" {
+		t.Errorf("failed result = %v", failed)
+	}
+	if actions := failed["actions"].([]any); actions[0] != "would run on_fail: halt_workers, escalate to sysadmin" {
+		t.Errorf("actions = %v", actions)
+	}
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,9 +9,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ConspiracyOS/agent-runner/internal/assembler"
 	"github.com/ConspiracyOS/agent-runner/internal/bootstrap"
 	"github.com/ConspiracyOS/agent-runner/internal/config"
-	"github.com/ConspiracyOS/agent-runner/internal/contracts"
 	"github.com/ConspiracyOS/agent-runner/internal/runner"
 )
 
@@ -22,9 +21,13 @@ func main() {
 		fmt.Fprintln(os.Stderr, "")
 		fmt.Fprintln(os.Stderr, "Commands:")
 		fmt.Fprintln(os.Stderr, "  bootstrap      Provision the conspiracy")
+		fmt.Fprintln(os.Stderr, "  scope-env      Split /etc/con/env into per-agent env files")
+		fmt.Fprintln(os.Stderr, "  secret <cmd>    Manage the encrypted secret store")
 		fmt.Fprintln(os.Stderr, "  run <agent>     Run an agent task cycle")
+		fmt.Fprintln(os.Stderr, "  assemble <agent> [--explain|--diff]  Show an agent's assembled AGENTS.md")
 		fmt.Fprintln(os.Stderr, "  route-inbox     Move outer inbox to concierge")
-		fmt.Fprintln(os.Stderr, "  healthcheck     Evaluate contracts")
+		fmt.Fprintln(os.Stderr, "  healthcheck [flags]  Evaluate contracts (--help for flags)")
+		fmt.Fprintln(os.Stderr, "  quarantine <cmd> List or release quarantined agents")
 		fmt.Fprintln(os.Stderr, "  task <message>  Drop a task into the outer inbox")
 		fmt.Fprintln(os.Stderr, "  status          Show agent status")
 		fmt.Fprintln(os.Stderr, "  logs            Show recent audit log entries")
@@ -35,16 +38,24 @@ func main() {
 	switch os.Args[1] {
 	case "bootstrap":
 		runBootstrap()
+	case "scope-env":
+		scopeEnv(loadConfig())
+	case "secret":
+		runSecret(os.Args[2:])
 	case "run":
 		if len(os.Args) < 3 {
 			fmt.Fprintln(os.Stderr, "usage: con run <agent-name>")
 			os.Exit(1)
 		}
 		runAgent(os.Args[2])
+	case "assemble":
+		runAssemble(os.Args[2:])
 	case "route-inbox":
 		routeInbox()
 	case "healthcheck":
-		runHealthcheck()
+		runHealthcheck(os.Args[2:])
+	case "quarantine":
+		runQuarantine(os.Args[2:])
 	case "task":
 		if len(os.Args) < 3 {
 			fmt.Fprintln(os.Stderr, "usage: con task <message>")
@@ -90,6 +101,9 @@ func runBootstrap() {
 		}
 	}
 
+	// Split secrets into per-agent env files before units reference them
+	scopeEnv(cfg)
+
 	// Write systemd units
 	for _, a := range cfg.Agents {
 		resolved := cfg.ResolvedAgent(a.Name)
@@ -125,8 +139,7 @@ func runBootstrap() {
 
 	// Assemble AGENTS.md for each agent — root-owned, read-only (Linux enforces integrity)
 	for _, a := range cfg.Agents {
-		resolved := cfg.ResolvedAgent(a.Name)
-		if err := runner.AssembleAgentsMD(resolved); err != nil {
+		if err := runner.AssembleAgentsMD(cfg, a.Name); err != nil {
 			fmt.Fprintf(os.Stderr, "warning: AGENTS.md assembly for %s: %v\n", a.Name, err)
 			continue
 		}
@@ -135,11 +148,10 @@ func runBootstrap() {
 		exec.Command("chmod", "0444", homeDir+"/AGENTS.md").Run()
 	}
 
-	// Deploy skills to each agent's workspace/skills/
+	// Deploy skills to each agent's workspace/skills/, root-owned and read-only,
+	// with a hash manifest in /srv/con/skills/ that the runner verifies
 	for _, a := range cfg.Agents {
-		user := "a-" + a.Name
 		skillsDir := fmt.Sprintf("/srv/con/agents/%s/workspace/skills", a.Name)
-		os.MkdirAll(skillsDir, 0755)
 
 		// Collect skills from roles and agent-specific dirs
 		// Outer config: /etc/con/roles/<role>/skills/, /etc/con/agents/<name>/skills/
@@ -149,104 +161,34 @@ func runBootstrap() {
 		}
 		sources = append(sources, fmt.Sprintf("/etc/con/agents/%s/skills", a.Name))
 
-		for _, src := range sources {
-			entries, err := os.ReadDir(src)
-			if err != nil {
-				continue // dir doesn't exist, skip
-			}
-			for _, e := range entries {
-				if e.IsDir() {
-					continue
-				}
-				data, err := os.ReadFile(filepath.Join(src, e.Name()))
-				if err != nil {
-					continue
-				}
-				dst := filepath.Join(skillsDir, e.Name())
-				os.WriteFile(dst, data, 0644)
-				fmt.Printf("+ skill %s -> %s\n", e.Name(), dst)
-			}
+		names, err := runner.DeploySkills(sources, skillsDir, runner.SkillManifestPath(a.Name))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: deploying skills for %s: %v\n", a.Name, err)
+			continue
 		}
-
-		// Fix ownership
-		exec.Command("chown", "-R", user+":agents", skillsDir).Run()
+		for _, name := range names {
+			fmt.Printf("+ skill %s -> %s\n", name, filepath.Join(skillsDir, name))
+		}
+		exec.Command("chown", "-R", "root:root", skillsDir).Run()
 	}
 
 	fmt.Println("bootstrap complete")
 }
 
-func routeInbox() {
-	if err := runner.MoveOuterInboxTasks(); err != nil {
-		fmt.Fprintf(os.Stderr, "route-inbox failed: %v\n", err)
+// scopeEnv writes /etc/con/env.d/<agent> for every agent, containing only the
+// secrets that agent is granted. Runs at bootstrap and on every boot after
+// con-export-env refreshes /etc/con/env.
+func scopeEnv(cfg *config.Config) {
+	fmt.Printf("+ scope %s -> %s/\n", "/etc/con/env", config.ScopedEnvDir)
+	if err := bootstrap.WriteScopedEnv(cfg, "/etc/con/env", config.ScopedEnvDir); err != nil {
+		fmt.Fprintf(os.Stderr, "scope-env: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func runHealthcheck() {
-	contractsDir := "/srv/con/contracts"
-	if env := os.Getenv("CON_CONTRACTS_DIR"); env != "" {
-		contractsDir = env
-	}
-	logPath := "/srv/con/logs/audit/contracts.log"
-
-	allContracts, err := contracts.LoadDir(contractsDir)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "healthcheck: loading contracts: %v\n", err)
-		os.Exit(1)
-	}
-
-	if len(allContracts) == 0 {
-		fmt.Println("healthcheck: no contracts found")
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	result := contracts.Evaluate(ctx, allContracts, contractsDir, &contracts.DefaultExecutor{})
-
-	// Write to log file
-	if f, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err == nil {
-		contracts.WriteLog(result, f)
-		f.Close()
-	}
-
-	// Also write to stdout (for journalctl)
-	contracts.WriteLog(result, os.Stdout)
-
-	// Dispatch failure actions
-	for _, cr := range result.Results {
-		if cr.Passed {
-			continue
-		}
-		// Find the corresponding check's on_fail action
-		for _, c := range allContracts {
-			for _, ch := range c.Checks {
-				if c.ID == cr.ContractID && ch.Name == cr.CheckName {
-					cmds, err := contracts.DispatchAction(ctx, ch.OnFail, c.Scope, &contracts.DefaultExecutor{})
-					if err != nil {
-						fmt.Fprintf(os.Stderr, "healthcheck: action dispatch for %s: %v\n", c.ID, err)
-					}
-					for _, cmd := range cmds {
-						fmt.Printf("  ACTION: %s\n", cmd)
-					}
-				}
-			}
-		}
-	}
-
-	// Meta-escalation: if any contracts failed, send one summary task to sysadmin
-	if result.Failed > 0 {
-		var failures []string
-		for _, cr := range result.Results {
-			if !cr.Passed {
-				failures = append(failures, fmt.Sprintf("%s/%s", cr.ContractID, cr.CheckName))
-			}
-		}
-		msg := fmt.Sprintf("Healthcheck: %d contract(s) failed: %s. Review audit log and fix.", result.Failed, strings.Join(failures, ", "))
-		if err := contracts.Escalate("sysadmin", msg); err != nil {
-			fmt.Fprintf(os.Stderr, "healthcheck: escalation failed: %v\n", err)
-		}
+func routeInbox() {
+	if err := runner.MoveOuterInboxTasks(); err != nil {
+		fmt.Fprintf(os.Stderr, "route-inbox failed: %v\n", err)
 		os.Exit(1)
 	}
 }
@@ -265,6 +207,54 @@ func runAgent(name string) {
 	}
 }
 
+// runAssemble prints an agent's freshly assembled AGENTS.md. --explain
+// annotates each section with its source layer and lists missing layers;
+// --diff compares the deployed AGENTS.md to a fresh assembly.
+func runAssemble(args []string) {
+	var name, mode string
+	for _, a := range args {
+		switch a {
+		case "--explain", "--diff":
+			mode = a
+		default:
+			name = a
+		}
+	}
+	if name == "" {
+		fmt.Fprintln(os.Stderr, "usage: con assemble <agent> [--explain|--diff]")
+		os.Exit(1)
+	}
+
+	cfg := loadConfig()
+	if cfg.ResolvedAgent(name).Name == "" {
+		fail("assemble: unknown agent %q", name)
+	}
+	result, err := assembler.AssembleResult(runner.AgentLayers(cfg, name))
+	if err != nil {
+		fail("assemble: %v", err)
+	}
+
+	switch mode {
+	case "--explain":
+		fmt.Print(result.Explain())
+	case "--diff":
+		path := fmt.Sprintf("/home/a-%s/AGENTS.md", name)
+		deployed, err := os.ReadFile(path)
+		if err != nil {
+			fail("assemble: %v", err)
+		}
+		diff := LineDiff(string(deployed), result.String())
+		if diff == "" {
+			fmt.Printf("%s is up to date\n", path)
+			return
+		}
+		fmt.Printf("--- %s (deployed)\n+++ fresh assembly\n%s", path, diff)
+		os.Exit(1)
+	default:
+		fmt.Print(result.String())
+	}
+}
+
 // dropTask writes a task file to the outer inbox. File ownership determines
 // trust level: run as a member of the "trusted" group (or root) for verified
 // framing. See internal/runner/runner.go isTrustedUID.
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ConspiracyOS/agent-runner/internal/contracts"
+	"github.com/ConspiracyOS/agent-runner/internal/quarantine"
+)
+
+func quarantineUsage() {
+	fmt.Fprintln(os.Stderr, "usage: con quarantine <command> [args]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  list              Show quarantined agents and why")
+	fmt.Fprintln(os.Stderr, "  release <agent>   Restore a quarantined agent's prior state")
+	os.Exit(1)
+}
+
+func runQuarantine(args []string) {
+	if len(args) < 1 {
+		quarantineUsage()
+	}
+
+	switch args[0] {
+	case "list":
+		markers, err := quarantine.List()
+		if err != nil {
+			fail("quarantine: %v", err)
+		}
+		if len(markers) == 0 {
+			fmt.Println("no agents quarantined")
+			return
+		}
+		for _, m := range markers {
+			source := ""
+			if m.Source != "" {
+				source = " [" + m.Source + "]"
+			}
+			fmt.Printf("%-16s since %s  %d task(s) held%s  %s\n",
+				m.Agent, m.Since.Local().Format(time.RFC3339), len(m.Held), source, m.Reason)
+			if !m.Isolated() {
+				fmt.Printf("%-16s NOT ISOLATED: %s\n", "", m.Failed)
+			}
+		}
+
+	case "release":
+		if len(args) < 2 {
+			quarantineUsage()
+		}
+		cmds, err := quarantine.Release(context.Background(), &contracts.DefaultExecutor{}, args[1])
+		for _, cmd := range cmds {
+			fmt.Printf("+ %s\n", cmd)
+		}
+		if err != nil {
+			fail("quarantine: %v", err)
+		}
+		fmt.Printf("released %s\n", args[1])
+
+	default:
+		quarantineUsage()
+	}
+}
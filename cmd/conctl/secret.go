@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/ConspiracyOS/agent-runner/internal/config"
+	"github.com/ConspiracyOS/agent-runner/internal/secrets"
+)
+
+func secretUsage() {
+	fmt.Fprintln(os.Stderr, "usage: con secret <command> [args]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  set <name>          Store a secret (value read from stdin)")
+	fmt.Fprintln(os.Stderr, "  get <name>          Print a secret")
+	fmt.Fprintln(os.Stderr, "  list                List secret names")
+	fmt.Fprintln(os.Stderr, "  rm <name>           Delete a secret")
+	fmt.Fprintln(os.Stderr, "  rotate              Re-encrypt the store with a new key")
+	fmt.Fprintln(os.Stderr, "  load <agent>        Decrypt an agent's secrets into its credentials dir")
+	fmt.Fprintln(os.Stderr, "  unload <agent>      Remove an agent's decrypted credentials")
+	os.Exit(1)
+}
+
+func runSecret(args []string) {
+	if len(args) < 1 {
+		secretUsage()
+	}
+	needArg := func() string {
+		if len(args) < 2 {
+			secretUsage()
+		}
+		return args[1]
+	}
+
+	switch args[0] {
+	case "set":
+		// The value comes from stdin only: argv is visible to every user in ps
+		name := needArg()
+		if len(args) > 2 {
+			fail("secret: set takes the value on stdin, not as an argument")
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fail("secret: reading value: %v", err)
+		}
+		value := strings.TrimRight(string(data), "\n")
+		store := openSecretStore()
+		if err := store.Set(name, value); err != nil {
+			fail("secret: %v", err)
+		}
+		saveSecretStore(store)
+		fmt.Printf("secret %s stored (grant it to agents under [secrets.%s] in con.toml)\n", name, name)
+
+	case "get":
+		name := needArg()
+		value, ok := openSecretStore().Get(name)
+		if !ok {
+			fail("secret: %s not found", name)
+		}
+		fmt.Println(value)
+
+	case "list":
+		for _, name := range openSecretStore().List() {
+			fmt.Println(name)
+		}
+
+	case "rm":
+		name := needArg()
+		store := openSecretStore()
+		if !store.Remove(name) {
+			fail("secret: %s not found", name)
+		}
+		saveSecretStore(store)
+		fmt.Printf("secret %s removed\n", name)
+
+	case "rotate":
+		store := openSecretStore()
+		if err := store.Rotate(); err != nil {
+			fail("secret: rotate: %v", err)
+		}
+		fmt.Println("secret store re-encrypted with a new key")
+
+	case "load":
+		agent := needArg()
+		cfg := loadConfig()
+		requireAgent(cfg, agent, "load")
+		u, err := user.Lookup("a-" + agent)
+		if err != nil {
+			fail("secret: load: %v", err)
+		}
+		uid, _ := strconv.Atoi(u.Uid)
+		gid, _ := strconv.Atoi(u.Gid)
+		store := openSecretStore()
+		if err := store.Materialize(config.CredentialsPath(agent), cfg.AgentSecrets(agent), uid, gid); err != nil {
+			fail("secret: load: %v", err)
+		}
+
+	case "unload":
+		agent := needArg()
+		requireAgent(loadConfig(), agent, "unload")
+		if err := secrets.Clear(config.CredentialsPath(agent)); err != nil {
+			fail("secret: unload: %v", err)
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown secret command: %s\n", args[0])
+		secretUsage()
+	}
+}
+
+// requireAgent fails unless agent is a valid name configured in cfg, so a
+// crafted argument cannot point a credentials path outside /srv/con.
+func requireAgent(cfg *config.Config, agent, cmd string) {
+	if !config.ValidAgentName(agent) {
+		fail("secret: %s: invalid agent name %q", cmd, agent)
+	}
+	if cfg.ResolvedAgent(agent).Name == "" {
+		fail("secret: %s: agent %q not found in config", cmd, agent)
+	}
+}
+
+func openSecretStore() *secrets.Store {
+	store, err := secrets.Open(secrets.DefaultPath, secrets.DefaultKeyPath)
+	if err != nil {
+		fail("secret: %v", err)
+	}
+	return store
+}
+
+func saveSecretStore(store *secrets.Store) {
+	if err := store.Save(); err != nil {
+		fail("secret: saving store: %v", err)
+	}
+}
+
+func fail(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,37 @@
+#!/bin/sh
+# CON-AGENT-003: Verify the deployed skills of agent $AGENT are root-owned,
+# read-only, and match the hash manifest written at bootstrap
+# (/srv/con/skills/$AGENT.sha256). Agents without a manifest pass.
+# Exit 0 = OK, exit 1 = drift detected.
+
+skills="/srv/con/agents/$AGENT/workspace/skills"
+manifest="/srv/con/skills/$AGENT.sha256"
+FAIL=0
+
+[ -f "$manifest" ] || exit 0
+
+if [ ! -d "$skills" ]; then
+    echo "DRIFT: $AGENT skills dir missing"
+    exit 1
+fi
+
+for f in "$skills"/*; do
+    [ -e "$f" ] || continue
+    owner=$(stat -c '%U' "$f" 2>/dev/null)
+    perms=$(stat -c '%a' "$f" 2>/dev/null)
+    if [ "$owner" != "root" ] || [ "$perms" != "444" ]; then
+        echo "DRIFT: $AGENT skill $(basename "$f") is $owner mode $perms (expected root 444)"
+        FAIL=1
+    fi
+    if ! grep -q "  $(basename "$f")\$" "$manifest"; then
+        echo "DRIFT: $AGENT skill $(basename "$f") not in manifest"
+        FAIL=1
+    fi
+done
+
+if ! (cd "$skills" && sha256sum -c --quiet "$manifest" >/dev/null 2>&1); then
+    echo "DRIFT: $AGENT skills do not match manifest"
+    FAIL=1
+fi
+
+exit $FAIL
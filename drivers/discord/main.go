@@ -518,6 +518,10 @@ func pollResponses(dg *discordgo.Session, cfg Config, exec Executor, tracker *re
 	for {
 		time.Sleep(5 * time.Second)
 
+		for _, alert := range collectAlerts(exec) {
+			sendResponse(dg, cfg, dms, "**alert:** "+alert)
+		}
+
 		out, err := exec.Run("ls /srv/con/agents/*/outbox/*.response 2>/dev/null")
 		if err != nil || out == "" {
 			continue
@@ -549,6 +553,32 @@ func pollResponses(dg *discordgo.Session, cfg Config, exec Executor, tracker *re
 	}
 }
 
+// collectAlerts reads and removes the contract alerts the healthcheck left
+// for Discord (alert sink type "discord"). Removing them instead of seeding
+// means alerts raised while the driver was down are still delivered.
+func collectAlerts(exec Executor) []string {
+	out, err := exec.Run("ls /srv/con/alerts/*.alert 2>/dev/null")
+	if err != nil || out == "" {
+		return nil
+	}
+	var alerts []string
+	for _, path := range strings.Split(out, "\n") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		content, err := exec.Run(fmt.Sprintf("cat '%s' && rm -f '%s'", path, path))
+		if err != nil {
+			log.Printf("reading alert %s: %v", path, err)
+			continue
+		}
+		if content != "" {
+			alerts = append(alerts, content)
+		}
+	}
+	return alerts
+}
+
 // sendResponse posts a message to the appropriate Discord destination.
 func sendResponse(dg *discordgo.Session, cfg Config, dms *dmChannels, content string) {
 	chunks := splitMessage(content, 2000)
@@ -158,3 +158,21 @@ func TestShellEscape(t *testing.T) {
 		t.Errorf("expected %q, got %q", expected, cmd)
 	}
 }
+
+func TestCollectAlerts(t *testing.T) {
+	path := "/srv/con/alerts/20260301-120000-CON-SYS-001-disk_free.alert"
+	mock := &MockExecutor{
+		Responses: map[string]string{
+			"ls /srv/con/alerts/*.alert 2>/dev/null":          path + "\n",
+			fmt.Sprintf("cat '%s' && rm -f '%s'", path, path): "[conspiracy] CON-SYS-001/disk_free failed",
+		},
+	}
+
+	alerts := collectAlerts(mock)
+	if len(alerts) != 1 || !strings.Contains(alerts[0], "disk_free failed") {
+		t.Errorf("alerts = %v", alerts)
+	}
+	if len(mock.Calls) != 2 || !strings.Contains(mock.Calls[1], "rm -f") {
+		t.Errorf("calls = %v, want the alert removed after reading", mock.Calls)
+	}
+}
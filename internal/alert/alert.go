@@ -0,0 +1,321 @@
+// Package alert tells humans about contract failures and recoveries through
+// configured sinks (webhook, SMTP, file, unix socket, Discord), independently
+// of the agents, which may be halted.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ConspiracyOS/agent-runner/internal/config"
+	"github.com/ConspiracyOS/agent-runner/internal/redact"
+)
+
+// DefaultStatePath is where dedup timestamps persist between healthchecks.
+const DefaultStatePath = "/var/lib/con/alerts-state.json"
+
+// DiscordDir is where the discord sink leaves alerts for the Discord driver,
+// which posts and removes them.
+var DiscordDir = "/srv/con/alerts"
+
+// DefaultTemplate renders an alert as a one-line summary.
+const DefaultTemplate = `[{{.System}}] {{.ContractID}}/{{.CheckName}} {{.Status}}{{if .Message}}: {{.Message}}{{end}}`
+
+// Alert is one contract check transition.
+type Alert struct {
+	System     string    `json:"system"`
+	ContractID string    `json:"contract"`
+	CheckName  string    `json:"check"`
+	Status     string    `json:"status"` // failed | recovered
+	Message    string    `json:"message,omitempty"`
+	Output     string    `json:"output,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// Key identifies an alert for dedup: the same check in the same status.
+func (a Alert) Key() string {
+	return a.ContractID + "/" + a.CheckName + "/" + a.Status
+}
+
+// sink delivers a rendered alert.
+type sink interface {
+	send(ctx context.Context, a Alert, text string) error
+}
+
+// target is a configured sink with its template and dedup window.
+type target struct {
+	name  string
+	sink  sink
+	tmpl  *template.Template
+	dedup time.Duration
+}
+
+// Notifier fans alerts out to every configured sink.
+type Notifier struct {
+	// Redactor masks secrets in an alert's output and message before any
+	// sink sees them; nil applies the credential patterns only.
+	Redactor *redact.Redactor
+
+	targets   []target
+	statePath string
+	sent      map[string]time.Time // sink#key -> last sent
+}
+
+// New builds a Notifier from the alert config. statePath persists dedup
+// timestamps; empty keeps them in memory only.
+func New(cfgs []config.AlertConfig, statePath string) (*Notifier, error) {
+	n := &Notifier{statePath: statePath, sent: map[string]time.Time{}}
+	for i, c := range cfgs {
+		t := target{name: fmt.Sprintf("%d:%s", i, c.Type)}
+		switch c.Type {
+		case "webhook":
+			t.sink = &webhook{url: c.URL}
+		case "smtp":
+			t.sink = &mail{addr: c.SMTPAddr, from: c.From, to: c.To, username: c.Username, password: os.Getenv(c.PasswordEnv)}
+		case "file":
+			t.sink = &file{path: c.Path}
+		case "socket":
+			t.sink = &socket{path: c.Path}
+		case "discord":
+			t.sink = &discord{}
+		default:
+			return nil, fmt.Errorf("alert sink %d: unknown type %q", i, c.Type)
+		}
+		text := c.Template
+		if text == "" {
+			text = DefaultTemplate
+		}
+		tmpl, err := template.New(t.name).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("alert sink %d: template: %w", i, err)
+		}
+		t.tmpl = tmpl
+		if c.Dedup != "" {
+			if t.dedup, err = time.ParseDuration(c.Dedup); err != nil {
+				return nil, fmt.Errorf("alert sink %d: dedup: %w", i, err)
+			}
+		}
+		n.targets = append(n.targets, t)
+	}
+	if statePath != "" {
+		if data, err := os.ReadFile(statePath); err == nil {
+			json.Unmarshal(data, &n.sent) // a corrupt file only loses dedup history
+		}
+	}
+	return n, nil
+}
+
+// Notify sends a to every sink that has not sent the same key within its
+// dedup window. Every sink is tried; the errors of those that failed are
+// returned together.
+func (n *Notifier) Notify(ctx context.Context, a Alert) error {
+	if a.Time.IsZero() {
+		a.Time = time.Now()
+	}
+	a.Output, _ = n.Redactor.Redact(a.Output)
+	a.Message, _ = n.Redactor.Redact(a.Message)
+	var errs []string
+	for _, t := range n.targets {
+		key := t.name + "#" + a.Key()
+		if last, ok := n.sent[key]; ok && t.dedup > 0 && a.Time.Sub(last) < t.dedup {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := t.tmpl.Execute(&buf, a); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: template: %v", t.name, err))
+			continue
+		}
+		if err := t.sink.send(ctx, a, buf.String()); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", t.name, err))
+			continue
+		}
+		n.sent[key] = a.Time
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("alert sinks failed: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Save persists dedup timestamps.
+func (n *Notifier) Save() error {
+	if n.statePath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(n.sent, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := n.statePath + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, n.statePath)
+}
+
+// webhook POSTs the alert as JSON, with the rendered text in "text".
+type webhook struct{ url string }
+
+func (w *webhook) send(ctx context.Context, a Alert, text string) error {
+	body, err := json.Marshal(struct {
+		Alert
+		Key  string `json:"key"`
+		Text string `json:"text"`
+	}{a, a.Key(), text})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// mail sends the rendered text as a plain-text email.
+type mail struct {
+	addr, from, username, password string
+	to                             []string
+}
+
+func (m *mail) send(ctx context.Context, a Alert, text string) error {
+	subject := fmt.Sprintf("[%s] %s/%s %s", a.System, a.ContractID, a.CheckName, a.Status)
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		headerSafe(m.from), headerSafe(strings.Join(m.to, ", ")), headerSafe(subject), text)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// smtp.Client has no context support; the deadline bounds the whole
+	// exchange instead.
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	host, _, _ := net.SplitHostPort(m.addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if m.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.username, m.password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(m.from); err != nil {
+		return err
+	}
+	for _, to := range m.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// file appends the rendered text as one line.
+type file struct{ path string }
+
+func (f *file) send(ctx context.Context, a Alert, text string) error {
+	fh, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	_, err = fmt.Fprintln(fh, oneLine(text))
+	return err
+}
+
+// socket writes the rendered text as one line to a unix stream socket.
+type socket struct{ path string }
+
+func (s *socket) send(ctx context.Context, a Alert, text string) error {
+	var d net.Dialer
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	conn, err := d.DialContext(ctx, "unix", s.path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = fmt.Fprintln(conn, oneLine(text))
+	return err
+}
+
+// discord leaves the alert in DiscordDir for the Discord driver to post.
+type discord struct{}
+
+func (d *discord) send(ctx context.Context, a Alert, text string) error {
+	if err := os.MkdirAll(DiscordDir, 0755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s-%s.alert", a.Time.Format("20060102-150405"), fileSafe(a.ContractID), fileSafe(a.CheckName))
+	return os.WriteFile(filepath.Join(DiscordDir, name), []byte(text), 0644)
+}
+
+// fileSafe replaces anything outside [A-Za-z0-9@._-] so s is one path element.
+func fileSafe(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '@', r == '.', r == '_', r == '-':
+			return r
+		}
+		return '_'
+	}, s)
+	if s == "" || s == "." || s == ".." {
+		return "_"
+	}
+	return s
+}
+
+// headerSafe replaces CR and LF, so a value cannot end its mail header and
+// inject others.
+func headerSafe(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
+
+func oneLine(s string) string {
+	return strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", " ")
+}
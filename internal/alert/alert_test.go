@@ -0,0 +1,302 @@
+package alert
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ConspiracyOS/agent-runner/internal/config"
+	"github.com/ConspiracyOS/agent-runner/internal/redact"
+)
+
+var failed = Alert{System: "conspiracy", ContractID: "CON-SYS-001", CheckName: "disk_free", Status: "failed",
+	Message: "disk below 15%", Time: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+
+func TestWebhook(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type = %q", r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	n, err := New([]config.AlertConfig{{Type: "webhook", URL: srv.URL}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), failed); err != nil {
+		t.Fatal(err)
+	}
+	if got["key"] != "CON-SYS-001/disk_free/failed" || got["contract"] != "CON-SYS-001" {
+		t.Errorf("payload = %v", got)
+	}
+	if got["text"] != "[conspiracy] CON-SYS-001/disk_free failed: disk below 15%" {
+		t.Errorf("text = %q, want default template", got["text"])
+	}
+}
+
+func TestWebhook_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	n, _ := New([]config.AlertConfig{{Type: "webhook", URL: srv.URL}}, "")
+	if err := n.Notify(context.Background(), failed); err == nil {
+		t.Error("expected error for 502 response")
+	}
+}
+
+func TestFileTemplateAndDedup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "alerts.log")
+	state := filepath.Join(dir, "state.json")
+	cfg := []config.AlertConfig{{Type: "file", Path: path, Template: "{{.Status}} {{.Key}}", Dedup: "1h"}}
+
+	n, _ := New(cfg, state)
+	n.Notify(context.Background(), failed)
+	later := failed
+	later.Time = failed.Time.Add(10 * time.Minute)
+	n.Notify(context.Background(), later) // within window: suppressed
+	recovered := later
+	recovered.Status = "recovered"
+	n.Notify(context.Background(), recovered) // different key: sent
+	if err := n.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Dedup survives across healthcheck runs
+	n, _ = New(cfg, state)
+	n.Notify(context.Background(), later)
+	muchLater := failed
+	muchLater.Time = failed.Time.Add(2 * time.Hour)
+	n.Notify(context.Background(), muchLater) // window elapsed: sent
+
+	data, _ := os.ReadFile(path)
+	want := "failed CON-SYS-001/disk_free/failed\nrecovered CON-SYS-001/disk_free/recovered\nfailed CON-SYS-001/disk_free/failed\n"
+	if string(data) != want {
+		t.Errorf("file =\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		got <- line
+	}()
+
+	n, _ := New([]config.AlertConfig{{Type: "socket", Path: path}}, "")
+	if err := n.Notify(context.Background(), failed); err != nil {
+		t.Fatal(err)
+	}
+	if line := <-got; !strings.Contains(line, "CON-SYS-001/disk_free failed") {
+		t.Errorf("socket line = %q", line)
+	}
+}
+
+func TestDiscord(t *testing.T) {
+	old := DiscordDir
+	DiscordDir = t.TempDir()
+	defer func() { DiscordDir = old }()
+
+	n, _ := New([]config.AlertConfig{{Type: "discord"}}, "")
+	if err := n.Notify(context.Background(), failed); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(DiscordDir, "*.alert"))
+	if len(files) != 1 {
+		t.Fatalf("alert files = %v, want 1", files)
+	}
+	if data, _ := os.ReadFile(files[0]); !strings.Contains(string(data), "disk below 15%") {
+		t.Errorf("alert = %q", data)
+	}
+}
+
+func TestDiscord_UnsafeNames(t *testing.T) {
+	old := DiscordDir
+	DiscordDir = t.TempDir()
+	defer func() { DiscordDir = old }()
+
+	a := failed
+	a.ContractID, a.CheckName = "CON-X@web", "../../etc/passwd"
+	n, _ := New([]config.AlertConfig{{Type: "discord"}}, "")
+	if err := n.Notify(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(DiscordDir, "*.alert"))
+	if len(files) != 1 || !strings.Contains(filepath.Base(files[0]), "CON-X@web-.._.._etc_passwd") {
+		t.Errorf("alert files = %v", files)
+	}
+}
+
+// fakeSMTP accepts one message and returns its DATA section.
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	data := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		io.WriteString(conn, "220 fake ESMTP\r\n")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				io.WriteString(conn, "250 fake\r\n")
+			case cmd == "DATA":
+				io.WriteString(conn, "354 go ahead\r\n")
+				var body strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					body.WriteString(l)
+				}
+				data <- body.String()
+				io.WriteString(conn, "250 queued\r\n")
+			case cmd == "QUIT":
+				io.WriteString(conn, "221 bye\r\n")
+				return
+			default:
+				io.WriteString(conn, "250 ok\r\n")
+			}
+		}
+	}()
+	return ln.Addr().String(), data
+}
+
+func TestSMTP(t *testing.T) {
+	addr, data := fakeSMTP(t)
+	n, _ := New([]config.AlertConfig{{Type: "smtp", SMTPAddr: addr, From: "con@example.com", To: []string{"ops@example.com"}}}, "")
+	if err := n.Notify(context.Background(), failed); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-data
+	if !strings.Contains(msg, "Subject: [conspiracy] CON-SYS-001/disk_free failed") || !strings.Contains(msg, "disk below 15%") {
+		t.Errorf("message =\n%s", msg)
+	}
+}
+
+func TestSMTP_HeaderInjection(t *testing.T) {
+	addr, data := fakeSMTP(t)
+	n, _ := New([]config.AlertConfig{{Type: "smtp", SMTPAddr: addr, From: "con@example.com", To: []string{"ops@example.com"}}}, "")
+	a := failed
+	a.ContractID = "CON-X\r\nBcc: attacker@example.com"
+	if err := n.Notify(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+	headers, _, _ := strings.Cut(<-data, "\r\n\r\n")
+	if strings.Contains(headers, "\r\nBcc:") {
+		t.Errorf("contract ID injected a header:\n%s", headers)
+	}
+}
+
+func TestNotify_RedactsOutput(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+	oldDir := DiscordDir
+	DiscordDir = t.TempDir()
+	t.Cleanup(func() { DiscordDir = oldDir })
+
+	n, _ := New([]config.AlertConfig{
+		{Type: "webhook", URL: srv.URL},
+		{Type: "discord", Template: "{{.Output}}"},
+	}, "")
+	n.Redactor = redact.New(map[string]string{"DB_PASSWORD": "hunter2hunter2"})
+	a := failed
+	a.Output = "connect failed: password=hunter2hunter2 token=sk-abcdefghijklmnopqrstuvwxyz"
+	if err := n.Notify(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(DiscordDir, "*.alert"))
+	if len(files) != 1 {
+		t.Fatalf("discord alerts = %v, want 1", files)
+	}
+	posted, _ := os.ReadFile(files[0])
+	for sink, text := range map[string]string{"webhook": got["output"].(string), "discord": string(posted)} {
+		if strings.Contains(text, "hunter2") || strings.Contains(text, "sk-abc") {
+			t.Errorf("%s output not redacted: %q", sink, text)
+		}
+		if !strings.Contains(text, "[REDACTED:DB_PASSWORD]") {
+			t.Errorf("%s output = %q, want the secret masked", sink, text)
+		}
+	}
+}
+
+func TestSMTP_Timeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second) // never greets
+		}
+	}()
+
+	n, _ := New([]config.AlertConfig{{Type: "smtp", SMTPAddr: ln.Addr().String(), From: "con@example.com", To: []string{"ops@example.com"}}}, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := n.Notify(ctx, failed); err == nil {
+		t.Fatal("expected error from a server that never greets")
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("smtp send took %s, want it bounded by ctx", d)
+	}
+}
+
+func TestNotify_OneSinkFailingDoesNotStopOthers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts.log")
+	n, _ := New([]config.AlertConfig{
+		{Type: "socket", Path: filepath.Join(t.TempDir(), "missing.sock")},
+		{Type: "file", Path: path},
+	}, "")
+	if err := n.Notify(context.Background(), failed); err == nil || !strings.Contains(err.Error(), "socket") {
+		t.Errorf("err = %v, want socket failure reported", err)
+	}
+	if data, _ := os.ReadFile(path); len(data) == 0 {
+		t.Error("file sink should still receive the alert")
+	}
+}
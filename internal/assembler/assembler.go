@@ -5,76 +5,499 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
 )
 
 type Layers struct {
-	OuterRoot          string   // /etc/con/
-	InnerRoot          string   // /srv/con/config/ (may be empty)
+	OuterRoot          string // /etc/con/
+	InnerRoot          string // /srv/con/config/ (may be empty)
 	Groups             []string
 	Roles              []string
 	Scopes             []string
 	AgentName          string
 	InlineInstructions string // from con.toml [[agents]] instructions field
+	Vars               Vars   // template data for every layer
+}
+
+// Vars is the data available to AGENTS.md templates, so one role file can
+// serve many agents, e.g. "You may delegate to {{join .CanTask ", "}}".
+type Vars struct {
+	Name    string
+	Tier    string
+	Roles   []string
+	Groups  []string
+	Scopes  []string
+	Model   string
+	CanTask []string // agents whose inbox this agent can write to
+	System  string   // [system] name
+	Mission string   // contents of mission.md
+}
+
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// render executes a layer as a Go template against vars.
+// source names the layer in error messages.
+func render(source, text string, vars Vars) (string, error) {
+	tmpl, err := template.New(source).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parsing template %s: %w", source, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return "", fmt.Errorf("rendering template %s: %w", source, err)
+	}
+	return b.String(), nil
+}
+
+// Section is one rendered layer of an assembled AGENTS.md.
+type Section struct {
+	Source  string // layer file path, or "instructions" for inline con.toml text
+	Content string
+	Agent   bool // the agent's own layer or inline instructions, not shared
+	blocks  []*block
+}
+
+// block is a run of text within a layer, or a named section.
+type block struct {
+	name    string // "" for unnamed text
+	content string
+	source  string // file that last set the content (differs from the layer when overridden)
+}
+
+// doc is a parsed layer file before it becomes a Section.
+type doc struct {
+	source string
+	mode   string
+	blocks []*block
+}
+
+// Result is an assembled AGENTS.md with provenance for each section.
+type Result struct {
+	Sections []Section
+	Missing  []string // layer files that were looked for but not found
+}
+
+// LayerSep separates layers in the assembled AGENTS.md. The comment makes
+// it unambiguous (a layer may contain its own "---" rules), so the runner
+// can split the file back into layers; it is removed from layer content.
+const LayerSep = "\n\n---\n" + layerMarker + "\n\n"
+
+const layerMarker = "<!-- con:layer -->"
+
+// AgentLayerSep precedes the agent's own sections (its agents/<name> layer
+// and inline instructions), so the runner can tell them from shared layers
+// wherever they are. Without shared layers the file starts with agentMarker.
+const AgentLayerSep = "\n\n---\n" + agentMarker + "\n\n"
+
+const agentMarker = "<!-- con:agent -->"
+
+// maxIncludeDepth bounds nested @include directives.
+const maxIncludeDepth = 8
+
+// Layer modes, declared in an inner file's frontmatter. They control how the
+// inner file combines with its outer counterpart.
+const (
+	ModeAppend  = "append"  // inner after outer (default)
+	ModePrepend = "prepend" // inner before outer
+	ModeReplace = "replace" // inner only
+)
+
+// String returns the assembled AGENTS.md as written to the agent's home.
+func (r *Result) String() string {
+	parts := make([]string, len(r.Sections))
+	for i, s := range r.Sections {
+		parts[i] = s.Content
+	}
+	return r.join(parts) + "\n"
+}
+
+// join joins section texts with LayerSep, and with AgentLayerSep before the
+// first agent section.
+func (r *Result) join(parts []string) string {
+	var b strings.Builder
+	agent := false
+	for i, s := range r.Sections {
+		switch {
+		case s.Agent && !agent && i == 0:
+			b.WriteString(agentMarker + "\n\n")
+		case s.Agent && !agent:
+			b.WriteString(AgentLayerSep)
+		case i > 0:
+			b.WriteString(LayerSep)
+		}
+		agent = agent || s.Agent
+		b.WriteString(parts[i])
+	}
+	return b.String()
+}
+
+// SplitLayers splits an assembled AGENTS.md into its shared layers and the
+// agent's own sections, which are returned as one text (empty if there are
+// none).
+func SplitLayers(agentsMD string) (shared []string, agent string) {
+	text := strings.TrimRight(agentsMD, "\n")
+	if rest, ok := strings.CutPrefix(text, agentMarker+"\n\n"); ok {
+		return nil, rest
+	}
+	text, agent, _ = strings.Cut(text, AgentLayerSep)
+	return strings.Split(text, LayerSep), agent
+}
+
+// Explain returns the assembled AGENTS.md with an HTML comment naming the
+// source of each section and of each overridden named section, followed by
+// the layers that were not found.
+func (r *Result) Explain() string {
+	parts := make([]string, len(r.Sections))
+	for i, s := range r.Sections {
+		var texts []string
+		for _, b := range s.blocks {
+			if b.content == "" {
+				continue
+			}
+			if b.source != s.Source {
+				texts = append(texts, fmt.Sprintf("<!-- section %s: %s -->\n%s", b.name, b.source, b.content))
+			} else {
+				texts = append(texts, b.content)
+			}
+		}
+		parts[i] = fmt.Sprintf("<!-- source: %s -->\n%s", s.Source, strings.Join(texts, "\n\n"))
+	}
+	out := r.join(parts) + "\n"
+	if len(r.Missing) > 0 {
+		out += "\n<!-- missing layers:\n"
+		for _, m := range r.Missing {
+			out += "  " + m + "\n"
+		}
+		out += "-->\n"
+	}
+	return out
 }
 
 // Assemble concatenates AGENTS.md files from all layers in order:
 // base → groups → roles → scopes → agent → inline instructions.
 // For each layer, outer is read first, then inner overlays.
+// Every layer is rendered as a Go template with l.Vars.
+//
+// Layer files may use these directives:
+//
+//	---
+//	mode: prepend          frontmatter; append (default), prepend or replace
+//	---                    the outer file of the same layer (inner files only)
+//	@include path          inline a file, relative to the including file and
+//	                       confined to the same layer root
+//	@section name          a named section; a later layer defining the same
+//	...                    name replaces its content in place
+//	@end
 func Assemble(l Layers) (string, error) {
-	var parts []string
+	r, err := AssembleResult(l)
+	if err != nil {
+		return "", err
+	}
+	return r.String(), nil
+}
+
+// AssembleResult is Assemble with per-section provenance.
+func AssembleResult(l Layers) (*Result, error) {
+	r := &Result{}
+	named := map[string]*block{}
+	var docs []*doc
 
-	// Helper: read AGENTS.md from a path, skip if not found
-	read := func(path string) {
-		data, err := os.ReadFile(filepath.Join(path, "AGENTS.md"))
-		if err == nil {
-			parts = append(parts, strings.TrimSpace(string(data)))
+	// register applies a doc's named sections: the first definition of a name
+	// stays in place, later definitions replace its content and are removed.
+	register := func(d *doc) {
+		var kept []*block
+		for _, b := range d.blocks {
+			if b.name != "" {
+				if prev, ok := named[b.name]; ok {
+					prev.content = b.content
+					prev.source = b.source
+					continue
+				}
+				named[b.name] = b
+			}
+			kept = append(kept, b)
 		}
+		d.blocks = kept
 	}
 
-	// Base layer
-	read(filepath.Join(l.OuterRoot, "base"))
-	if l.InnerRoot != "" {
-		read(filepath.Join(l.InnerRoot, "base"))
+	// layer loads the outer and inner AGENTS.md for one layer and combines
+	// them according to the inner file's mode.
+	layer := func(parts ...string) error {
+		outer, err := loadDoc(l.OuterRoot, filepath.Join(parts...), l.Vars, r)
+		if err != nil {
+			return err
+		}
+		var inner *doc
+		if l.InnerRoot != "" {
+			if inner, err = loadDoc(l.InnerRoot, filepath.Join(parts...), l.Vars, r); err != nil {
+				return err
+			}
+		}
+		if inner != nil && inner.mode == ModeReplace {
+			outer = nil
+		}
+		// Inner sections override outer ones regardless of output position
+		for _, d := range []*doc{outer, inner} {
+			if d != nil {
+				register(d)
+			}
+		}
+		if inner != nil && inner.mode == ModePrepend {
+			outer, inner = inner, outer
+		}
+		for _, d := range []*doc{outer, inner} {
+			if d != nil {
+				docs = append(docs, d)
+			}
+		}
+		return nil
 	}
 
-	// Group layers
+	var dirs [][]string
+	dirs = append(dirs, []string{"base"})
 	for _, g := range l.Groups {
-		read(filepath.Join(l.OuterRoot, "groups", g))
-		if l.InnerRoot != "" {
-			read(filepath.Join(l.InnerRoot, "groups", g))
+		dirs = append(dirs, []string{"groups", g})
+	}
+	for _, role := range l.Roles {
+		dirs = append(dirs, []string{"roles", role})
+	}
+	for _, s := range l.Scopes {
+		dirs = append(dirs, []string{"scopes", s})
+	}
+	for _, d := range dirs {
+		if err := layer(d...); err != nil {
+			return nil, err
 		}
 	}
+	shared := len(docs) // docs from here on are the agent's own
+	if err := layer("agents", l.AgentName); err != nil {
+		return nil, err
+	}
 
-	// Role layers
-	for _, r := range l.Roles {
-		read(filepath.Join(l.OuterRoot, "roles", r))
-		if l.InnerRoot != "" {
-			read(filepath.Join(l.InnerRoot, "roles", r))
+	// Inline instructions from con.toml
+	if l.InlineInstructions != "" {
+		out, err := render("instructions", l.InlineInstructions, l.Vars)
+		if err != nil {
+			return nil, err
+		}
+		blocks, err := parseBlocks("instructions", out)
+		if err != nil {
+			return nil, err
 		}
+		d := &doc{source: "instructions", blocks: blocks}
+		register(d)
+		docs = append(docs, d)
 	}
 
-	// Scope layers
-	for _, s := range l.Scopes {
-		read(filepath.Join(l.OuterRoot, "scopes", s))
-		if l.InnerRoot != "" {
-			read(filepath.Join(l.InnerRoot, "scopes", s))
+	for i, d := range docs {
+		var texts []string
+		for _, b := range d.blocks {
+			if b.content != "" {
+				texts = append(texts, b.content)
+			}
+		}
+		if len(texts) == 0 {
+			continue
 		}
+		r.Sections = append(r.Sections, Section{
+			Source:  d.source,
+			Content: strings.Join(texts, "\n\n"),
+			Agent:   i >= shared,
+			blocks:  d.blocks,
+		})
 	}
 
-	// Agent-specific layer
-	read(filepath.Join(l.OuterRoot, "agents", l.AgentName))
-	if l.InnerRoot != "" {
-		read(filepath.Join(l.InnerRoot, "agents", l.AgentName))
+	if len(r.Sections) == 0 {
+		return nil, fmt.Errorf("no AGENTS.md content found for agent %q", l.AgentName)
 	}
 
-	// Inline instructions from con.toml
-	if l.InlineInstructions != "" {
-		parts = append(parts, strings.TrimSpace(l.InlineInstructions))
+	return r, nil
+}
+
+// loadDoc reads, expands and renders root/rel/AGENTS.md. A missing file is
+// recorded in r.Missing and returns a nil doc.
+func loadDoc(root, rel string, vars Vars, r *Result) (*doc, error) {
+	file := filepath.Join(root, rel, "AGENTS.md")
+	data, err := os.ReadFile(file)
+	if err != nil {
+		r.Missing = append(r.Missing, file)
+		return nil, nil
+	}
+	mode, body, err := parseFrontmatter(file, string(data))
+	if err != nil {
+		return nil, err
+	}
+	body, err = expandIncludes(root, file, body, 0)
+	if err != nil {
+		return nil, err
+	}
+	out, err := render(file, body, vars)
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := parseBlocks(file, out)
+	if err != nil {
+		return nil, err
+	}
+	return &doc{source: file, mode: mode, blocks: blocks}, nil
+}
+
+// parseFrontmatter splits an optional leading "---" YAML block from text and
+// returns the declared mode (default append) and the remaining body.
+func parseFrontmatter(source, text string) (string, string, error) {
+	if !strings.HasPrefix(text, "---\n") {
+		return ModeAppend, text, nil
+	}
+	end := strings.Index(text[4:], "\n---")
+	if end < 0 {
+		return "", "", fmt.Errorf("%s: unterminated frontmatter", source)
+	}
+	front := text[4 : 4+end]
+	body := strings.TrimPrefix(text[4+end+4:], "\n")
+
+	var meta struct {
+		Mode string `yaml:"mode"`
+	}
+	if err := yaml.Unmarshal([]byte(front), &meta); err != nil {
+		return "", "", fmt.Errorf("%s: parsing frontmatter: %w", source, err)
+	}
+	switch meta.Mode {
+	case "":
+		meta.Mode = ModeAppend
+	case ModeAppend, ModePrepend, ModeReplace:
+	default:
+		return "", "", fmt.Errorf("%s: invalid mode %q (must be append/prepend/replace)", source, meta.Mode)
+	}
+	return meta.Mode, body, nil
+}
+
+// expandIncludes replaces "@include path" lines with the referenced file.
+// Paths are relative to the including file and must stay within root once
+// symlinks are resolved, so a link planted in a writable layer cannot pull
+// in files from elsewhere. Lines inside fenced code blocks are left alone.
+func expandIncludes(root, source, text string, depth int) (string, error) {
+	if !strings.Contains(text, "@include") {
+		return text, nil
+	}
+	if depth >= maxIncludeDepth {
+		return "", fmt.Errorf("%s: includes nested deeper than %d", source, maxIncludeDepth)
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	realRoot, err := filepath.EvalSymlinks(absRoot)
+	if err != nil {
+		return "", err
 	}
 
-	if len(parts) == 0 {
-		return "", fmt.Errorf("no AGENTS.md content found for agent %q", l.AgentName)
+	lines := strings.Split(text, "\n")
+	inFence := false
+	for i, line := range lines {
+		if isFence(line) {
+			inFence = !inFence
+		}
+		arg, ok := strings.CutPrefix(strings.TrimSpace(line), "@include ")
+		if !ok || inFence {
+			continue
+		}
+		arg = strings.TrimSpace(arg)
+		path := arg
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(source), path)
+		}
+		path, err = filepath.Abs(path)
+		if err != nil {
+			return "", err
+		}
+		if !within(absRoot, path) {
+			return "", fmt.Errorf("%s: @include %s: outside %s", source, arg, root)
+		}
+		if path, err = filepath.EvalSymlinks(path); err != nil {
+			return "", fmt.Errorf("%s: @include %s: %w", source, arg, err)
+		}
+		if !within(realRoot, path) {
+			return "", fmt.Errorf("%s: @include %s: outside %s", source, arg, root)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("%s: @include %s: %w", source, arg, err)
+		}
+		_, body, err := parseFrontmatter(path, string(data))
+		if err != nil {
+			return "", err
+		}
+		body, err = expandIncludes(root, path, body, depth+1)
+		if err != nil {
+			return "", err
+		}
+		lines[i] = strings.TrimRight(body, "\n")
 	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// within reports whether path is root or below it.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
 
-	return strings.Join(parts, "\n\n---\n\n") + "\n", nil
+// isFence reports whether line opens or closes a fenced code block.
+func isFence(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
+}
+
+// parseBlocks splits rendered text into unnamed runs and "@section name" …
+// "@end" named sections. Block content is trimmed and cannot contain the
+// layer markers. Directives inside fenced code blocks are content.
+func parseBlocks(source, text string) ([]*block, error) {
+	var blocks []*block
+	var cur []string
+	name := ""
+	flush := func() {
+		content := strings.Join(cur, "\n")
+		for _, m := range []string{layerMarker, agentMarker} {
+			content = strings.ReplaceAll(content, m, "")
+		}
+		blocks = append(blocks, &block{name: name, content: strings.TrimSpace(content), source: source})
+		cur = nil
+	}
+	inFence := false
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if isFence(line) {
+			inFence = !inFence
+		}
+		if inFence {
+			cur = append(cur, line)
+			continue
+		}
+		if arg, ok := strings.CutPrefix(trimmed, "@section "); ok {
+			if name != "" {
+				return nil, fmt.Errorf("%s: @section %s inside section %s", source, arg, name)
+			}
+			flush()
+			name = strings.TrimSpace(arg)
+			continue
+		}
+		if trimmed == "@end" {
+			if name == "" {
+				return nil, fmt.Errorf("%s: @end without @section", source)
+			}
+			flush()
+			name = ""
+			continue
+		}
+		cur = append(cur, line)
+	}
+	if name != "" {
+		return nil, fmt.Errorf("%s: section %s missing @end", source, name)
+	}
+	flush()
+	return blocks, nil
 }
@@ -84,3 +84,260 @@ func TestAssembleInnerOverlay(t *testing.T) {
 		t.Error("missing inner base overlay")
 	}
 }
+
+func TestAssembleRendersTemplates(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "roles", "researcher"), 0755)
+	os.WriteFile(filepath.Join(root, "roles", "researcher", "AGENTS.md"),
+		[]byte("You are {{.Name}} ({{.Tier}}) on {{.System}}.\nDelegate to: {{join .CanTask \", \"}}\n"), 0644)
+
+	layers := Layers{
+		OuterRoot:          root,
+		Roles:              []string{"researcher"},
+		AgentName:          "scout",
+		InlineInstructions: "Mission: {{.Mission}}",
+		Vars: Vars{
+			Name:    "scout",
+			Tier:    "worker",
+			CanTask: []string{"concierge", "sysadmin"},
+			System:  "acme",
+			Mission: "Find things.",
+		},
+	}
+
+	result, err := Assemble(layers)
+	if err != nil {
+		t.Fatalf("Assemble failed: %v", err)
+	}
+	for _, want := range []string{
+		"You are scout (worker) on acme.",
+		"Delegate to: concierge, sysadmin",
+		"Mission: Find things.",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("missing %q in:\n%s", want, result)
+		}
+	}
+}
+
+func TestAssembleTemplateErrorNamesFile(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "base"), 0755)
+	path := filepath.Join(root, "base", "AGENTS.md")
+	os.WriteFile(path, []byte("Hello {{.Name"), 0644)
+
+	_, err := Assemble(Layers{OuterRoot: root, AgentName: "x"})
+	if err == nil {
+		t.Fatal("expected template error, got nil")
+	}
+	if !strings.Contains(err.Error(), path) {
+		t.Errorf("error %q should name %s", err, path)
+	}
+
+	os.WriteFile(path, []byte("Hello {{.Nmae}}"), 0644)
+	if _, err := Assemble(Layers{OuterRoot: root, AgentName: "x"}); err == nil {
+		t.Error("expected error for unknown field, got nil")
+	}
+}
+
+func TestAssembleResultProvenance(t *testing.T) {
+	root := t.TempDir()
+	inner := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "base"), 0755)
+	os.WriteFile(filepath.Join(root, "base", "AGENTS.md"), []byte("Base.\n"), 0644)
+	os.MkdirAll(filepath.Join(root, "roles", "ops"), 0755)
+	os.WriteFile(filepath.Join(root, "roles", "ops", "AGENTS.md"), []byte("Ops.\n"), 0644)
+
+	layers := Layers{
+		OuterRoot:          root,
+		InnerRoot:          inner,
+		Roles:              []string{"ops"},
+		AgentName:          "x",
+		InlineInstructions: "Inline.",
+	}
+	r, err := AssembleResult(layers)
+	if err != nil {
+		t.Fatalf("AssembleResult failed: %v", err)
+	}
+
+	wantSources := []string{
+		filepath.Join(root, "base", "AGENTS.md"),
+		filepath.Join(root, "roles", "ops", "AGENTS.md"),
+		"instructions",
+	}
+	if len(r.Sections) != len(wantSources) {
+		t.Fatalf("got %d sections, want %d", len(r.Sections), len(wantSources))
+	}
+	for i, want := range wantSources {
+		if r.Sections[i].Source != want {
+			t.Errorf("section %d source = %q, want %q", i, r.Sections[i].Source, want)
+		}
+	}
+
+	// inner base, inner role, outer agent, inner agent
+	if len(r.Missing) != 4 {
+		t.Errorf("got %d missing layers, want 4: %v", len(r.Missing), r.Missing)
+	}
+
+	plain, _ := Assemble(layers)
+	if r.String() != plain {
+		t.Error("Result.String() differs from Assemble output")
+	}
+	explain := r.Explain()
+	if !strings.Contains(explain, "<!-- source: "+wantSources[1]+" -->\nOps.") {
+		t.Errorf("explain output missing role provenance:\n%s", explain)
+	}
+	if !strings.Contains(explain, filepath.Join(inner, "agents", "x", "AGENTS.md")) {
+		t.Errorf("explain output missing inner agent layer:\n%s", explain)
+	}
+}
+
+func writeLayer(t *testing.T, root, rel, content string) {
+	t.Helper()
+	dir := filepath.Join(root, rel)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "AGENTS.md"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAssembleInclude(t *testing.T) {
+	root := t.TempDir()
+	writeLayer(t, root, "base", "Intro.\n@include ../shared/policy.md\nOutro.\n")
+	os.MkdirAll(filepath.Join(root, "shared"), 0755)
+	os.WriteFile(filepath.Join(root, "shared", "policy.md"), []byte("Policy for {{.Name}}.\n"), 0644)
+
+	result, err := Assemble(Layers{OuterRoot: root, AgentName: "x", Vars: Vars{Name: "x"}})
+	if err != nil {
+		t.Fatalf("Assemble failed: %v", err)
+	}
+	if result != "Intro.\nPolicy for x.\nOutro.\n" {
+		t.Errorf("unexpected result:\n%s", result)
+	}
+
+	// Includes may not escape the layer root
+	writeLayer(t, root, "base", "@include ../../etc/passwd\n")
+	if _, err := Assemble(Layers{OuterRoot: root, AgentName: "x"}); err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("expected outside-root error, got %v", err)
+	}
+
+	// Nor through a symlink planted inside it
+	secret := filepath.Join(t.TempDir(), "secrets.key")
+	os.WriteFile(secret, []byte("KEY"), 0600)
+	os.Symlink(secret, filepath.Join(root, "shared", "key.md"))
+	writeLayer(t, root, "base", "@include ../shared/key.md\n")
+	if _, err := Assemble(Layers{OuterRoot: root, AgentName: "x"}); err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("expected outside-root error for a symlink escape, got %v", err)
+	}
+
+	// Self-include is bounded
+	writeLayer(t, root, "base", "@include AGENTS.md\n")
+	if _, err := Assemble(Layers{OuterRoot: root, AgentName: "x"}); err == nil {
+		t.Error("expected error for recursive include")
+	}
+}
+
+func TestAssembleModes(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"", "Outer." + LayerSep + "Inner.\n"},
+		{"append", "Outer." + LayerSep + "Inner.\n"},
+		{"prepend", "Inner." + LayerSep + "Outer.\n"},
+		{"replace", "Inner.\n"},
+	}
+	for _, tt := range tests {
+		t.Run("mode="+tt.mode, func(t *testing.T) {
+			outer, inner := t.TempDir(), t.TempDir()
+			writeLayer(t, outer, "roles/ops", "Outer.\n")
+			content := "Inner.\n"
+			if tt.mode != "" {
+				content = "---\nmode: " + tt.mode + "\n---\n" + content
+			}
+			writeLayer(t, inner, "roles/ops", content)
+
+			result, err := Assemble(Layers{OuterRoot: outer, InnerRoot: inner, Roles: []string{"ops"}, AgentName: "x"})
+			if err != nil {
+				t.Fatalf("Assemble failed: %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("got %q, want %q", result, tt.want)
+			}
+		})
+	}
+
+	outer, inner := t.TempDir(), t.TempDir()
+	writeLayer(t, outer, "base", "Outer.\n")
+	writeLayer(t, inner, "base", "---\nmode: merge\n---\nInner.\n")
+	if _, err := Assemble(Layers{OuterRoot: outer, InnerRoot: inner, AgentName: "x"}); err == nil {
+		t.Error("expected error for invalid mode")
+	}
+}
+
+func TestAssembleSectionOverride(t *testing.T) {
+	outer, inner := t.TempDir(), t.TempDir()
+	writeLayer(t, outer, "roles/ops", "Role intro.\n@section escalation\nEscalate to concierge.\n@end\nRole outro.\n")
+	writeLayer(t, inner, "roles/ops", "@section escalation\nEscalate to sysadmin first.\n@end\n")
+	writeLayer(t, outer, "agents/x", "Agent notes.\n")
+
+	layers := Layers{OuterRoot: outer, InnerRoot: inner, Roles: []string{"ops"}, AgentName: "x"}
+	r, err := AssembleResult(layers)
+	if err != nil {
+		t.Fatalf("AssembleResult failed: %v", err)
+	}
+	want := "Role intro.\n\nEscalate to sysadmin first.\n\nRole outro." + AgentLayerSep + "Agent notes.\n"
+	if got := r.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if strings.Contains(r.String(), "concierge") {
+		t.Error("overridden section content still present")
+	}
+	innerFile := filepath.Join(inner, "roles", "ops", "AGENTS.md")
+	if !strings.Contains(r.Explain(), "<!-- section escalation: "+innerFile+" -->") {
+		t.Errorf("explain missing override provenance:\n%s", r.Explain())
+	}
+
+	// Directives inside fenced code blocks are content
+	writeLayer(t, outer, "agents/x", "Example:\n```\n@section demo\n@include nope.md\n```\n")
+	if r, err := AssembleResult(layers); err != nil || !strings.Contains(r.String(), "@section demo\n@include nope.md") {
+		t.Errorf("fenced directives: err %v, got %q", err, r)
+	}
+
+	writeLayer(t, outer, "base", "@section open\nNo end.\n")
+	if _, err := Assemble(layers); err == nil {
+		t.Error("expected error for unterminated section")
+	}
+}
+
+func TestAssembleLayerSep(t *testing.T) {
+	root := t.TempDir()
+	writeLayer(t, root, "base", "Base.\n\n---\n\nStill base.\n"+agentMarker+"\n")
+	writeLayer(t, root, "roles/ops", "Role.\n")
+	writeLayer(t, root, "agents/x", "Agent.\n"+layerMarker+"\nNot a layer.\n")
+	layers := Layers{OuterRoot: root, Roles: []string{"ops"}, AgentName: "x", InlineInstructions: "Inline."}
+
+	result, err := Assemble(layers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared, agent := SplitLayers(result)
+	if len(shared) != 2 || !strings.HasPrefix(shared[1], "Role.") {
+		t.Errorf("shared = %q, want base and role: a rule or a planted marker is not a layer boundary", shared)
+	}
+	if agent != "Agent.\n\nNot a layer."+LayerSep+"Inline." {
+		t.Errorf("agent = %q, want the agent layer and inline instructions", agent)
+	}
+
+	// Without shared layers, everything is the agent's
+	os.RemoveAll(filepath.Join(root, "base"))
+	os.RemoveAll(filepath.Join(root, "roles"))
+	if result, err = Assemble(layers); err != nil {
+		t.Fatal(err)
+	}
+	if shared, agent := SplitLayers(result); len(shared) != 0 || !strings.HasSuffix(agent, "Inline.") {
+		t.Errorf("shared = %q, agent = %q; want only agent sections", shared, agent)
+	}
+}
@@ -19,9 +19,11 @@ func PlanProvision(cfg *config.Config) []string {
 	cmds = append(cmds, "groupadd -f trusted")
 
 	// Lock down /etc/con/env — only root should read it.
-	// Agents receive env vars via systemd EnvironmentFile= injection.
+	// Agents receive env vars via systemd EnvironmentFile= injection of their
+	// scoped file in /etc/con/env.d/ (see WriteScopedEnv).
 	cmds = append(cmds, "chmod 600 /etc/con/env 2>/dev/null || true")
 	cmds = append(cmds, "chown root:root /etc/con/env 2>/dev/null || true")
+	cmds = append(cmds, fmt.Sprintf("install -d -o root -g root -m 700 %s", config.ScopedEnvDir))
 
 	// Can-task groups (who can write to whose inbox)
 	for _, a := range cfg.Agents {
@@ -68,6 +70,9 @@ func PlanProvision(cfg *config.Config) []string {
 	cmds = append(cmds, "install -d -m 755 /srv/con/scopes")
 	cmds = append(cmds, "install -d -m 755 /srv/con/policy")
 	cmds = append(cmds, "install -d -m 755 /srv/con/ledger")
+	cmds = append(cmds, "install -d -o root -g root -m 755 /srv/con/skills") // skill hash manifests
+	cmds = append(cmds, "install -d -o root -g root -m 755 /var/lib/con")     // healthcheck state
+	cmds = append(cmds, "install -d -o root -g root -m 755 /srv/con/alerts")  // discord alert sink
 
 	// Per-agent dirs
 	for _, a := range cfg.Agents {
@@ -78,20 +83,22 @@ func PlanProvision(cfg *config.Config) []string {
 			fmt.Sprintf("install -d -o %s -g agents -m 700 %s/inbox", user, base),
 			fmt.Sprintf("install -d -o %s -g agents -m 700 %s/outbox", user, base),
 			fmt.Sprintf("install -d -o %s -g agents -m 700 %s/workspace", user, base),
+			fmt.Sprintf("install -d -o %s -g agents -m 700 %s/workspace/notes", user, base), // agent-writable, untrusted
 			fmt.Sprintf("install -d -o %s -g agents -m 700 %s/sessions", user, base),
 			fmt.Sprintf("install -d -o %s -g agents -m 700 %s/processed", user, base),
+			fmt.Sprintf("install -d -o %s -g agents -m 700 %s/failed", user, base), // tasks of killed sessions
 		)
 	}
 
-	// 4. ACLs — default tasking permissions for minimal install
+	// 4. ACLs — tasking permissions (built-in concierge <-> sysadmin + can_task)
 	// With mode 700, agents need explicit traverse (--x) on each other's base dirs
 	// to reach the inbox subdirectory.
-	// Concierge can task sysadmin: traverse base + rwx inbox
-	cmds = append(cmds, "setfacl -m u:a-concierge:x /srv/con/agents/sysadmin/")
-	cmds = append(cmds, "setfacl -m u:a-concierge:rwx /srv/con/agents/sysadmin/inbox/")
-	// Sysadmin can task concierge: traverse base + rwx inbox
-	cmds = append(cmds, "setfacl -m u:a-sysadmin:x /srv/con/agents/concierge/")
-	cmds = append(cmds, "setfacl -m u:a-sysadmin:rwx /srv/con/agents/concierge/inbox/")
+	for _, a := range cfg.Agents {
+		for _, target := range cfg.TaskTargets(a.Name) {
+			cmds = append(cmds, fmt.Sprintf("setfacl -m u:a-%s:x /srv/con/agents/%s/", a.Name, target))
+			cmds = append(cmds, fmt.Sprintf("setfacl -m u:a-%s:rwx /srv/con/agents/%s/inbox/", a.Name, target))
+		}
+	}
 
 	// Sysadmin write access to inner config and contracts (for commissioning)
 	cmds = append(cmds, "setfacl -m u:a-sysadmin:rwx /srv/con/config/agents/")
@@ -123,11 +130,14 @@ func PlanProvision(cfg *config.Config) []string {
 	// 7. Initialize /srv/con/ as git repo with .gitignore
 	cmds = append(cmds, `cd /srv/con && git init && git config user.name 'con' && git config user.email 'con@localhost' && cat > .gitignore << 'GITIGNORE'
 agents/*/workspace/
+agents/*/run.json*
 artifacts/
+alerts/
 *.env
 *.pem
 *.key
 GITIGNORE
+git rm -r -q --cached --ignore-unmatch 'agents/*/run.json*'
 git add -A && git commit -m 'initial state' --allow-empty || true`)
 
 	// 8. Outer inbox watcher — triggers concierge when files land in /srv/con/inbox
@@ -151,7 +161,6 @@ Description=ConspiracyOS outer inbox -> concierge inbox
 Type=oneshot
 User=a-concierge
 ExecStart=/usr/local/bin/con route-inbox
-EnvironmentFile=-/etc/con/env
 EOF`)
 
 	cmds = append(cmds, "systemctl enable --now con-outer-inbox.path")
@@ -79,6 +79,73 @@ func TestProvisionACLs(t *testing.T) {
 	}
 }
 
+func TestProvisionCanTaskACLs(t *testing.T) {
+	cfg := &config.Config{
+		System: config.SystemConfig{Name: "test"},
+		Agents: []config.AgentConfig{
+			{Name: "concierge", Tier: "operator", Mode: "on-demand"},
+			{Name: "sysadmin", Tier: "operator", Mode: "on-demand"},
+			{Name: "researcher", Tier: "worker", Mode: "on-demand", CanTask: []string{"writer", "researcher", "ghost"}},
+			{Name: "writer", Tier: "worker", Mode: "on-demand"},
+		},
+	}
+
+	var acls []string
+	for _, c := range PlanProvision(cfg) {
+		if strings.HasPrefix(c, "setfacl -m u:a-") && strings.Contains(c, "/srv/con/agents/") {
+			acls = append(acls, c)
+		}
+	}
+	has := func(cmd string) bool {
+		for _, c := range acls {
+			if c == cmd {
+				return true
+			}
+		}
+		return false
+	}
+
+	// can_task grants traverse on the target's base dir and rwx on its inbox
+	for _, want := range []string{
+		"setfacl -m u:a-researcher:x /srv/con/agents/writer/",
+		"setfacl -m u:a-researcher:rwx /srv/con/agents/writer/inbox/",
+	} {
+		if !has(want) {
+			t.Errorf("missing %q in %v", want, acls)
+		}
+	}
+
+	// Nothing beyond the declared pairs: no reverse grant, no self grant,
+	// no grant for unknown agents, and workers get no default targets.
+	for _, c := range acls {
+		for _, bad := range []string{"u:a-writer:", "agents/researcher/", "ghost"} {
+			if strings.Contains(c, bad) {
+				t.Errorf("unexpected ACL %q", c)
+			}
+		}
+	}
+	for _, c := range acls {
+		if strings.Contains(c, "u:a-researcher:") && !strings.Contains(c, "agents/writer/") {
+			t.Errorf("researcher granted beyond can_task: %q", c)
+		}
+	}
+}
+
+func TestProvisionGitignoresRunState(t *testing.T) {
+	cmds := PlanProvision(&config.Config{System: config.SystemConfig{Name: "test"}})
+	for _, c := range cmds {
+		if strings.Contains(c, "cat > .gitignore") {
+			// run.json holds the in-flight PID and task; installs that already
+			// committed it stop tracking it on the next bootstrap
+			if !strings.Contains(c, "\nagents/*/run.json*\n") || !strings.Contains(c, "git rm -r -q --cached --ignore-unmatch 'agents/*/run.json*'") {
+				t.Errorf("run state not kept out of /srv/con history:\n%s", c)
+			}
+			return
+		}
+	}
+	t.Error("expected the /srv/con .gitignore to be written")
+}
+
 func TestProvisionContractInstallation(t *testing.T) {
 	cfg := &config.Config{
 		System: config.SystemConfig{Name: "test"},
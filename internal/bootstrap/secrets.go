@@ -0,0 +1,60 @@
+package bootstrap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ConspiracyOS/agent-runner/internal/config"
+)
+
+// ScopeEnv returns the lines of an env file whose keys are in keys.
+// Line order is preserved; comments and ungranted keys are dropped.
+func ScopeEnv(env string, keys []string) string {
+	allowed := map[string]bool{}
+	for _, k := range keys {
+		allowed[k] = true
+	}
+
+	var b strings.Builder
+	for _, line := range strings.Split(env, "\n") {
+		key, _, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || !allowed[strings.TrimSpace(key)] {
+			continue
+		}
+		b.WriteString(strings.TrimSpace(line))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// WriteScopedEnv splits the shared env file into one file per agent under dir,
+// each containing only the secrets returned by cfg.AgentSecrets plus the
+// non-secret settings in config.SettingsEnv. Files are
+// mode 0600 and owned by the caller (root) — systemd reads them before
+// dropping privileges, so the agent never needs read access.
+func WriteScopedEnv(cfg *config.Config, envPath, dir string) error {
+	data, err := os.ReadFile(envPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading %s: %w", envPath, err)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("creating %s: %w", dir, err)
+	}
+
+	for _, a := range cfg.Agents {
+		keys := append(append([]string{}, config.SettingsEnv...), cfg.AgentSecrets(a.Name)...)
+		scoped := ScopeEnv(string(data), keys)
+		path := filepath.Join(dir, a.Name)
+		if err := os.WriteFile(path, []byte(scoped), 0600); err != nil {
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
+		// WriteFile keeps the mode of an existing file — enforce it
+		if err := os.Chmod(path, 0600); err != nil {
+			return fmt.Errorf("chmod %s: %w", path, err)
+		}
+	}
+	return nil
+}
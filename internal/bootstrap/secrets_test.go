@@ -0,0 +1,93 @@
+package bootstrap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ConspiracyOS/agent-runner/internal/config"
+)
+
+func TestScopeEnv(t *testing.T) {
+	env := "CON_API_KEY=sk-shared\nCON_OFFICER_KEY=sk-officer\n# comment\nTS_AUTHKEY=tskey\n"
+
+	got := ScopeEnv(env, []string{"CON_API_KEY"})
+	if got != "CON_API_KEY=sk-shared\n" {
+		t.Errorf("ScopeEnv = %q, want only CON_API_KEY", got)
+	}
+	if strings.Contains(got, "sk-officer") || strings.Contains(got, "tskey") {
+		t.Error("scoped env must not contain ungranted secrets")
+	}
+}
+
+func TestWriteScopedEnv(t *testing.T) {
+	dir := t.TempDir()
+	envPath := filepath.Join(dir, "env")
+	os.WriteFile(envPath, []byte("CON_API_KEY=sk-shared\nCON_OFFICER_KEY=sk-officer\nGITHUB_TOKEN=ghp\nCON_SYSTEM_NAME=acme\nCON_CONFIG=/etc/con/acme.toml\n"), 0600)
+
+	cfg := &config.Config{
+		Base: config.BaseConfig{
+			APIKeyEnv: "CON_API_KEY",
+			Officer:   config.TierConfig{APIKeyEnv: "CON_OFFICER_KEY"},
+		},
+		Secrets: map[string]config.SecretConfig{
+			"GITHUB_TOKEN": {Agents: []string{"sysadmin"}},
+		},
+		Agents: []config.AgentConfig{
+			{Name: "sysadmin", Tier: "operator"},
+			{Name: "strategist", Tier: "officer"},
+			{Name: "researcher", Tier: "worker"},
+		},
+	}
+
+	envDir := filepath.Join(dir, "env.d")
+	if err := WriteScopedEnv(cfg, envPath, envDir); err != nil {
+		t.Fatalf("WriteScopedEnv failed: %v", err)
+	}
+
+	tests := []struct {
+		agent   string
+		want    []string
+		notWant []string
+	}{
+		{"sysadmin", []string{"sk-shared", "ghp", "CON_SYSTEM_NAME=acme"}, []string{"sk-officer"}},
+		{"strategist", []string{"sk-officer", "CON_SYSTEM_NAME=acme"}, []string{"sk-shared", "ghp"}},
+		{"researcher", []string{"sk-shared", "CON_SYSTEM_NAME=acme", "CON_CONFIG=/etc/con/acme.toml"}, []string{"sk-officer", "ghp"}},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(envDir, tt.agent)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("reading %s: %v", path, err)
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(string(data), w) {
+				t.Errorf("%s env should contain %q, got %q", tt.agent, w, data)
+			}
+		}
+		for _, nw := range tt.notWant {
+			if strings.Contains(string(data), nw) {
+				t.Errorf("%s env must not contain %q, got %q", tt.agent, nw, data)
+			}
+		}
+		info, _ := os.Stat(path)
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("%s env mode = %o, want 600", tt.agent, info.Mode().Perm())
+		}
+	}
+}
+
+func TestWriteScopedEnvMissingSource(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{Agents: []config.AgentConfig{{Name: "concierge"}}}
+
+	envDir := filepath.Join(dir, "env.d")
+	if err := WriteScopedEnv(cfg, filepath.Join(dir, "missing"), envDir); err != nil {
+		t.Fatalf("missing /etc/con/env should not be an error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(envDir, "concierge"))
+	if err != nil || len(data) != 0 {
+		t.Errorf("expected empty scoped env file, got %q (err %v)", data, err)
+	}
+}
@@ -16,6 +16,7 @@ After=network.target
 
 [Service]
 Type=oneshot
+EnvironmentFile=-/etc/con/env
 ExecStart=/usr/local/bin/con healthcheck
 ExecStartPost=-/usr/local/bin/con-status-page
 `
@@ -102,9 +103,14 @@ func GenerateUnits(agent config.AgentConfig) map[string]string {
 	user := "a-" + agent.Name
 	svcName := "con-" + agent.Name
 	hardening := serviceHardening(agent)
+	envFile := config.ScopedEnvPath(agent.Name)
+	credDir := config.CredentialsPath(agent.Name)
 
 	// Service unit (always generated)
-	// EnvironmentFile loads API keys from /etc/con/env (written at container start)
+	// EnvironmentFile loads only this agent's secrets from /etc/con/env.d/<name>
+	// (split from /etc/con/env by bootstrap and on every boot).
+	// Secrets from the encrypted store are decrypted by root (+) into a tmpfs
+	// credentials dir before the run and removed afterwards.
 	svc := fmt.Sprintf(`[Unit]
 Description=ConspiracyOS agent: %s
 After=network.target
@@ -116,11 +122,14 @@ Group=agents
 ExecStart=/usr/local/bin/con run %s
 WorkingDirectory=/srv/con/agents/%s/workspace
 Environment=HOME=/home/%s
-EnvironmentFile=-/etc/con/env
+EnvironmentFile=-%s
+Environment=CON_CREDENTIALS_DIR=%s
+ExecStartPre=+/usr/local/bin/con secret load %s
+ExecStopPost=+/usr/local/bin/con secret unload %s
 %s
 [Install]
 WantedBy=multi-user.target
-`, agent.Name, user, agent.Name, agent.Name, user, hardening)
+`, agent.Name, user, agent.Name, agent.Name, user, envFile, credDir, agent.Name, agent.Name, hardening)
 
 	units[svcName+".service"] = svc
 
@@ -152,13 +161,16 @@ Group=agents
 ExecStart=/usr/local/bin/con run %s --continuous
 WorkingDirectory=/srv/con/agents/%s/workspace
 Environment=HOME=/home/%s
-EnvironmentFile=-/etc/con/env
+EnvironmentFile=-%s
+Environment=CON_CREDENTIALS_DIR=%s
+ExecStartPre=+/usr/local/bin/con secret load %s
+ExecStopPost=+/usr/local/bin/con secret unload %s
 Restart=on-failure
 RestartSec=5
 %s
 [Install]
 WantedBy=multi-user.target
-`, agent.Name, user, agent.Name, agent.Name, user, hardening)
+`, agent.Name, user, agent.Name, agent.Name, user, envFile, credDir, agent.Name, agent.Name, hardening)
 		units[svcName+".service"] = svc
 
 	case "cron":
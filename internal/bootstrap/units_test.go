@@ -36,6 +36,21 @@ func TestGenerateOnDemandUnits(t *testing.T) {
 	if !strings.Contains(svcUnit, "ExecStart=/usr/local/bin/con run concierge") {
 		t.Error("service should exec con run")
 	}
+	if !strings.Contains(svcUnit, "EnvironmentFile=-/etc/con/env.d/concierge") {
+		t.Error("service should load the agent's scoped env file")
+	}
+	if strings.Contains(svcUnit, "EnvironmentFile=-/etc/con/env\n") {
+		t.Error("service must not load the shared /etc/con/env")
+	}
+	if !strings.Contains(svcUnit, "ExecStartPre=+/usr/local/bin/con secret load concierge") {
+		t.Error("service should materialize store secrets as root before the run")
+	}
+	if !strings.Contains(svcUnit, "ExecStopPost=+/usr/local/bin/con secret unload concierge") {
+		t.Error("service should remove materialized secrets after the run")
+	}
+	if !strings.Contains(svcUnit, "Environment=CON_CREDENTIALS_DIR=/run/con/credentials/concierge") {
+		t.Error("service should point CON_CREDENTIALS_DIR at the agent's credentials dir")
+	}
 }
 
 func TestServiceHardeningWorker(t *testing.T) {
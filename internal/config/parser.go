@@ -3,9 +3,14 @@ package config
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/BurntSushi/toml"
+
+	"github.com/ConspiracyOS/agent-runner/internal/secrets"
 )
 
 // legacyConfig handles the old [defaults.<tier>] format for backwards compatibility.
@@ -63,6 +68,18 @@ func migrateLegacyDefaults(path string, cfg *Config) {
 	}
 }
 
+// SettingsEnv lists the non-secret variables of /etc/con/env that
+// configure con itself (CON_CONFIG selects the config file, the rest
+// override it in applyENVOverrides). Every agent unit gets them, so `con
+// run` sees the config bootstrap saw.
+var SettingsEnv = []string{
+	"CON_CONFIG",
+	"CON_SYSTEM_NAME",
+	"CON_INFRA_TAILSCALE_HOSTNAME",
+	"CON_INFRA_TAILSCALE_LOGIN_SERVER",
+	"CON_SSH_AUTHORIZED_KEYS",
+}
+
 func applyENVOverrides(cfg *Config) {
 	if v := os.Getenv("CON_SYSTEM_NAME"); v != "" {
 		cfg.System.Name = v
@@ -103,6 +120,9 @@ func applyDefaults(cfg *Config) {
 	if cfg.Contracts.System.HealthcheckInterval == "" {
 		cfg.Contracts.System.HealthcheckInterval = "60s"
 	}
+	if cfg.Contracts.System.EscalationCooldown == "" {
+		cfg.Contracts.System.EscalationCooldown = "30m"
+	}
 	if cfg.Dashboard.Port == 0 {
 		cfg.Dashboard.Port = 8080
 	}
@@ -111,6 +131,10 @@ func applyDefaults(cfg *Config) {
 	}
 }
 
+// OutputReserve is the part of an agent's context window, in tokens, kept
+// free for the model's response; the rest is the prompt budget.
+const OutputReserve = 8192
+
 func validate(cfg *Config) error {
 	validTiers := map[string]bool{"officer": true, "operator": true, "worker": true}
 	validModes := map[string]bool{"on-demand": true, "continuous": true, "cron": true}
@@ -124,6 +148,9 @@ func validate(cfg *Config) error {
 		if a.Name == "" {
 			return fmt.Errorf("agent[%d]: name is required", i)
 		}
+		if !ValidAgentName(a.Name) {
+			return fmt.Errorf("agent %q: invalid name (use a-z, 0-9 and -)", a.Name)
+		}
 		if a.Tier != "" && !validTiers[a.Tier] {
 			return fmt.Errorf("agent %q: invalid tier %q (must be officer/operator/worker)", a.Name, a.Tier)
 		}
@@ -142,6 +169,9 @@ func validate(cfg *Config) error {
 		if a.Provider != "" && !validProviders[a.Provider] {
 			return fmt.Errorf("agent %q: invalid provider %q (must be openrouter/anthropic/openai/claude_code)", a.Name, a.Provider)
 		}
+		if a.ContextWindow < 0 || (a.ContextWindow > 0 && a.ContextWindow <= OutputReserve) {
+			return fmt.Errorf("agent %q: context_window %d leaves no room for a prompt (must exceed %d)", a.Name, a.ContextWindow, OutputReserve)
+		}
 	}
 
 	// Validate runner-provider compatibility at the resolved level
@@ -152,9 +182,91 @@ func validate(cfg *Config) error {
 		}
 	}
 
+	agentNames := map[string]bool{}
+	for _, a := range cfg.Agents {
+		agentNames[a.Name] = true
+	}
+
+	// can_task must reference agents that exist
+	for _, a := range cfg.Agents {
+		for _, t := range a.CanTask {
+			if !agentNames[t] {
+				return fmt.Errorf("agent %q: can_task references unknown agent %q", a.Name, t)
+			}
+		}
+	}
+
+	// Secrets must be granted to agents and tiers that exist
+	for name, s := range cfg.Secrets {
+		if !secrets.ValidName(name) {
+			return fmt.Errorf("secret %q: invalid name (use A-Z, 0-9 and _)", name)
+		}
+		for _, a := range s.Agents {
+			if !agentNames[a] {
+				return fmt.Errorf("secret %q: unknown agent %q", name, a)
+			}
+		}
+		for _, t := range s.Tiers {
+			if !validTiers[t] {
+				return fmt.Errorf("secret %q: invalid tier %q (must be officer/operator/worker)", name, t)
+			}
+		}
+	}
+
+	if d, err := time.ParseDuration(cfg.Contracts.System.EscalationCooldown); err != nil || d < 0 {
+		return fmt.Errorf("contracts.system: invalid escalation_cooldown %q", cfg.Contracts.System.EscalationCooldown)
+	}
+
+	for i, a := range cfg.Alerts {
+		if err := validateAlert(a); err != nil {
+			return fmt.Errorf("alerts[%d] (%s): %w", i, a.Type, err)
+		}
+	}
+
+	return nil
+}
+
+// validateAlert checks that an alert sink has the fields its type needs.
+func validateAlert(a AlertConfig) error {
+	switch a.Type {
+	case "webhook":
+		if a.URL == "" {
+			return fmt.Errorf("url is required")
+		}
+	case "smtp":
+		if a.SMTPAddr == "" || a.From == "" || len(a.To) == 0 {
+			return fmt.Errorf("smtp_addr, from and to are required")
+		}
+	case "file", "socket":
+		if a.Path == "" {
+			return fmt.Errorf("path is required")
+		}
+	case "discord":
+	default:
+		return fmt.Errorf("invalid type (must be webhook/smtp/file/socket/discord)")
+	}
+	if a.Template != "" {
+		if _, err := template.New("alert").Parse(a.Template); err != nil {
+			return fmt.Errorf("template: %w", err)
+		}
+	}
+	if a.Dedup != "" {
+		if d, err := time.ParseDuration(a.Dedup); err != nil || d <= 0 {
+			return fmt.Errorf("invalid dedup window %q", a.Dedup)
+		}
+	}
 	return nil
 }
 
+var validAgentName = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// ValidAgentName reports whether name is a valid agent name ([a-z0-9-]+).
+// Names become Linux users and paths, and are substituted into contract
+// commands, so nothing else is allowed.
+func ValidAgentName(name string) bool {
+	return validAgentName.MatchString(name)
+}
+
 // validateRunnerProvider checks that a runner and provider are compatible.
 func validateRunnerProvider(agentName, runner, provider string) error {
 	switch runner {
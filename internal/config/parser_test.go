@@ -1,8 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -186,6 +188,14 @@ mode = "on-demand"
 	if err == nil {
 		t.Error("expected validation error for agent without name")
 	}
+
+	// A context window no larger than the output reserve leaves no prompt budget
+	for _, window := range []string{"8192", "-1"} {
+		os.WriteFile(path, []byte("[[agents]]\nname = \"a\"\ncontext_window = "+window+"\n"), 0644)
+		if _, err := Parse(path); err == nil {
+			t.Errorf("expected validation error for context_window = %s", window)
+		}
+	}
 }
 
 func TestValidateRunnerProviderCompat(t *testing.T) {
@@ -317,3 +327,259 @@ tier = "operator"
 		t.Errorf("expected default max_sessions 1, got %d", agent.MaxSessions)
 	}
 }
+
+func TestAgentSecretsScoping(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "con.toml")
+	os.WriteFile(path, []byte(`
+[base]
+provider = "openrouter"
+api_key_env = "CON_API_KEY"
+
+[base.officer]
+api_key_env = "CON_OFFICER_KEY"
+
+[secrets.GITHUB_TOKEN]
+agents = ["sysadmin"]
+
+[secrets.SEARCH_API_KEY]
+tiers = ["officer", "worker"]
+
+[[agents]]
+name = "sysadmin"
+tier = "operator"
+
+[[agents]]
+name = "strategist"
+tier = "officer"
+
+[[agents]]
+name = "researcher"
+tier = "worker"
+`), 0644)
+
+	cfg, err := Parse(path)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	tests := []struct {
+		agent string
+		want  []string
+	}{
+		{"sysadmin", []string{"CON_API_KEY", "CON_OPENROUTER_API_KEY", "GITHUB_TOKEN"}},
+		{"strategist", []string{"CON_OFFICER_KEY", "CON_OPENROUTER_API_KEY", "SEARCH_API_KEY"}},
+		{"researcher", []string{"CON_API_KEY", "CON_OPENROUTER_API_KEY", "SEARCH_API_KEY"}},
+		{"missing", nil},
+	}
+	for _, tt := range tests {
+		got := cfg.AgentSecrets(tt.agent)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("AgentSecrets(%q) = %v, want %v", tt.agent, got, tt.want)
+		}
+	}
+}
+
+func TestSecretsValidation(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name string
+		toml string
+	}{
+		{"unknown agent", `[secrets.TOKEN]
+agents = ["ghost"]
+
+[[agents]]
+name = "a"`},
+		{"invalid tier", `[secrets.TOKEN]
+tiers = ["admin"]
+
+[[agents]]
+name = "a"`},
+		{"path in name", `[secrets."../x"]
+agents = ["a"]
+
+[[agents]]
+name = "a"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "-")+".toml")
+			os.WriteFile(path, []byte(tt.toml), 0644)
+			if _, err := Parse(path); err == nil {
+				t.Error("expected validation error, got nil")
+			}
+		})
+	}
+}
+
+func TestTaskTargets(t *testing.T) {
+	cfg := &Config{Agents: []AgentConfig{
+		{Name: "concierge", CanTask: []string{"researcher", "sysadmin"}},
+		{Name: "sysadmin"},
+		{Name: "researcher", CanTask: []string{"researcher"}},
+	}}
+
+	if got := strings.Join(cfg.TaskTargets("concierge"), ","); got != "sysadmin,researcher" {
+		t.Errorf("concierge targets = %q, want sysadmin,researcher", got)
+	}
+	if got := strings.Join(cfg.TaskTargets("sysadmin"), ","); got != "concierge" {
+		t.Errorf("sysadmin targets = %q, want concierge", got)
+	}
+	if got := cfg.TaskTargets("researcher"); len(got) != 0 {
+		t.Errorf("researcher should not target itself, got %v", got)
+	}
+	if got := cfg.TaskTargets("ghost"); got != nil {
+		t.Errorf("unknown agent targets = %v, want nil", got)
+	}
+}
+
+func TestCanTaskValidation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "con.toml")
+	os.WriteFile(path, []byte(`[[agents]]
+name = "a"
+can_task = ["ghost"]
+`), 0644)
+	if _, err := Parse(path); err == nil {
+		t.Error("expected can_task validation error, got nil")
+	}
+}
+
+func TestAgentNameValidation(t *testing.T) {
+	for _, name := range []string{"Researcher", "web_scraper", "x'; id; '", "a b"} {
+		path := filepath.Join(t.TempDir(), "con.toml")
+		os.WriteFile(path, []byte(fmt.Sprintf("[[agents]]\nname = %q\n", name)), 0644)
+		if _, err := Parse(path); err == nil {
+			t.Errorf("agent name %q: expected validation error, got nil", name)
+		}
+	}
+}
+
+func TestParseEnv(t *testing.T) {
+	env := ParseEnv("# comment\nCON_API_KEY=sk-123\n\nQUOTED=\"a b\"\nSINGLE='c'\nnot a pair\n")
+
+	if env["CON_API_KEY"] != "sk-123" {
+		t.Errorf("CON_API_KEY = %q, want sk-123", env["CON_API_KEY"])
+	}
+	if env["QUOTED"] != "a b" {
+		t.Errorf("QUOTED = %q, want %q", env["QUOTED"], "a b")
+	}
+	if env["SINGLE"] != "c" {
+		t.Errorf("SINGLE = %q, want c", env["SINGLE"])
+	}
+	if len(env) != 3 {
+		t.Errorf("expected 3 entries, got %d: %v", len(env), env)
+	}
+}
+
+func TestLookupSecret(t *testing.T) {
+	envDir := t.TempDir()
+	credDir := t.TempDir()
+	oldEnv, oldCred := ScopedEnvDir, CredentialsDir
+	ScopedEnvDir, CredentialsDir = envDir, credDir
+	defer func() { ScopedEnvDir, CredentialsDir = oldEnv, oldCred }()
+	t.Setenv("CON_CREDENTIALS_DIR", "")
+	t.Setenv("TEST_SECRET", "")
+
+	os.WriteFile(filepath.Join(envDir, "agent"), []byte("TEST_SECRET=from-env-file\n"), 0600)
+	if got := LookupSecret("agent", "TEST_SECRET"); got != "from-env-file" {
+		t.Errorf("expected scoped env file value, got %q", got)
+	}
+
+	os.MkdirAll(filepath.Join(credDir, "agent"), 0700)
+	os.WriteFile(filepath.Join(credDir, "agent", "TEST_SECRET"), []byte("from-store\n"), 0400)
+	if got := LookupSecret("agent", "TEST_SECRET"); got != "from-store" {
+		t.Errorf("expected credentials dir to take precedence, got %q", got)
+	}
+
+	t.Setenv("TEST_SECRET", "from-process-env")
+	if got := LookupSecret("agent", "TEST_SECRET"); got != "from-process-env" {
+		t.Errorf("expected process env to take precedence, got %q", got)
+	}
+}
+
+func TestContractVars(t *testing.T) {
+	cfg := &Config{}
+	cfg.Contracts.System = SystemContracts{DiskMinFreePct: 20, MemMinFreePct: 10, MaxLoadFactor: 1.5, MaxSessionMin: 45, HealthcheckInterval: "5m"}
+	vars := cfg.ContractVars()
+	want := map[string]string{
+		"contracts.system.disk_min_free_pct":    "20",
+		"contracts.system.max_load_factor":      "1.5",
+		"contracts.system.max_session_min":      "45",
+		"contracts.system.healthcheck_interval": "5m",
+	}
+	for k, v := range want {
+		if vars[k] != v {
+			t.Errorf("%s = %q, want %q", k, vars[k], v)
+		}
+	}
+}
+
+func TestAlertsValidation(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.toml")
+	os.WriteFile(valid, []byte(`[[alerts]]
+type = "webhook"
+url = "https://hooks.example.com/con"
+dedup = "30m"
+
+[[alerts]]
+type = "smtp"
+smtp_addr = "mail.example.com:587"
+from = "con@example.com"
+to = ["ops@example.com"]
+template = "{{.ContractID}} {{.Status}}"
+`), 0644)
+	cfg, err := Parse(valid)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(cfg.Alerts) != 2 || cfg.Alerts[1].To[0] != "ops@example.com" {
+		t.Errorf("Alerts = %+v", cfg.Alerts)
+	}
+
+	tests := []struct {
+		name string
+		toml string
+	}{
+		{"unknown type", "[[alerts]]\ntype = \"pager\""},
+		{"webhook without url", "[[alerts]]\ntype = \"webhook\""},
+		{"smtp without to", "[[alerts]]\ntype = \"smtp\"\nsmtp_addr = \"m:25\"\nfrom = \"a@b\""},
+		{"file without path", "[[alerts]]\ntype = \"file\""},
+		{"bad template", "[[alerts]]\ntype = \"discord\"\ntemplate = \"{{.Status\""},
+		{"bad dedup", "[[alerts]]\ntype = \"discord\"\ndedup = \"often\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "-")+".toml")
+			os.WriteFile(path, []byte(tt.toml), 0644)
+			if _, err := Parse(path); err == nil {
+				t.Error("expected validation error, got nil")
+			}
+		})
+	}
+}
+
+func TestEscalationCooldown(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "default.toml")
+	os.WriteFile(path, []byte("[system]\nname = \"test\"\n"), 0644)
+	cfg, err := Parse(path)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if cfg.Contracts.System.EscalationCooldown != "30m" {
+		t.Errorf("EscalationCooldown = %q, want default 30m", cfg.Contracts.System.EscalationCooldown)
+	}
+
+	bad := filepath.Join(dir, "bad.toml")
+	os.WriteFile(bad, []byte("[contracts.system]\nescalation_cooldown = \"soon\"\n"), 0644)
+	if _, err := Parse(bad); err == nil || !strings.Contains(err.Error(), "escalation_cooldown") {
+		t.Errorf("Parse(bad) error = %v, want escalation_cooldown error", err)
+	}
+}
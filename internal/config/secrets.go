@@ -0,0 +1,129 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ConspiracyOS/agent-runner/internal/secrets"
+)
+
+// ScopedEnvDir holds the per-agent env files written by bootstrap.
+// Each file carries only the secrets granted to that agent, so a worker
+// cannot read an officer's API key via EnvironmentFile= injection.
+var ScopedEnvDir = "/etc/con/env.d"
+
+// CredentialsDir holds per-agent directories of decrypted secrets from the
+// encrypted store (`con secret`), materialized on tmpfs at unit start.
+var CredentialsDir = "/run/con/credentials"
+
+// legacyKeyEnv maps a provider to the env var the PicoClaw runtime falls back
+// to when the agent's api_key_env is unset. Agents keep access to it so
+// existing deployments (CON_OPENROUTER_API_KEY in .env) continue to work.
+var legacyKeyEnv = map[string]string{
+	"openrouter": "CON_OPENROUTER_API_KEY",
+	"anthropic":  "CON_AUTH_ANTHROPIC",
+	"openai":     "CON_AUTH_OPENAI",
+}
+
+// ScopedEnvPath returns the path of an agent's scoped env file.
+func ScopedEnvPath(agent string) string {
+	return filepath.Join(ScopedEnvDir, agent)
+}
+
+// CredentialsPath returns the directory of an agent's decrypted credentials.
+func CredentialsPath(agent string) string {
+	return filepath.Join(CredentialsDir, agent)
+}
+
+// LookupSecret resolves a secret for an agent. Sources, in order:
+// the process environment (injected from the scoped env file by systemd),
+// the agent's credentials directory ($CON_CREDENTIALS_DIR when set by the
+// unit), and finally the scoped env file itself (readable only as root).
+func LookupSecret(agent, key string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	credDir := os.Getenv("CON_CREDENTIALS_DIR")
+	if credDir == "" {
+		credDir = CredentialsPath(agent)
+	}
+	if secrets.ValidName(key) {
+		if data, err := os.ReadFile(filepath.Join(credDir, key)); err == nil {
+			return strings.TrimRight(string(data), "\n")
+		}
+	}
+	return ReadEnvFile(ScopedEnvPath(agent))[key]
+}
+
+// AgentSecrets returns the sorted names of the secrets an agent may receive:
+// its resolved api_key_env, the legacy key for its provider, and every
+// [secrets] entry that grants access to the agent by name or tier.
+func (c *Config) AgentSecrets(name string) []string {
+	agent := c.ResolvedAgent(name)
+	if agent.Name == "" {
+		return nil
+	}
+
+	set := map[string]bool{}
+	if agent.APIKeyEnv != "" {
+		set[agent.APIKeyEnv] = true
+	}
+	if legacy := legacyKeyEnv[agent.Provider]; legacy != "" {
+		set[legacy] = true
+	}
+	for secret, grant := range c.Secrets {
+		if contains(grant.Agents, agent.Name) || (agent.Tier != "" && contains(grant.Tiers, agent.Tier)) {
+			set[secret] = true
+		}
+	}
+
+	names := make([]string, 0, len(set))
+	for n := range set {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseEnv parses KEY=VALUE lines in systemd EnvironmentFile format.
+// Blank lines and # comments are skipped; surrounding quotes are stripped.
+func ParseEnv(data string) map[string]string {
+	env := map[string]string{}
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env[strings.TrimSpace(key)] = value
+	}
+	return env
+}
+
+// ReadEnvFile reads and parses an env file. Returns an empty map if the file
+// cannot be read (missing, or not readable by the current user).
+func ReadEnvFile(path string) map[string]string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return map[string]string{}
+	}
+	return ParseEnv(string(data))
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
@@ -1,14 +1,18 @@
 package config
 
+import "strconv"
+
 // Config is the top-level ConspiracyOS configuration.
 type Config struct {
-	System    SystemConfig    `toml:"system"`
-	Infra     InfraConfig     `toml:"infra"`
-	Base      BaseConfig      `toml:"base"`
-	Network   NetworkConfig   `toml:"network"`
-	Contracts ContractsConfig `toml:"contracts"`
-	Dashboard DashboardConfig `toml:"dashboard"`
-	Agents    []AgentConfig   `toml:"agents"`
+	System    SystemConfig            `toml:"system"`
+	Infra     InfraConfig             `toml:"infra"`
+	Base      BaseConfig              `toml:"base"`
+	Network   NetworkConfig           `toml:"network"`
+	Contracts ContractsConfig         `toml:"contracts"`
+	Dashboard DashboardConfig         `toml:"dashboard"`
+	Secrets   map[string]SecretConfig `toml:"secrets"`
+	Alerts    []AlertConfig           `toml:"alerts"`
+	Agents    []AgentConfig           `toml:"agents"`
 }
 
 type SystemConfig struct {
@@ -61,6 +65,50 @@ type SystemContracts struct {
 	MaxLoadFactor       float64 `toml:"max_load_factor"`
 	MaxSessionMin       int     `toml:"max_session_min"`
 	HealthcheckInterval string  `toml:"healthcheck_interval"`
+	EscalationCooldown  string  `toml:"escalation_cooldown"` // min time between repeat escalations of the same failures
+}
+
+// ContractVars returns the config values contract YAML may reference as
+// ${contracts.system.<key>}, keyed by their dotted TOML path.
+func (c *Config) ContractVars() map[string]string {
+	s := c.Contracts.System
+	return map[string]string{
+		"system.name":                           c.System.Name,
+		"contracts.system.disk_min_free_pct":    strconv.Itoa(s.DiskMinFreePct),
+		"contracts.system.mem_min_free_pct":     strconv.Itoa(s.MemMinFreePct),
+		"contracts.system.max_load_factor":      strconv.FormatFloat(s.MaxLoadFactor, 'g', -1, 64),
+		"contracts.system.max_session_min":      strconv.Itoa(s.MaxSessionMin),
+		"contracts.system.healthcheck_interval": s.HealthcheckInterval,
+	}
+}
+
+// SecretConfig grants a named secret (a variable in /etc/con/env) to agents.
+// An agent receives the secret if it is listed by name or its tier is listed.
+type SecretConfig struct {
+	Agents []string `toml:"agents"`
+	Tiers  []string `toml:"tiers"`
+}
+
+// AlertConfig is a sink that is told when a contract check fails or
+// recovers, independently of the agents (which may be halted).
+type AlertConfig struct {
+	Type string `toml:"type"` // webhook | smtp | file | socket | discord
+
+	URL  string `toml:"url"`  // webhook: JSON POST target
+	Path string `toml:"path"` // file: append target; socket: unix socket
+
+	SMTPAddr    string   `toml:"smtp_addr"` // smtp: host:port
+	From        string   `toml:"from"`
+	To          []string `toml:"to"`
+	Username    string   `toml:"username"`
+	PasswordEnv string   `toml:"password_env"` // env var holding the SMTP password
+
+	// Template is a text/template over the alert fields; empty uses a
+	// one-line summary.
+	Template string `toml:"template"`
+	// Dedup suppresses repeats of the same contract/check/status within
+	// this window, e.g. "1h". Empty sends every transition.
+	Dedup string `toml:"dedup"`
 }
 
 type DashboardConfig struct {
@@ -75,6 +123,7 @@ type AgentConfig struct {
 	Roles        []string `toml:"roles"`
 	Groups       []string `toml:"groups"`
 	Scopes       []string `toml:"scopes"`
+	CanTask      []string `toml:"can_task"`
 	Mode         string   `toml:"mode"`
 	Cron         string   `toml:"cron"`
 	Runner       string   `toml:"runner"`
@@ -84,6 +133,10 @@ type AgentConfig struct {
 	MaxSessions  int      `toml:"max_sessions"`
 	Instructions string   `toml:"instructions"`
 
+	// ContextWindow overrides the model's context size in tokens when the
+	// built-in table does not know the model. It must exceed OutputReserve.
+	ContextWindow int `toml:"context_window"`
+
 	// Deprecated: use Runner. Kept for backwards compatibility.
 	CLI     string   `toml:"cli"`
 	CLIArgs []string `toml:"cli_args"`
@@ -141,6 +194,40 @@ func (c *Config) ResolvedAgent(name string) AgentConfig {
 	return AgentConfig{}
 }
 
+// defaultTaskTargets are the tasking paths every install gets: concierge
+// hands work to sysadmin, and sysadmin reports back to concierge.
+var defaultTaskTargets = map[string][]string{
+	"concierge": {"sysadmin"},
+	"sysadmin":  {"concierge"},
+}
+
+// TaskTargets returns the agents that the named agent can task (write to
+// their inbox): its can_task list plus the built-in defaults, restricted to
+// agents that exist in the config. Order is stable: defaults first.
+func (c *Config) TaskTargets(name string) []string {
+	exists := map[string]bool{}
+	var agent AgentConfig
+	for _, a := range c.Agents {
+		exists[a.Name] = true
+		if a.Name == name {
+			agent = a
+		}
+	}
+	if agent.Name == "" {
+		return nil
+	}
+
+	var targets []string
+	seen := map[string]bool{}
+	for _, t := range append(append([]string{}, defaultTaskTargets[name]...), agent.CanTask...) {
+		if exists[t] && t != name && !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
 // tierConfig returns the TierConfig for a given tier name.
 func (c *Config) tierConfig(tier string) TierConfig {
 	switch tier {
@@ -2,24 +2,92 @@ package contracts
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/ConspiracyOS/agent-runner/internal/quarantine"
+	"github.com/ConspiracyOS/agent-runner/internal/session"
 )
 
+// Dispatcher runs check actions. With a State it remembers what each
+// check's on_fail changed, so Recover restores only that.
+type Dispatcher struct {
+	Executor CommandExecutor
+	State    *State // nil: nothing is remembered and Recover has nothing to undo
+
+	// Agents resolves halts to per-agent units. Without it halts fail
+	// rather than guess which units to stop.
+	Agents []AgentInfo
+
+	// EscalationCooldown is the minimum time between repeat escalation
+	// tasks for the same check (see State.Escalate).
+	EscalationCooldown time.Duration
+}
+
+// AgentInfo is what halting needs to know about a configured agent.
+type AgentInfo struct {
+	Name string
+	Tier string // officer | operator | worker
+	Mode string // on-demand | continuous | cron
+}
+
+// triggers returns the path/timer units that start the agent's service.
+func (a AgentInfo) triggers() []string {
+	switch a.Mode {
+	case "on-demand":
+		return []string{"con-" + a.Name + ".path"}
+	case "cron":
+		return []string{"con-" + a.Name + ".timer", "con-" + a.Name + ".path"}
+	}
+	return nil
+}
+
 // DispatchAction executes the failure action for a failed check.
 // Returns the commands that were executed.
 func DispatchAction(ctx context.Context, action FailAction, scope string, executor CommandExecutor) ([]string, error) {
+	d := &Dispatcher{Executor: executor}
+	return d.dispatch(ctx, "", action, scope, nil)
+}
+
+// Fail executes ch's on_fail action and records what it changed.
+func (d *Dispatcher) Fail(ctx context.Context, contractID string, ch Check, scope string) ([]string, error) {
+	return d.dispatch(ctx, contractID, ch.OnFail, scope, d.checkState(contractID, ch.Name))
+}
+
+func (d *Dispatcher) checkState(contractID, name string) *CheckState {
+	if d.State == nil {
+		return nil
+	}
+	return d.State.check(contractID, name)
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, contractID string, action FailAction, scope string, st *CheckState) ([]string, error) {
 	var cmds []string
+	executor := d.Executor
 
 	switch action.Action {
 	case "halt_agents", "halt_workers":
-		// v1: halt_workers = halt_agents (tier differentiation deferred)
-		cmd := "systemctl stop 'con-*.service'"
-		cmds = append(cmds, cmd)
-		if _, _, err := executor.Execute(ctx, cmd); err != nil {
-			return cmds, fmt.Errorf("halt_agents: %w", err)
+		if len(d.Agents) == 0 {
+			return nil, fmt.Errorf("%s: no agents configured to halt", action.Action)
+		}
+		halted, holding, haltCmds, err := d.halt(ctx, action)
+		cmds = append(cmds, haltCmds...)
+		if st != nil {
+			// A retried halt finds the units a failed attempt stopped
+			// inactive: keep remembering them
+			for _, unit := range halted {
+				if !contains(st.Halted, unit) {
+					st.Halted = append(st.Halted, unit)
+				}
+			}
+			st.Holding = holding
+		}
+		if err != nil {
+			return cmds, fmt.Errorf("%s: %w", action.Action, err)
 		}
 
 	case "kill_session":
@@ -27,9 +95,15 @@ func DispatchAction(ctx context.Context, action FailAction, scope string, execut
 		if agent == "" {
 			return nil, fmt.Errorf("kill_session: cannot determine agent from scope %q", scope)
 		}
-		cmd := fmt.Sprintf("pkill -u a-%s picoclaw", agent)
-		cmds = append(cmds, cmd)
-		if _, _, err := executor.Execute(ctx, cmd); err != nil {
+		// Terminates the run recorded by the runner, whatever the runtime;
+		// an agent with no run in flight has nothing to kill
+		reason := action.Message
+		if contractID != "" {
+			reason = contractID + ": " + reason
+		}
+		steps, err := session.Kill(agent, reason)
+		cmds = append(cmds, steps...)
+		if err != nil && !errors.Is(err, session.ErrNoSession) {
 			return cmds, fmt.Errorf("kill_session: %w", err)
 		}
 
@@ -38,14 +112,10 @@ func DispatchAction(ctx context.Context, action FailAction, scope string, execut
 		if agent == "" {
 			return nil, fmt.Errorf("quarantine: cannot determine agent from scope %q", scope)
 		}
-		stopCmd := fmt.Sprintf("systemctl stop con-%s.service", agent)
-		aclCmd := fmt.Sprintf("setfacl -b /srv/con/agents/%s/inbox/", agent)
-		cmds = append(cmds, stopCmd, aclCmd)
-		if _, _, err := executor.Execute(ctx, stopCmd); err != nil {
-			return cmds, fmt.Errorf("quarantine stop: %w", err)
-		}
-		if _, _, err := executor.Execute(ctx, aclCmd); err != nil {
-			return cmds, fmt.Errorf("quarantine acl: %w", err)
+		qcmds, err := quarantine.Quarantine(ctx, executor, agent, action.Message, contractID)
+		cmds = append(cmds, qcmds...)
+		if err != nil {
+			return cmds, fmt.Errorf("quarantine: %w", err)
 		}
 
 	case "alert":
@@ -57,7 +127,7 @@ func DispatchAction(ctx context.Context, action FailAction, scope string, execut
 
 	// Escalate if target specified
 	if action.Escalate != "" {
-		if err := Escalate(action.Escalate, action.Message); err != nil {
+		if err := d.escalate(action.Escalate, contractID+"/failed/"+action.Message, action.Message); err != nil {
 			return cmds, fmt.Errorf("escalation to %s: %w", action.Escalate, err)
 		}
 	}
@@ -65,10 +135,171 @@ func DispatchAction(ctx context.Context, action FailAction, scope string, execut
 	return cmds, nil
 }
 
+// halt stops the agents selected by action: every agent for halt_agents,
+// worker-tier agents for halt_workers, minus action.Exclude. Their path and
+// timer units are disabled first so queued tasks do not restart them.
+// Returns the units it changed (for restart on recovery), every unit it
+// selected (held down while the check fails) and the commands run.
+func (d *Dispatcher) halt(ctx context.Context, action FailAction) (halted, holding, cmds []string, err error) {
+	excluded := map[string]bool{}
+	for _, name := range action.Exclude {
+		excluded[name] = true
+	}
+	var triggers, services []string
+	for _, a := range d.Agents {
+		if excluded[a.Name] || (action.Action == "halt_workers" && a.Tier != "worker") {
+			continue
+		}
+		triggers = append(triggers, a.triggers()...)
+		services = append(services, "con-"+a.Name+".service")
+	}
+
+	// A service another halt already stopped is not active now, but this
+	// check holds it down all the same
+	holding = append(append(holding, triggers...), services...)
+	if len(triggers) > 0 {
+		cmd := "systemctl disable --now " + strings.Join(triggers, " ")
+		cmds = append(cmds, cmd)
+		if _, _, err := d.Executor.Execute(ctx, cmd); err != nil {
+			return halted, holding, cmds, err
+		}
+		halted = append(halted, triggers...)
+	}
+	if len(services) > 0 {
+		// Only services that were running are restarted on recovery
+		halted = append(halted, activeUnits(ctx, d.Executor, services...)...)
+		cmd := "systemctl stop " + strings.Join(services, " ")
+		cmds = append(cmds, cmd)
+		if _, _, err := d.Executor.Execute(ctx, cmd); err != nil {
+			return halted, holding, cmds, err
+		}
+	}
+	return halted, holding, cmds, nil
+}
+
+// Recover executes ch's on_recover action for a check that passes again,
+// undoing only what its on_fail recorded in State.
+func (d *Dispatcher) Recover(ctx context.Context, contractID string, ch Check, scope string) ([]string, error) {
+	action := ch.OnRecover
+	st := d.checkState(contractID, ch.Name)
+	if st == nil {
+		st = &CheckState{}
+	}
+	var cmds []string
+
+	switch action.Action {
+	case "restart":
+		// Triggers were disabled by the halt and are re-enabled; services
+		// are started only if they were running when halted. A unit another
+		// failing check still holds down is left to that check's recovery.
+		var triggers, services []string
+		for _, unit := range st.Halted {
+			if holder := d.holder(st, unit); holder != nil {
+				if !contains(holder.Halted, unit) {
+					holder.Halted = append(holder.Halted, unit)
+				}
+				continue
+			}
+			if strings.HasSuffix(unit, ".service") {
+				services = append(services, unit)
+			} else {
+				triggers = append(triggers, unit)
+			}
+		}
+		if len(triggers) > 0 {
+			cmd := "systemctl enable --now " + strings.Join(triggers, " ")
+			cmds = append(cmds, cmd)
+			if _, _, err := d.Executor.Execute(ctx, cmd); err != nil {
+				return cmds, fmt.Errorf("restart: %w", err)
+			}
+		}
+		if len(services) > 0 {
+			cmd := "systemctl start " + strings.Join(services, " ")
+			cmds = append(cmds, cmd)
+			if _, _, err := d.Executor.Execute(ctx, cmd); err != nil {
+				return cmds, fmt.Errorf("restart: %w", err)
+			}
+		}
+		st.Halted = nil
+		st.Holding = nil
+
+	case "lift_quarantine":
+		agent := parseAgentFromScope(scope)
+		if agent == "" {
+			return nil, fmt.Errorf("lift_quarantine: cannot determine agent from scope %q", scope)
+		}
+		qcmds, err := quarantine.Release(ctx, d.Executor, agent)
+		cmds = append(cmds, qcmds...)
+		if err != nil {
+			return cmds, fmt.Errorf("lift_quarantine: %w", err)
+		}
+
+	case "notify", "":
+		// No OS action — notification only
+
+	default:
+		return nil, fmt.Errorf("unknown on_recover action: %q", action.Action)
+	}
+
+	if action.Escalate != "" {
+		if err := d.escalate(action.Escalate, contractID+"/recovered/"+action.Message, action.Message); err != nil {
+			return cmds, fmt.Errorf("notify %s: %w", action.Escalate, err)
+		}
+	}
+
+	return cmds, nil
+}
+
+// holder returns another failing check whose halt holds unit down, or nil.
+func (d *Dispatcher) holder(self *CheckState, unit string) *CheckState {
+	if d.State == nil {
+		return nil
+	}
+	for _, cs := range d.State.Contracts {
+		for _, st := range cs.Checks {
+			if st == self || !st.Failing {
+				continue
+			}
+			for _, pattern := range st.Holding {
+				if ok, _ := filepath.Match(pattern, unit); ok {
+					return st
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// activeUnits lists the active systemd services matching the given unit
+// names or (quoted) patterns. Errors yield an empty list: nothing is
+// remembered, so nothing is restarted later.
+func activeUnits(ctx context.Context, executor CommandExecutor, units ...string) []string {
+	out, code, err := executor.Execute(ctx, "systemctl list-units --type=service --state=active --plain --no-legend "+strings.Join(units, " "))
+	if err != nil || code != 0 {
+		return nil
+	}
+	var active []string
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			active = append(active, fields[0])
+		}
+	}
+	return active
+}
+
+// escalate deduplicates through State when there is one.
+func (d *Dispatcher) escalate(agent, key, message string) error {
+	if d.State == nil {
+		return Escalate(agent, message)
+	}
+	_, err := d.State.Escalate(agent, key, message, d.EscalationCooldown, time.Now())
+	return err
+}
+
 // Escalate writes a .task file to the target agent's inbox.
 func Escalate(agentName string, message string) error {
 	ts := time.Now().Format("20060102-150405")
-	taskPath := fmt.Sprintf("/srv/con/agents/%s/inbox/%s-healthcheck.task", agentName, ts)
+	taskPath := filepath.Join(AgentsDir, agentName, "inbox", ts+"-healthcheck.task")
 	return os.WriteFile(taskPath, []byte(message), 0644)
 }
 
@@ -6,51 +6,37 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
-)
-
-func TestDispatchAction_HaltAgents(t *testing.T) {
-	exec := &MockExecutor{ExitCode: 0}
-	action := FailAction{Action: "halt_agents", Message: "test"}
 
-	cmds, err := DispatchAction(context.Background(), action, "system", exec)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if len(cmds) != 1 {
-		t.Fatalf("cmds = %d, want 1", len(cmds))
-	}
-	if !strings.Contains(cmds[0], "systemctl stop") {
-		t.Errorf("expected systemctl stop, got: %s", cmds[0])
-	}
-}
-
-func TestDispatchAction_HaltWorkers(t *testing.T) {
-	exec := &MockExecutor{ExitCode: 0}
-	action := FailAction{Action: "halt_workers", Message: "test"}
+	"github.com/ConspiracyOS/agent-runner/internal/quarantine"
+	"github.com/ConspiracyOS/agent-runner/internal/session"
+)
 
-	cmds, err := DispatchAction(context.Background(), action, "system", exec)
-	if err != nil {
-		t.Fatal(err)
-	}
-	// v1: halt_workers = halt_agents
-	if len(cmds) != 1 {
-		t.Fatalf("cmds = %d, want 1", len(cmds))
+func TestDispatchAction_HaltWithoutAgents(t *testing.T) {
+	// Without agent config there is nothing to resolve a halt to: it fails
+	// instead of stopping every con-*.service
+	for _, action := range []string{"halt_agents", "halt_workers"} {
+		exec := &MockExecutor{}
+		cmds, err := DispatchAction(context.Background(), FailAction{Action: action, Message: "test"}, "system", exec)
+		if err == nil || len(cmds) != 0 || len(exec.Calls) != 0 {
+			t.Errorf("%s: cmds %v calls %v err %v, want an error and nothing run", action, cmds, exec.Calls, err)
+		}
 	}
 }
 
 func TestDispatchAction_KillSession(t *testing.T) {
+	old := session.AgentsDir
+	session.AgentsDir = t.TempDir()
+	defer func() { session.AgentsDir = old }()
 	exec := &MockExecutor{ExitCode: 0}
 	action := FailAction{Action: "kill_session", Message: "session too long"}
 
+	// No run in flight: nothing to kill, not an error
 	cmds, err := DispatchAction(context.Background(), action, "agent:researcher", exec)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(cmds) != 1 {
-		t.Fatalf("cmds = %d, want 1", len(cmds))
-	}
-	if !strings.Contains(cmds[0], "pkill -u a-researcher picoclaw") {
-		t.Errorf("expected pkill for researcher, got: %s", cmds[0])
+	if len(cmds) != 0 || len(exec.Calls) != 0 {
+		t.Errorf("cmds = %v, calls = %v; want none (no pkill of a runtime name)", cmds, exec.Calls)
 	}
 }
 
@@ -64,7 +50,16 @@ func TestDispatchAction_KillSession_NoAgent(t *testing.T) {
 	}
 }
 
+// withQuarantineDirs points the quarantine package at temp dirs.
+func withQuarantineDirs(t *testing.T) {
+	t.Helper()
+	oldDir, oldAgents := quarantine.Dir, quarantine.AgentsDir
+	quarantine.Dir, quarantine.AgentsDir = t.TempDir(), t.TempDir()
+	t.Cleanup(func() { quarantine.Dir, quarantine.AgentsDir = oldDir, oldAgents })
+}
+
 func TestDispatchAction_Quarantine(t *testing.T) {
+	withQuarantineDirs(t)
 	exec := &MockExecutor{ExitCode: 0}
 	action := FailAction{Action: "quarantine", Message: "compromised"}
 
@@ -75,12 +70,15 @@ func TestDispatchAction_Quarantine(t *testing.T) {
 	if len(cmds) != 2 {
 		t.Fatalf("cmds = %d, want 2", len(cmds))
 	}
-	if !strings.Contains(cmds[0], "systemctl stop con-badagent") {
-		t.Errorf("first cmd should stop service, got: %s", cmds[0])
+	if !strings.Contains(cmds[0], "systemctl mask --runtime --now con-badagent") {
+		t.Errorf("first cmd should stop and mask units, got: %s", cmds[0])
 	}
 	if !strings.Contains(cmds[1], "setfacl -b") {
 		t.Errorf("second cmd should revoke ACLs, got: %s", cmds[1])
 	}
+	if m, err := quarantine.Get("badagent"); err != nil || m.Reason != "compromised" {
+		t.Errorf("quarantine marker = %+v, %v", m, err)
+	}
 }
 
 func TestDispatchAction_Alert(t *testing.T) {
@@ -129,3 +127,230 @@ func TestDispatchAction_UnknownAction(t *testing.T) {
 		t.Error("expected error for unknown action")
 	}
 }
+
+// continuousAgents have no path or timer units, so halts only stop services.
+var continuousAgents = []AgentInfo{
+	{Name: "concierge", Tier: "operator", Mode: "continuous"},
+	{Name: "writer", Tier: "worker", Mode: "continuous"},
+}
+
+func TestDispatcher_HaltThenRestart(t *testing.T) {
+	state, _ := LoadState(filepath.Join(t.TempDir(), "state.json"))
+	exec := &MockExecutor{Outputs: map[string]string{
+		"list-units": "con-concierge.service loaded active running ConspiracyOS agent concierge\ncon-writer.service loaded active running ConspiracyOS agent writer\n",
+	}}
+	d := &Dispatcher{Executor: exec, State: state, Agents: continuousAgents}
+	ch := Check{Name: "disk", OnFail: FailAction{Action: "halt_agents"}, OnRecover: RecoverAction{Action: "restart"}}
+
+	if _, err := d.Fail(context.Background(), "CON-SYS-001", ch, "system"); err != nil {
+		t.Fatal(err)
+	}
+	if got := state.Contracts["CON-SYS-001"].Checks["disk"].Halted; len(got) != 2 {
+		t.Fatalf("Halted = %v, want the two active units", got)
+	}
+
+	cmds, err := d.Recover(context.Background(), "CON-SYS-001", ch, "system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmds) != 1 || cmds[0] != "systemctl start con-concierge.service con-writer.service" {
+		t.Errorf("recover cmds = %v, want start of the halted units only", cmds)
+	}
+	if len(state.Contracts["CON-SYS-001"].Checks["disk"].Halted) != 0 {
+		t.Error("Halted should be cleared after restart")
+	}
+
+	// Nothing remembered: nothing restarted
+	if cmds, _ := d.Recover(context.Background(), "CON-SYS-001", ch, "system"); len(cmds) != 0 {
+		t.Errorf("second recover cmds = %v, want none", cmds)
+	}
+}
+
+func TestDispatcher_OverlappingHalts(t *testing.T) {
+	state, _ := LoadState(filepath.Join(t.TempDir(), "state.json"))
+	exec := &MockExecutor{Outputs: map[string]string{
+		"list-units": "con-concierge.service loaded active running ConspiracyOS agent concierge\n",
+	}}
+	d := &Dispatcher{Executor: exec, State: state, Agents: continuousAgents}
+	a := Check{Name: "a", OnFail: FailAction{Action: "halt_agents"}, OnRecover: RecoverAction{Action: "restart"}}
+	b := Check{Name: "disk", OnFail: FailAction{Action: "halt_agents"}, OnRecover: RecoverAction{Action: "restart"}}
+	ctx := context.Background()
+
+	// A halts first; B halts what is left (nothing running any more)
+	d.Fail(ctx, "CON-A", a, "system")
+	state.check("CON-A", "a").Failing = true
+	exec.Outputs = nil
+	d.Fail(ctx, "CON-SYS-001", b, "system")
+	state.check("CON-SYS-001", "disk").Failing = true
+
+	// A recovers while B still fails: nothing starts, B takes over the unit
+	state.check("CON-A", "a").Failing = false
+	if cmds, err := d.Recover(ctx, "CON-A", a, "system"); err != nil || len(cmds) != 0 {
+		t.Fatalf("recover A: cmds %v err %v, want nothing started while B holds the units", cmds, err)
+	}
+	if got := state.check("CON-SYS-001", "disk").Halted; len(got) != 1 || got[0] != "con-concierge.service" {
+		t.Errorf("B Halted = %v, want the unit A stopped", got)
+	}
+
+	state.check("CON-SYS-001", "disk").Failing = false
+	cmds, err := d.Recover(ctx, "CON-SYS-001", b, "system")
+	if err != nil || len(cmds) != 1 || cmds[0] != "systemctl start con-concierge.service" {
+		t.Errorf("recover B: cmds %v err %v, want the unit started", cmds, err)
+	}
+}
+
+func TestDispatcher_StaleHolderPruned(t *testing.T) {
+	state, _ := LoadState(filepath.Join(t.TempDir(), "state.json"))
+	gone := state.check("CON-GONE", "c")
+	gone.Failing, gone.Holding = true, []string{"con-*.service"}
+	ch := Check{Name: "disk", OnRecover: RecoverAction{Action: "restart"}}
+	state.check("CON-SYS-001", "disk").Halted = []string{"con-concierge.service"}
+
+	state.Prune([]Contract{{ID: "CON-SYS-001", Checks: []Check{ch}}})
+	d := &Dispatcher{Executor: &MockExecutor{}, State: state}
+	cmds, err := d.Recover(context.Background(), "CON-SYS-001", ch, "system")
+	if err != nil || len(cmds) != 1 || cmds[0] != "systemctl start con-concierge.service" {
+		t.Errorf("recover cmds %v err %v, want the unit started once the stale holder is pruned", cmds, err)
+	}
+}
+
+func TestDispatcher_LiftQuarantine(t *testing.T) {
+	withQuarantineDirs(t)
+	state, _ := LoadState(filepath.Join(t.TempDir(), "state.json"))
+	d := &Dispatcher{Executor: &MockExecutor{}, State: state}
+	ch := Check{Name: "c", OnFail: FailAction{Action: "quarantine", Message: "bad"}, OnRecover: RecoverAction{Action: "lift_quarantine"}}
+
+	if _, err := d.Fail(context.Background(), "CON-Q", ch, "agent:bad"); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := quarantine.Get("bad"); m.Source != "CON-Q" {
+		t.Errorf("marker source = %q, want CON-Q", m.Source)
+	}
+	cmds, err := d.Recover(context.Background(), "CON-Q", ch, "agent:bad")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmds) == 0 || !strings.HasPrefix(cmds[0], "setfacl --restore=") {
+		t.Errorf("recover cmds = %v, want ACL restore first", cmds)
+	}
+	if _, err := quarantine.Get("bad"); err == nil {
+		t.Error("agent still quarantined after lift_quarantine")
+	}
+}
+
+func TestDispatcher_NotifyAndValidation(t *testing.T) {
+	d := &Dispatcher{Executor: &MockExecutor{}}
+	cmds, err := d.Recover(context.Background(), "CON-N", Check{Name: "c", OnRecover: RecoverAction{Action: "notify"}}, "system")
+	if err != nil || len(cmds) != 0 {
+		t.Errorf("notify: cmds %v err %v, want no commands", cmds, err)
+	}
+
+	c := Contract{ID: "CON-V", Type: "detective", Checks: []Check{
+		{Name: "c", Command: &CmdCheck{Run: "true", Test: "true"}, OnRecover: RecoverAction{Action: "resurrect"}},
+	}}
+	if err := validate(c); err == nil {
+		t.Error("expected validation error for invalid on_recover action")
+	}
+}
+
+var testAgents = []AgentInfo{
+	{Name: "concierge", Tier: "operator", Mode: "on-demand"},
+	{Name: "sysadmin", Tier: "officer", Mode: "on-demand"},
+	{Name: "scraper", Tier: "worker", Mode: "cron"},
+	{Name: "writer", Tier: "worker", Mode: "continuous"},
+}
+
+func TestDispatcher_HaltWorkersByTier(t *testing.T) {
+	exec := &MockExecutor{}
+	d := &Dispatcher{Executor: exec, Agents: testAgents}
+
+	cmds, err := d.dispatch(context.Background(), "", FailAction{Action: "halt_workers"}, "system", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"systemctl disable --now con-scraper.timer con-scraper.path",
+		"systemctl stop con-scraper.service con-writer.service",
+	}
+	if strings.Join(cmds, "\n") != strings.Join(want, "\n") {
+		t.Errorf("cmds = %q, want %q", cmds, want)
+	}
+}
+
+func TestDispatcher_HaltAgentsExcludeAndRestart(t *testing.T) {
+	state, _ := LoadState(filepath.Join(t.TempDir(), "state.json"))
+	exec := &MockExecutor{Outputs: map[string]string{
+		"list-units": "con-concierge.service loaded active running ConspiracyOS agent: concierge\n",
+	}}
+	d := &Dispatcher{Executor: exec, State: state, Agents: testAgents}
+	ch := Check{Name: "disk",
+		OnFail:    FailAction{Action: "halt_agents", Exclude: []string{"sysadmin"}},
+		OnRecover: RecoverAction{Action: "restart"},
+	}
+
+	cmds, err := d.Fail(context.Background(), "CON-SYS-001", ch, "system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cmd := range cmds {
+		if strings.Contains(cmd, "sysadmin") {
+			t.Errorf("excluded sysadmin was halted: %s", cmd)
+		}
+	}
+	if !strings.Contains(cmds[0], "disable --now con-concierge.path con-scraper.timer") {
+		t.Errorf("triggers should be disabled first, got %q", cmds)
+	}
+
+	cmds, err = d.Recover(context.Background(), "CON-SYS-001", ch, "system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"systemctl enable --now con-concierge.path con-scraper.timer con-scraper.path",
+		"systemctl start con-concierge.service",
+	}
+	if strings.Join(cmds, "\n") != strings.Join(want, "\n") {
+		t.Errorf("recover cmds = %q, want %q", cmds, want)
+	}
+}
+
+func TestDispatcher_RestartSkipsUnitsHeldByScopedHalt(t *testing.T) {
+	state, _ := LoadState(filepath.Join(t.TempDir(), "state.json"))
+	exec := &MockExecutor{Outputs: map[string]string{
+		"list-units": "con-concierge.service loaded active running\ncon-writer.service loaded active running\n",
+	}}
+	d := &Dispatcher{Executor: exec, State: state, Agents: testAgents}
+	all := Check{Name: "disk", OnFail: FailAction{Action: "halt_agents", Exclude: []string{"sysadmin"}}, OnRecover: RecoverAction{Action: "restart"}}
+	workers := Check{Name: "egress", OnFail: FailAction{Action: "halt_workers"}, OnRecover: RecoverAction{Action: "restart"}}
+	ctx := context.Background()
+
+	d.Fail(ctx, "CON-SYS-001", all, "system")
+	state.check("CON-SYS-001", "disk").Failing = true
+	// The workers are already down when the second halt runs
+	exec.Outputs = nil
+	d.Fail(ctx, "CON-NET-001", workers, "system")
+	state.check("CON-NET-001", "egress").Failing = true
+
+	state.check("CON-SYS-001", "disk").Failing = false
+	cmds, err := d.Recover(ctx, "CON-SYS-001", all, "system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"systemctl enable --now con-concierge.path",
+		"systemctl start con-concierge.service",
+	}
+	if strings.Join(cmds, "\n") != strings.Join(want, "\n") {
+		t.Errorf("recover cmds = %q, want only units the worker halt does not hold", cmds)
+	}
+
+	state.check("CON-NET-001", "egress").Failing = false
+	cmds, _ = d.Recover(ctx, "CON-NET-001", workers, "system")
+	want = []string{
+		"systemctl enable --now con-scraper.timer con-scraper.path",
+		"systemctl start con-writer.service",
+	}
+	if strings.Join(cmds, "\n") != strings.Join(want, "\n") {
+		t.Errorf("worker recover cmds = %q, want %q", cmds, want)
+	}
+}
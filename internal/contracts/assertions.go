@@ -0,0 +1,202 @@
+package contracts
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Assertion kinds verify that a preventive contract's mechanism is in place.
+// They are ordinary native checks, so detective contracts may use them too.
+
+// ACLCheck passes when Path carries every ACL entry in Entries (exact
+// permissions) and none of the tag:qualifier pairs in Absent. Entries use
+// getfacl syntax, e.g. "user:a-concierge:rwx" or "default:group:agents:r-x";
+// the short tags u, g, o, m and d are accepted.
+type ACLCheck struct {
+	Path    string   `yaml:"path"`
+	Entries []string `yaml:"entries"`
+	Absent  []string `yaml:"absent"` // e.g. "user:a-researcher"
+}
+
+// UnitDirectiveCheck passes when the unit's effective configuration (unit
+// file plus drop-ins, as printed by systemctl cat) sets Directive to Value.
+// An empty Value only requires the directive to be set.
+type UnitDirectiveCheck struct {
+	Unit      string `yaml:"unit"`
+	Directive string `yaml:"directive"` // e.g. NoNewPrivileges
+	Value     string `yaml:"value"`     // e.g. yes
+}
+
+// SudoersCheck passes when User may run every command in Allow and none in
+// Deny, as sudo itself resolves the sudoers policy.
+type SudoersCheck struct {
+	User  string   `yaml:"user"`
+	Allow []string `yaml:"allow"` // e.g. "/usr/bin/systemctl restart con-*"
+	Deny  []string `yaml:"deny"`
+}
+
+// userName matches the user names a sudoers assertion accepts; the name is
+// passed to sudo through the shell.
+var userName = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+func (c *ACLCheck) validate() error {
+	if c.Path == "" || strings.Contains(c.Path, "'") {
+		return fmt.Errorf("acl: invalid path %q", c.Path)
+	}
+	if len(c.Entries) == 0 && len(c.Absent) == 0 {
+		return fmt.Errorf("acl: set entries or absent")
+	}
+	for _, e := range c.Entries {
+		if strings.Count(normalizeACLEntry(e), ":") < 2 {
+			return fmt.Errorf("acl: invalid entry %q (want tag:qualifier:perms)", e)
+		}
+	}
+	return nil
+}
+
+func (c *ACLCheck) run(ctx context.Context, executor CommandExecutor) (string, bool, error) {
+	out, code, err := executor.Execute(ctx, fmt.Sprintf("getfacl -cp '%s'", c.Path))
+	if err != nil {
+		return "", false, err
+	}
+	if code != 0 {
+		return fmt.Sprintf("%s: getfacl exit %d: %s", c.Path, code, strings.TrimSpace(out)), false, nil
+	}
+	have := map[string]bool{}
+	qualifiers := map[string]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		// "group:agents:rwx\t#effective:r-x": only the entry itself counts
+		entry, _, _ := strings.Cut(line, "#")
+		entry = strings.TrimSpace(entry)
+		i := strings.LastIndex(entry, ":")
+		if i < 0 {
+			continue
+		}
+		have[entry] = true
+		qualifiers[entry[:i]] = true
+	}
+
+	var problems []string
+	for _, e := range c.Entries {
+		if want := normalizeACLEntry(e); !have[want] {
+			problems = append(problems, "missing "+want)
+		}
+	}
+	for _, a := range c.Absent {
+		if q := strings.TrimSuffix(normalizeACLEntry(a+":"), ":"); qualifiers[q] {
+			problems = append(problems, "unexpected "+q)
+		}
+	}
+	if len(problems) > 0 {
+		return c.Path + ": " + strings.Join(problems, ", "), false, nil
+	}
+	return fmt.Sprintf("%s: ACL as declared", c.Path), true, nil
+}
+
+// normalizeACLEntry expands the short tags setfacl accepts to the long
+// ones getfacl prints.
+func normalizeACLEntry(e string) string {
+	long := map[string]string{"u": "user", "g": "group", "o": "other", "m": "mask"}
+	parts := strings.Split(strings.TrimSpace(e), ":")
+	i := 0
+	if parts[0] == "d" || parts[0] == "default" {
+		parts[0] = "default"
+		i = 1
+	}
+	if i < len(parts) {
+		if l, ok := long[parts[i]]; ok {
+			parts[i] = l
+		}
+	}
+	return strings.Join(parts, ":")
+}
+
+func (c *UnitDirectiveCheck) validate() error {
+	if !unitName.MatchString(c.Unit) {
+		return fmt.Errorf("unit_directive: invalid unit %q", c.Unit)
+	}
+	if c.Directive == "" || strings.ContainsAny(c.Directive, " =") {
+		return fmt.Errorf("unit_directive: invalid directive %q", c.Directive)
+	}
+	return nil
+}
+
+func (c *UnitDirectiveCheck) run(ctx context.Context, executor CommandExecutor) (string, bool, error) {
+	out, code, err := executor.Execute(ctx, "systemctl cat "+c.Unit)
+	if err != nil {
+		return "", false, err
+	}
+	if code != 0 {
+		return fmt.Sprintf("%s: not found", c.Unit), false, nil
+	}
+	// Later assignments (drop-ins) override earlier ones; an empty
+	// assignment resets the directive
+	value, set := "", false
+	for _, line := range strings.Split(out, "\n") {
+		key, v, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || strings.TrimSpace(key) != c.Directive {
+			continue
+		}
+		value = strings.TrimSpace(v)
+		set = value != ""
+	}
+	switch {
+	case !set:
+		return fmt.Sprintf("%s: %s not set", c.Unit, c.Directive), false, nil
+	case c.Value != "" && value != c.Value:
+		return fmt.Sprintf("%s: %s=%s (want %s)", c.Unit, c.Directive, value, c.Value), false, nil
+	}
+	return fmt.Sprintf("%s: %s=%s", c.Unit, c.Directive, value), true, nil
+}
+
+func (c *SudoersCheck) validate() error {
+	if !userName.MatchString(c.User) {
+		return fmt.Errorf("sudoers: invalid user %q", c.User)
+	}
+	if len(c.Allow) == 0 && len(c.Deny) == 0 {
+		return fmt.Errorf("sudoers: set allow or deny")
+	}
+	for _, cmd := range append(append([]string{}, c.Allow...), c.Deny...) {
+		if strings.TrimSpace(cmd) == "" || strings.Contains(cmd, "'") {
+			return fmt.Errorf("sudoers: invalid command %q", cmd)
+		}
+	}
+	return nil
+}
+
+func (c *SudoersCheck) run(ctx context.Context, executor CommandExecutor) (string, bool, error) {
+	var problems []string
+	for _, list := range []struct {
+		cmds  []string
+		allow bool
+	}{{c.Allow, true}, {c.Deny, false}} {
+		for _, cmd := range list.cmds {
+			// sudo -l with a command exits 0 only if the policy allows it
+			_, code, err := executor.Execute(ctx, fmt.Sprintf("sudo -n -l -U '%s' %s", c.User, shellWords(cmd)))
+			if err != nil {
+				return "", false, err
+			}
+			switch {
+			case list.allow && code != 0:
+				problems = append(problems, "denied: "+cmd)
+			case !list.allow && code == 0:
+				problems = append(problems, "allowed: "+cmd)
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return c.User + ": " + strings.Join(problems, ", "), false, nil
+	}
+	return fmt.Sprintf("%s: sudoers as declared", c.User), true, nil
+}
+
+// shellWords single-quotes each word of cmd.
+func shellWords(cmd string) string {
+	words := strings.Fields(cmd)
+	for i, w := range words {
+		words[i] = "'" + w + "'"
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,143 @@
+package contracts
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const auditACL = "user::rwx\nuser:a-sysadmin:rwx\ngroup::r-x\ngroup:agents:rwx\t#effective:r-x\nmask::r-x\nother::---\ndefault:group:agents:r-x\n"
+
+func TestACLCheck(t *testing.T) {
+	exec := &MockExecutor{Outputs: map[string]string{"getfacl": auditACL}}
+	ctx := context.Background()
+
+	held := &ACLCheck{Path: "/srv/con/logs/audit/", Entries: []string{"g:agents:rwx", "d:g:agents:r-x"}, Absent: []string{"user:a-researcher"}}
+	if out, ok, err := held.run(ctx, exec); err != nil || !ok {
+		t.Errorf("declared ACL: ok=%v err=%v out=%q", ok, err, out)
+	}
+	if !strings.Contains(exec.Calls[0], "getfacl -cp '/srv/con/logs/audit/'") {
+		t.Errorf("command = %q", exec.Calls[0])
+	}
+
+	drifted := &ACLCheck{Path: "/srv/con/logs/audit/", Entries: []string{"group:agents:r-x"}, Absent: []string{"u:a-sysadmin"}}
+	out, ok, err := drifted.run(ctx, exec)
+	if err != nil || ok {
+		t.Fatalf("drifted ACL: ok=%v err=%v", ok, err)
+	}
+	if !strings.Contains(out, "missing group:agents:r-x") || !strings.Contains(out, "unexpected user:a-sysadmin") {
+		t.Errorf("output = %q", out)
+	}
+
+	if err := (&ACLCheck{Path: "/x"}).validate(); err == nil {
+		t.Error("an acl assertion without entries should not validate")
+	}
+	if err := (&ACLCheck{Path: "/x", Entries: []string{"agents"}}).validate(); err == nil {
+		t.Error("a malformed entry should not validate")
+	}
+}
+
+func TestAssertionNamesAllowlisted(t *testing.T) {
+	for _, unit := range []string{"", "x\nid", "x>/tmp/y", "$(id)", "a b"} {
+		if err := (&UnitDirectiveCheck{Unit: unit, Directive: "User"}).validate(); err == nil {
+			t.Errorf("unit %q should not validate", unit)
+		}
+	}
+	for _, user := range []string{"", "a\nid", "a<b", "a(b)", "a b"} {
+		if err := (&SudoersCheck{User: user, Deny: []string{"/bin/sh"}}).validate(); err == nil {
+			t.Errorf("user %q should not validate", user)
+		}
+	}
+	if err := (&UnitDirectiveCheck{Unit: "con-web@1.service", Directive: "User"}).validate(); err != nil {
+		t.Errorf("valid unit rejected: %v", err)
+	}
+	if err := (&SudoersCheck{User: "a-sysadmin", Deny: []string{"/bin/sh"}}).validate(); err != nil {
+		t.Errorf("valid user rejected: %v", err)
+	}
+}
+
+func TestUnitDirectiveCheck(t *testing.T) {
+	unit := "# /etc/systemd/system/con-researcher.service\n[Service]\nNoNewPrivileges=yes\nProtectSystem=full\n\n# /etc/systemd/system/con-researcher.service.d/override.conf\n[Service]\nProtectSystem=strict\nReadWritePaths=\n"
+	exec := &MockExecutor{Outputs: map[string]string{"systemctl cat": unit}}
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		directive, value string
+		want             bool
+	}{
+		{"NoNewPrivileges", "yes", true},
+		{"ProtectSystem", "strict", true}, // the drop-in wins
+		{"ProtectSystem", "full", false},
+		{"ProtectSystem", "", true},
+		{"ReadWritePaths", "", false}, // reset by an empty assignment
+		{"PrivateTmp", "yes", false},
+	} {
+		c := &UnitDirectiveCheck{Unit: "con-researcher.service", Directive: tc.directive, Value: tc.value}
+		out, ok, err := c.run(ctx, exec)
+		if err != nil || ok != tc.want {
+			t.Errorf("%s=%s: ok=%v err=%v out=%q, want %v", tc.directive, tc.value, ok, err, out, tc.want)
+		}
+	}
+
+	missing := &MockExecutor{ExitCode: 1}
+	if _, ok, _ := (&UnitDirectiveCheck{Unit: "con-gone.service", Directive: "NoNewPrivileges"}).run(ctx, missing); ok {
+		t.Error("a missing unit should drift")
+	}
+}
+
+func TestSudoersCheck(t *testing.T) {
+	exec := &MockExecutor{ExitCode: 1, Overrides: map[string]int{"'/usr/bin/systemctl' 'restart'": 0}}
+	ctx := context.Background()
+
+	ok := &SudoersCheck{User: "a-sysadmin", Allow: []string{"/usr/bin/systemctl restart con-concierge"}, Deny: []string{"/bin/sh"}}
+	if out, passed, err := ok.run(ctx, exec); err != nil || !passed {
+		t.Errorf("declared sudoers: passed=%v err=%v out=%q", passed, err, out)
+	}
+	if exec.Calls[0] != "sudo -n -l -U 'a-sysadmin' '/usr/bin/systemctl' 'restart' 'con-concierge'" {
+		t.Errorf("command = %q", exec.Calls[0])
+	}
+
+	drifted := &SudoersCheck{User: "a-researcher", Deny: []string{"/usr/bin/systemctl restart con-sysadmin"}}
+	out, passed, err := drifted.run(ctx, exec)
+	if err != nil || passed || !strings.Contains(out, "allowed: /usr/bin/systemctl restart con-sysadmin") {
+		t.Errorf("drifted sudoers: passed=%v err=%v out=%q", passed, err, out)
+	}
+}
+
+func TestEvaluatePreventiveAssertions(t *testing.T) {
+	contracts := []Contract{
+		{ID: "CON-P-001", Type: "preventive", Mechanism: "acl", Checks: []Check{
+			{Name: "audit_acl", ACL: &ACLCheck{Path: "/srv/con/logs/audit/", Entries: []string{"group:agents:rwx"}}},
+		}},
+		{ID: "CON-P-002", Type: "preventive", Mechanism: "systemd", Checks: []Check{
+			{Name: "no_new_privs", UnitDirective: &UnitDirectiveCheck{Unit: "con-researcher.service", Directive: "NoNewPrivileges", Value: "yes"}},
+		}},
+		{ID: "CON-P-003", Type: "preventive", Mechanism: "nftables"},
+	}
+	exec := &MockExecutor{Outputs: map[string]string{"getfacl": auditACL, "systemctl cat": "NoNewPrivileges=no"}}
+	state, _ := LoadState(filepath.Join(t.TempDir(), "state.json"))
+
+	result := EvaluateDue(context.Background(), contracts, "/tmp", exec, state)
+	if result.Enforced != 1 || result.Drifted != 1 || result.Skipped != 1 || result.Passed != 0 || result.Failed != 0 {
+		t.Fatalf("result = %+v", result)
+	}
+	if got := result.Results[1]; got.Status() != "DRIFTED" || got.Transition != TransitionFailed {
+		t.Errorf("drifted assertion: status %s, transition %q", got.Status(), got.Transition)
+	}
+	if failing := state.FailingChecks(contracts); len(failing) != 1 || failing[0] != "CON-P-002/no_new_privs" {
+		t.Errorf("FailingChecks = %v", failing)
+	}
+
+	var buf bytes.Buffer
+	result.Timestamp = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	WriteLog(result, &buf)
+	log := buf.String()
+	for _, want := range []string{"CON-P-001 ENFORCED audit_acl", "CON-P-002 DRIFTED no_new_privs", "1 enforced, 1 drifted"} {
+		if !strings.Contains(log, want) {
+			t.Errorf("log missing %q:\n%s", want, log)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package contracts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// depGraph is the depends_on DAG over checks. Nodes are "<contract>/<check>";
+// a contract-level dependency, or a reference to a whole contract, expands
+// to every check of that contract.
+type depGraph struct {
+	nodes   []string            // in contract and check order
+	deps    map[string][]string // node -> nodes it depends on
+	outside map[string][]string // node -> references to contracts not in the graph
+}
+
+// splitRef splits a depends_on reference: "CON-X" (every check of the
+// contract) or "CON-X/check".
+func splitRef(ref string) (id, check string) {
+	id, check, _ = strings.Cut(ref, "/")
+	return id, check
+}
+
+// buildDeps builds the dependency graph of contracts. References to
+// contracts that are not in contracts are kept as outside references; with
+// strict they are an error, as are references to unknown checks.
+func buildDeps(contracts []Contract, strict bool) (*depGraph, error) {
+	g := &depGraph{deps: map[string][]string{}, outside: map[string][]string{}}
+	checks := map[string][]string{} // contract ID -> its nodes
+	for _, c := range contracts {
+		checks[c.ID] = []string{}
+		for _, ch := range c.Checks {
+			node := c.ID + "/" + ch.Name
+			g.nodes = append(g.nodes, node)
+			checks[c.ID] = append(checks[c.ID], node)
+		}
+	}
+
+	for _, c := range contracts {
+		for _, ch := range c.Checks {
+			node := c.ID + "/" + ch.Name
+			refs := append(append([]string{}, c.DependsOn...), ch.DependsOn...)
+			for _, ref := range refs {
+				id, check := splitRef(ref)
+				nodes, known := checks[id]
+				switch {
+				case !known && strict:
+					return nil, fmt.Errorf("%s depends on unknown contract %s", node, id)
+				case !known:
+					g.outside[node] = append(g.outside[node], ref)
+				case check == "":
+					g.deps[node] = append(g.deps[node], nodes...)
+				case contains(nodes, ref):
+					g.deps[node] = append(g.deps[node], ref)
+				case strict:
+					return nil, fmt.Errorf("%s depends on unknown check %s", node, ref)
+				default:
+					g.outside[node] = append(g.outside[node], ref)
+				}
+			}
+		}
+	}
+	return g, nil
+}
+
+// cycle returns the nodes of a dependency cycle, first node repeated at
+// the end, or nil if the graph is acyclic.
+func (g *depGraph) cycle() []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	var stack []string
+	var visit func(n string) []string
+	visit = func(n string) []string {
+		state[n] = visiting
+		stack = append(stack, n)
+		for _, d := range g.deps[n] {
+			switch state[d] {
+			case visiting:
+				for i, s := range stack {
+					if s == d {
+						return append(append([]string{}, stack[i:]...), d)
+					}
+				}
+			case unvisited:
+				if c := visit(d); c != nil {
+					return c
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[n] = done
+		return nil
+	}
+	for _, n := range g.nodes {
+		if state[n] == unvisited {
+			if c := visit(n); c != nil {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// levels assigns each node 0 if it has no dependencies in the graph, else
+// one more than its deepest dependency, so running level by level runs
+// every check after the checks it depends on. The graph must be acyclic.
+func (g *depGraph) levels() map[string]int {
+	level := map[string]int{}
+	var walk func(n string) int
+	walk = func(n string) int {
+		if l, ok := level[n]; ok {
+			return l
+		}
+		l := 0
+		for _, d := range g.deps[n] {
+			if dl := walk(d) + 1; dl > l {
+				l = dl
+			}
+		}
+		level[n] = l
+		return l
+	}
+	for _, n := range g.nodes {
+		walk(n)
+	}
+	return level
+}
+
+// validateDeps rejects unknown references and cycles across a set of
+// contracts (LoadDir). LoadFile checks a single contract's own cycles.
+func validateDeps(contracts []Contract, strict bool) error {
+	g, err := buildDeps(contracts, strict)
+	if err != nil {
+		return err
+	}
+	if c := g.cycle(); c != nil {
+		return fmt.Errorf("depends_on cycle: %s", strings.Join(c, " -> "))
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
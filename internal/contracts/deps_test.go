@@ -0,0 +1,142 @@
+package contracts
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadDir_DependsOnCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeTemp(t, dir, "CON-A.yaml", `id: CON-A
+type: detective
+depends_on: [CON-B]
+checks:
+  - name: a
+    command: {run: "true", test: "true"}
+`)
+	writeTemp(t, dir, "CON-B.yaml", `id: CON-B
+type: detective
+checks:
+  - name: b
+    command: {run: "true", test: "true"}
+    depends_on: [CON-A/a]
+`)
+	_, err := LoadDir(dir, nil)
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("LoadDir error = %v, want a depends_on cycle", err)
+	}
+}
+
+func TestLoadFile_DependsOnSelfCycle(t *testing.T) {
+	path := writeTemp(t, t.TempDir(), "CON-S.yaml", `id: CON-S
+type: detective
+checks:
+  - name: first
+    command: {run: "true", test: "true"}
+    depends_on: [CON-S/second]
+  - name: second
+    command: {run: "true", test: "true"}
+    depends_on: [CON-S/first]
+`)
+	if _, err := LoadFile(path, nil); err == nil || !strings.Contains(err.Error(), "CON-S/first -> CON-S/second -> CON-S/first") {
+		t.Errorf("LoadFile error = %v, want the cycle spelled out", err)
+	}
+}
+
+func TestLoadDir_DependsOnUnknown(t *testing.T) {
+	dir := t.TempDir()
+	writeTemp(t, dir, "CON-A.yaml", `id: CON-A
+type: detective
+checks:
+  - name: a
+    command: {run: "true", test: "true"}
+    depends_on: [CON-SYS-001/mounted]
+`)
+	if _, err := LoadDir(dir, nil); err == nil || !strings.Contains(err.Error(), "unknown contract CON-SYS-001") {
+		t.Errorf("LoadDir error = %v, want unknown contract", err)
+	}
+}
+
+func depContracts() []Contract {
+	return []Contract{
+		{ID: "CON-PERMS", Type: "detective", DependsOn: []string{"CON-MOUNT"}, Checks: []Check{
+			{Name: "agent_perms", Command: &CmdCheck{Run: "check-perms", Test: "true"}},
+		}},
+		{ID: "CON-MOUNT", Type: "detective", Checks: []Check{
+			{Name: "srv_mounted", Command: &CmdCheck{Run: "mountpoint /srv/con", Test: "true"}},
+		}},
+		{ID: "CON-OTHER", Type: "detective", Checks: []Check{
+			{Name: "independent", Command: &CmdCheck{Run: "echo ok", Test: "true"}},
+		}},
+	}
+}
+
+func TestEvaluate_BlockedByFailedDependency(t *testing.T) {
+	exec := &MockExecutor{Overrides: map[string]int{"mountpoint": 1}}
+	result := Evaluate(context.Background(), depContracts(), "/tmp", exec)
+
+	perms := result.Results[0]
+	if !perms.Blocked || perms.Status() != "BLOCKED" || perms.BlockedBy[0] != "CON-MOUNT/srv_mounted" {
+		t.Errorf("dependent result = %+v, want blocked by CON-MOUNT/srv_mounted", perms)
+	}
+	for _, call := range exec.Calls {
+		if strings.Contains(call, "check-perms") {
+			t.Error("a blocked check must not run")
+		}
+	}
+	if result.Failed != 1 || result.Passed != 1 || result.Blocked != 1 {
+		t.Errorf("counts = %d passed, %d failed, %d blocked; want 1, 1, 1", result.Passed, result.Failed, result.Blocked)
+	}
+}
+
+func TestEvaluate_DependencyRunsFirst(t *testing.T) {
+	exec := &MockExecutor{}
+	Workers = 1
+	defer func() { Workers = 4 }()
+	result := Evaluate(context.Background(), depContracts(), "/tmp", exec)
+
+	if result.Passed != 3 || result.Blocked != 0 {
+		t.Fatalf("result = %+v", result)
+	}
+	mount, perms := -1, -1
+	for i, call := range exec.Calls {
+		if strings.Contains(call, "mountpoint") {
+			mount = i
+		}
+		if strings.Contains(call, "check-perms") {
+			perms = i
+		}
+	}
+	if mount < 0 || perms < mount {
+		t.Errorf("calls = %v, want the dependency before its dependent", exec.Calls)
+	}
+	if result.Results[0].ContractID != "CON-PERMS" {
+		t.Error("results should stay in contract order")
+	}
+}
+
+func TestEvaluateDue_BlockedKeepsStateAndUsesOutsideState(t *testing.T) {
+	state, _ := LoadState(filepath.Join(t.TempDir(), "state.json"))
+	perms := depContracts()[0]
+	mount := depContracts()[1]
+
+	// CON-MOUNT is failing from an earlier run and not part of this one
+	state.Record("CON-MOUNT", mount.Checks[0], false, state.contract("CON-MOUNT").LastRun)
+	result := EvaluateDue(context.Background(), []Contract{perms}, "/tmp", &MockExecutor{}, state)
+
+	if !result.Results[0].Blocked || result.Results[0].Transition != "" {
+		t.Errorf("result = %+v, want blocked without a transition", result.Results[0])
+	}
+	if st := state.Contracts["CON-PERMS"].Checks["agent_perms"]; st != nil {
+		t.Errorf("blocked check history = %+v, want none recorded", st)
+	}
+
+	// Once the dependency recovers the check runs again
+	state.Record("CON-MOUNT", mount.Checks[0], true, state.contract("CON-MOUNT").LastRun)
+	result = EvaluateNow(context.Background(), []Contract{perms}, "/tmp", &MockExecutor{}, state)
+	if result.Results[0].Blocked || !result.Results[0].Passed {
+		t.Errorf("result = %+v, want the check to run and pass", result.Results[0])
+	}
+}
@@ -0,0 +1,173 @@
+package contracts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// AgentsDir is the root of the agents' directories; escalation tasks are
+// written to their inboxes.
+var AgentsDir = "/srv/con/agents"
+
+// escalationTTL is how long an escalation that stopped recurring is kept
+// in state before it is forgotten.
+const escalationTTL = 7 * 24 * time.Hour
+
+// EscalationState is one deduplicated escalation, keyed in State by its
+// fingerprint. Repeats update it instead of writing a new task.
+type EscalationState struct {
+	Agent       string    `json:"agent"`
+	Task        string    `json:"task"` // last task written
+	Occurrences int       `json:"occurrences"`
+	FirstAt     time.Time `json:"first_at"`
+	LastAt      time.Time `json:"last_at"`
+	SentAt      time.Time `json:"sent_at"` // when Task was written
+}
+
+// EscalationOutcome says what Escalate did with an escalation.
+type EscalationOutcome string
+
+const (
+	EscalationSent       EscalationOutcome = "sent"       // new task written
+	EscalationUpdated    EscalationOutcome = "updated"    // pending task rewritten in place
+	EscalationSuppressed EscalationOutcome = "suppressed" // counted only, within cooldown
+)
+
+// Fingerprint identifies an escalation by its target agent and key.
+func Fingerprint(agent, key string) string {
+	sum := sha256.Sum256([]byte(agent + "\x00" + key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Escalate sends message to agent's inbox, deduplicated by key. While the
+// previous task for the same fingerprint is still pending in the inbox it
+// is replaced (via rename) with the new occurrence count; once the agent has
+// picked it up, repeats within cooldown are only counted. The caller saves
+// the state.
+func (s *State) Escalate(agent, key, message string, cooldown time.Duration, now time.Time) (EscalationOutcome, error) {
+	s.pruneEscalations(now)
+	if s.Escalations == nil {
+		s.Escalations = map[string]*EscalationState{}
+	}
+	fp := Fingerprint(agent, key)
+	e, ok := s.Escalations[fp]
+	if !ok {
+		e = &EscalationState{Agent: agent, FirstAt: now}
+		s.Escalations[fp] = e
+	}
+	e.Occurrences++
+	e.LastAt = now
+
+	if e.Task != "" {
+		replaced, err := replaceTask(e.Task, escalationBody(message, fp, e))
+		if err != nil {
+			return "", fmt.Errorf("updating %s: %w", e.Task, err)
+		}
+		if replaced {
+			return EscalationUpdated, nil
+		}
+		if now.Sub(e.SentAt) < cooldown {
+			return EscalationSuppressed, nil
+		}
+	}
+
+	task := filepath.Join(AgentsDir, agent, "inbox", fmt.Sprintf("%s-healthcheck-%s.task", now.Format("20060102-150405"), fp))
+	if err := writeTask(task, escalationBody(message, fp, e)); err != nil {
+		return "", err
+	}
+	e.Task = task
+	e.SentAt = now
+	return EscalationSent, nil
+}
+
+// writeTask writes body to a temp file beside path and renames it over
+// path, so a runner reading the inbox never sees a partial task.
+func writeTask(path, body string) error {
+	tmp, err := writeTaskTemp(path, body)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// beforeSwap runs between holding a pending task and replacing it (tests).
+var beforeSwap = func(path string) {}
+
+// replaceTask swaps body in for the pending task at path and reports
+// whether it did. A task the agent already took is never recreated: a hard
+// link holds the old task while the new one is renamed over it, and if the
+// old task gained another name meanwhile (moved to processed/), the new
+// copy is removed again.
+func replaceTask(path, body string) (bool, error) {
+	hold := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".hold")
+	os.Remove(hold) // left by an interrupted refresh
+	if err := os.Link(path, hold); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer os.Remove(hold)
+
+	tmp, err := writeTaskTemp(path, body)
+	if err != nil {
+		return false, err
+	}
+	fresh, err := os.Stat(tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	beforeSwap(path)
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	old, err := os.Stat(hold)
+	if err != nil {
+		return false, err
+	}
+	if st, ok := old.Sys().(*syscall.Stat_t); ok && st.Nlink > 1 {
+		if cur, err := os.Stat(path); err == nil && os.SameFile(cur, fresh) {
+			os.Remove(path)
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// writeTaskTemp writes body to a temp file beside path and returns its name.
+// The name does not end in .task, so it is never picked up.
+func writeTaskTemp(path, body string) (string, error) {
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmp, []byte(body), 0644); err != nil {
+		return "", err
+	}
+	return tmp, nil
+}
+
+func escalationBody(message, fp string, e *EscalationState) string {
+	if e.Occurrences <= 1 {
+		return message + "\n"
+	}
+	return fmt.Sprintf("%s\n\nOccurrences: %d (first %s, last %s; fingerprint %s)\n",
+		message, e.Occurrences, e.FirstAt.Format(time.RFC3339), e.LastAt.Format(time.RFC3339), fp)
+}
+
+// pruneEscalations forgets escalations that have not recurred for escalationTTL.
+func (s *State) pruneEscalations(now time.Time) {
+	for fp, e := range s.Escalations {
+		if now.Sub(e.LastAt) > escalationTTL {
+			delete(s.Escalations, fp)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package contracts
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func escalationSetup(t *testing.T) (*State, string) {
+	t.Helper()
+	AgentsDir = t.TempDir()
+	t.Cleanup(func() { AgentsDir = "/srv/con/agents" })
+	inbox := filepath.Join(AgentsDir, "sysadmin", "inbox")
+	if err := os.MkdirAll(inbox, 0755); err != nil {
+		t.Fatal(err)
+	}
+	state, _ := LoadState(filepath.Join(t.TempDir(), "state.json"))
+	return state, inbox
+}
+
+func inboxTasks(t *testing.T, inbox string) []string {
+	t.Helper()
+	matches, _ := filepath.Glob(filepath.Join(inbox, "*.task"))
+	return matches
+}
+
+func TestEscalateUpdatesPendingTask(t *testing.T) {
+	state, inbox := escalationSetup(t)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, want := range []EscalationOutcome{EscalationSent, EscalationUpdated, EscalationUpdated} {
+		got, err := state.Escalate("sysadmin", "healthcheck:CON-SYS-001/disk_free", "disk low", 30*time.Minute, now.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("run %d: outcome = %s, want %s", i, got, want)
+		}
+	}
+
+	tasks := inboxTasks(t, inbox)
+	if len(tasks) != 1 {
+		t.Fatalf("tasks = %v, want exactly one", tasks)
+	}
+	data, _ := os.ReadFile(tasks[0])
+	if !strings.Contains(string(data), "disk low") || !strings.Contains(string(data), "Occurrences: 3") {
+		t.Errorf("task = %q, want the message with 3 occurrences", data)
+	}
+}
+
+func TestEscalateReplacesPendingTaskAtomically(t *testing.T) {
+	state, inbox := escalationSetup(t)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	key := "healthcheck:CON-SYS-001/disk_free"
+
+	if _, err := state.Escalate("sysadmin", key, "disk low", 30*time.Minute, now); err != nil {
+		t.Fatal(err)
+	}
+	// A reader holding the task open keeps the content it opened
+	reader, err := os.Open(inboxTasks(t, inbox)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	if _, err := state.Escalate("sysadmin", key, "disk low", 30*time.Minute, now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(reader); string(data) != "disk low\n" {
+		t.Errorf("open task was rewritten in place: %q", data)
+	}
+	entries, _ := os.ReadDir(inbox)
+	if len(entries) != 1 {
+		t.Errorf("inbox = %v, want only the task (no temp files)", entries)
+	}
+}
+
+func TestEscalateTaskTakenDuringRefresh(t *testing.T) {
+	state, inbox := escalationSetup(t)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	key := "healthcheck:CON-SYS-001/disk_free"
+	processed := filepath.Join(filepath.Dir(inbox), "processed")
+	os.MkdirAll(processed, 0755)
+
+	if _, err := state.Escalate("sysadmin", key, "disk low", 30*time.Minute, now); err != nil {
+		t.Fatal(err)
+	}
+	// The agent finishes the task between the hold and the swap
+	beforeSwap = func(path string) { os.Rename(path, filepath.Join(processed, filepath.Base(path))) }
+	t.Cleanup(func() { beforeSwap = func(string) {} })
+
+	got, err := state.Escalate("sysadmin", key, "disk low", 30*time.Minute, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != EscalationSuppressed {
+		t.Errorf("outcome = %s, want %s", got, EscalationSuppressed)
+	}
+	if entries, _ := os.ReadDir(inbox); len(entries) != 0 {
+		t.Errorf("inbox = %v, want the taken task not recreated", entries)
+	}
+	if tasks, _ := filepath.Glob(filepath.Join(processed, "*.task")); len(tasks) != 1 {
+		t.Errorf("processed = %v, want the original task", tasks)
+	}
+}
+
+func TestEscalateCooldownAfterPickup(t *testing.T) {
+	state, inbox := escalationSetup(t)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	key := "healthcheck:CON-SYS-001/disk_free"
+
+	if _, err := state.Escalate("sysadmin", key, "disk low", 30*time.Minute, now); err != nil {
+		t.Fatal(err)
+	}
+	// The agent picks the task up
+	for _, task := range inboxTasks(t, inbox) {
+		os.Remove(task)
+	}
+
+	got, err := state.Escalate("sysadmin", key, "disk low", 30*time.Minute, now.Add(10*time.Minute))
+	if err != nil || got != EscalationSuppressed {
+		t.Fatalf("within cooldown: outcome = %s, %v, want suppressed", got, err)
+	}
+	if tasks := inboxTasks(t, inbox); len(tasks) != 0 {
+		t.Errorf("suppressed escalation wrote %v", tasks)
+	}
+
+	got, err = state.Escalate("sysadmin", key, "disk low", 30*time.Minute, now.Add(31*time.Minute))
+	if err != nil || got != EscalationSent {
+		t.Fatalf("after cooldown: outcome = %s, %v, want sent", got, err)
+	}
+	tasks := inboxTasks(t, inbox)
+	if len(tasks) != 1 {
+		t.Fatalf("tasks = %v, want one reminder", tasks)
+	}
+	data, _ := os.ReadFile(tasks[0])
+	if !strings.Contains(string(data), "Occurrences: 3") {
+		t.Errorf("reminder = %q, want the running occurrence count", data)
+	}
+}
+
+func TestEscalateDistinctFingerprints(t *testing.T) {
+	state, inbox := escalationSetup(t)
+	now := time.Now()
+
+	state.Escalate("sysadmin", "healthcheck:CON-SYS-001/disk_free", "one failing", time.Hour, now)
+	state.Escalate("sysadmin", "healthcheck:CON-SYS-001/disk_free,CON-SYS-002/mem_free", "two failing", time.Hour, now)
+
+	if tasks := inboxTasks(t, inbox); len(tasks) != 2 {
+		t.Errorf("tasks = %v, want one per failing set", tasks)
+	}
+	if len(state.Escalations) != 2 {
+		t.Errorf("escalations = %d, want 2", len(state.Escalations))
+	}
+}
+
+func TestEscalationsPersistAndPrune(t *testing.T) {
+	state, _ := escalationSetup(t)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	state.Escalate("sysadmin", "old", "old failure", time.Hour, now)
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadState(state.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := loaded.Escalations[Fingerprint("sysadmin", "old")]; e == nil || e.Occurrences != 1 {
+		t.Fatalf("loaded escalation = %+v", e)
+	}
+
+	loaded.Escalate("sysadmin", "new", "new failure", time.Hour, now.Add(escalationTTL+time.Hour))
+	if _, ok := loaded.Escalations[Fingerprint("sysadmin", "old")]; ok {
+		t.Error("stale escalation was not pruned")
+	}
+}
+
+func TestFailingChecks(t *testing.T) {
+	state, _ := LoadState(filepath.Join(t.TempDir(), "state.json"))
+	disk := Check{Name: "disk_free"}
+	mem := Check{Name: "mem_free"}
+	all := []Contract{
+		{ID: "CON-SYS-002", Checks: []Check{mem}},
+		{ID: "CON-SYS-001", Checks: []Check{disk}},
+	}
+	now := time.Now()
+	state.Record("CON-SYS-002", mem, false, now)
+	state.Record("CON-SYS-001", disk, false, now)
+	state.Record("CON-GONE-001", disk, false, now)
+
+	got := state.FailingChecks(all)
+	want := []string{"CON-SYS-001/disk_free", "CON-SYS-002/mem_free"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("FailingChecks = %v, want %v", got, want)
+	}
+}
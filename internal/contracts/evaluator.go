@@ -8,6 +8,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -17,17 +18,32 @@ type CommandExecutor interface {
 	Execute(ctx context.Context, command string) (stdout string, exitCode int, err error)
 }
 
+// DefaultCheckTimeout bounds checks that declare no timeout of their own.
+const DefaultCheckTimeout = 30 * time.Second
+
+// Workers is the number of checks evaluated concurrently.
+var Workers = 4
+
 // DefaultExecutor runs commands via sh -c.
 type DefaultExecutor struct{}
 
 func (e *DefaultExecutor) Execute(ctx context.Context, command string) (string, int, error) {
 	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	// Kill the whole process group on timeout, so a check's children do not
+	// outlive it or hold its output pipe open
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
 	var stdout bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = io.Discard
 
 	err := cmd.Run()
 	exitCode := 0
+	if err != nil && ctx.Err() != nil {
+		return strings.TrimSpace(stdout.String()), -1, ctx.Err()
+	}
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
@@ -41,33 +57,138 @@ func (e *DefaultExecutor) Execute(ctx context.Context, command string) (string,
 	return strings.TrimSpace(stdout.String()), exitCode, err
 }
 
-// Evaluate runs all detective checks. Preventive contracts are skipped.
+// Evaluate runs all detective checks and the assertions of preventive
+// contracts. Preventive contracts without assertions are skipped.
+// Checks run concurrently on up to Workers goroutines, each under its own
+// timeout, and after the checks they depend on; a check whose dependency
+// did not pass is reported as blocked without running. Results are
+// reported in contract and check order.
 func Evaluate(ctx context.Context, contracts []Contract, contractsDir string, executor CommandExecutor) RunResult {
+	return evaluate(ctx, contracts, contractsDir, executor, nil)
+}
+
+// evaluate is Evaluate with satisfied deciding dependencies on contracts
+// outside this run; nil treats them as satisfied.
+func evaluate(ctx context.Context, contracts []Contract, contractsDir string, executor CommandExecutor, satisfied func(ref string) bool) RunResult {
 	result := RunResult{
 		Timestamp: time.Now(),
 	}
 
+	type job struct {
+		index      int
+		node       string
+		contractID string
+		check      Check
+		preventive bool
+	}
+	var jobs []job
 	for _, c := range contracts {
-		if c.Type == "preventive" {
+		if c.Type == "preventive" && len(c.Checks) == 0 {
 			result.Skipped++
 			continue
 		}
-
 		for _, ch := range c.Checks {
-			cr := runCheck(ctx, c.ID, ch, contractsDir, executor)
-			result.Results = append(result.Results, cr)
-			if cr.Passed {
-				result.Passed++
-			} else {
-				result.Failed++
+			jobs = append(jobs, job{index: len(jobs), node: c.ID + "/" + ch.Name, contractID: c.ID, check: ch, preventive: c.Type == "preventive"})
+		}
+	}
+
+	graph, _ := buildDeps(contracts, false) // never fails when not strict
+	levels := graph.levels()
+	maxLevel := 0
+	for _, l := range levels {
+		if l > maxLevel {
+			maxLevel = l
+		}
+	}
+	byNode := map[string]int{}
+	for _, j := range jobs {
+		byNode[j.node] = j.index
+	}
+
+	results := make([]CheckResult, len(jobs))
+	workers := Workers
+	if workers < 1 {
+		workers = 1
+	}
+	for level := 0; level <= maxLevel; level++ {
+		var runnable []job
+		for _, j := range jobs {
+			if levels[j.node] != level {
+				continue
+			}
+			var blockers []string
+			for _, d := range graph.deps[j.node] {
+				if !results[byNode[d]].Passed {
+					blockers = append(blockers, d)
+				}
 			}
+			for _, ref := range graph.outside[j.node] {
+				if satisfied != nil && !satisfied(ref) {
+					blockers = append(blockers, ref)
+				}
+			}
+			if len(blockers) > 0 {
+				results[j.index] = CheckResult{ContractID: j.contractID, CheckName: j.check.Name, Preventive: j.preventive,
+					Blocked: true, BlockedBy: blockers, Output: "blocked by " + strings.Join(blockers, ", ")}
+				continue
+			}
+			runnable = append(runnable, j)
+		}
+
+		queue := make(chan job)
+		var wg sync.WaitGroup
+		for w := 0; w < workers && w < len(runnable); w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := range queue {
+					results[j.index] = runCheck(ctx, j.contractID, j.check, contractsDir, executor)
+					results[j.index].Preventive = j.preventive
+				}
+			}()
+		}
+		for _, j := range runnable {
+			queue <- j
+		}
+		close(queue)
+		wg.Wait()
+	}
+
+	for _, cr := range results {
+		result.Results = append(result.Results, cr)
+		switch {
+		case cr.Blocked:
+			result.Blocked++
+		case cr.Preventive && cr.Passed:
+			result.Enforced++
+		case cr.Preventive:
+			result.Drifted++
+		case cr.Passed:
+			result.Passed++
+		default:
+			result.Failed++
 		}
 	}
 
 	return result
 }
 
-// runCheck executes a single check (command or script).
+// checkTimeout returns the timeout a check declares, or DefaultCheckTimeout.
+func checkTimeout(ch Check) time.Duration {
+	var declared string
+	switch {
+	case ch.Command != nil:
+		declared = ch.Command.Timeout
+	case ch.Script != nil:
+		declared = ch.Script.Timeout
+	}
+	if d, err := time.ParseDuration(declared); err == nil && d > 0 {
+		return d
+	}
+	return DefaultCheckTimeout
+}
+
+// runCheck executes a single check (command or script) under its timeout.
 func runCheck(ctx context.Context, contractID string, ch Check, contractsDir string, executor CommandExecutor) CheckResult {
 	start := time.Now()
 
@@ -76,36 +197,36 @@ func runCheck(ctx context.Context, contractID string, ch Check, contractsDir str
 		CheckName:  ch.Name,
 	}
 
-	var command string
-	var checkCtx context.Context
-	var cancel context.CancelFunc
+	checkCtx, cancel := context.WithTimeout(ctx, checkTimeout(ch))
+	defer cancel()
+
+	if n := ch.native(); n != nil {
+		var passed bool
+		var err error
+		cr.Output, passed, err = runNativeCheck(checkCtx, n, executor)
+		cr.Duration = time.Since(start)
+		cr.Passed = passed && err == nil
+		cr.Error = err
+		if err != nil && checkCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			cr.Error = fmt.Errorf("timed out after %s", checkTimeout(ch))
+		}
+		return cr
+	}
 
+	var command string
 	if ch.Command != nil {
 		// Inline command: combine run + test into a single shell invocation
 		command = fmt.Sprintf("RESULT=$(%s); %s", ch.Command.Run, ch.Command.Test)
-		checkCtx = ctx
 	} else if ch.Script != nil {
-		// Script: resolve path, apply timeout
+		// Script: resolve path relative to the contracts dir
 		scriptPath := ch.Script.Path
 		if !filepath.IsAbs(scriptPath) {
 			scriptPath = filepath.Join(contractsDir, scriptPath)
 		}
 		command = "sh " + scriptPath
-
-		if ch.Script.Timeout != "" {
-			if d, err := time.ParseDuration(ch.Script.Timeout); err == nil {
-				checkCtx, cancel = context.WithTimeout(ctx, d)
-				defer cancel()
-			} else {
-				checkCtx = ctx
-			}
-		} else {
-			checkCtx = ctx
-		}
 	}
-
-	if checkCtx == nil {
-		checkCtx = ctx
+	if ch.agent != "" {
+		command = fmt.Sprintf("AGENT='%s'; export AGENT; %s", ch.agent, command)
 	}
 
 	stdout, exitCode, err := executor.Execute(checkCtx, command)
@@ -115,6 +236,9 @@ func runCheck(ctx context.Context, contractID string, ch Check, contractsDir str
 	if err != nil {
 		cr.Passed = false
 		cr.Error = err
+		if checkCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			cr.Error = fmt.Errorf("timed out after %s", checkTimeout(ch))
+		}
 	} else {
 		cr.Passed = exitCode == 0
 	}
@@ -130,15 +254,25 @@ func WriteLog(result RunResult, w io.Writer) {
 	}
 
 	for _, cr := range result.Results {
-		status := "PASS"
-		if !cr.Passed {
-			status = "FAIL"
+		line := fmt.Sprintf("%s [healthcheck] %s %s %s (%dms)",
+			ts, cr.ContractID, cr.Status(), cr.CheckName, cr.Duration.Milliseconds())
+		if cr.Transition != "" {
+			line += " [" + string(cr.Transition) + "]"
 		}
-		line := fmt.Sprintf("%s [healthcheck] %s %s %s (%dms)\n",
-			ts, cr.ContractID, status, cr.CheckName, cr.Duration.Milliseconds())
+		line += "\n"
 		fmt.Fprint(w, line)
 	}
 
-	fmt.Fprintf(w, "%s [healthcheck] summary: %d passed, %d failed, %d skipped\n",
+	summary := fmt.Sprintf("%s [healthcheck] summary: %d passed, %d failed, %d skipped",
 		ts, result.Passed, result.Failed, result.Skipped)
+	if result.Blocked > 0 {
+		summary += fmt.Sprintf(", %d blocked", result.Blocked)
+	}
+	if result.Enforced+result.Drifted > 0 {
+		summary += fmt.Sprintf(", %d enforced, %d drifted", result.Enforced, result.Drifted)
+	}
+	if result.NotDue > 0 {
+		summary += fmt.Sprintf(", %d not due", result.NotDue)
+	}
+	fmt.Fprintln(w, summary)
 }
@@ -4,21 +4,28 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // MockExecutor returns predefined results for commands.
 type MockExecutor struct {
+	mu sync.Mutex
 	// Calls records every command that was executed.
 	Calls []string
 	// ExitCode is the default exit code for all commands.
 	ExitCode int
 	// Overrides maps command substrings to specific exit codes.
 	Overrides map[string]int
+	// Outputs maps command substrings to stdout.
+	Outputs map[string]string
 }
 
 func (m *MockExecutor) Execute(ctx context.Context, command string) (string, int, error) {
+	m.mu.Lock()
 	m.Calls = append(m.Calls, command)
+	m.mu.Unlock()
 
 	// Check for context cancellation (timeout)
 	select {
@@ -27,12 +34,18 @@ func (m *MockExecutor) Execute(ctx context.Context, command string) (string, int
 	default:
 	}
 
+	out := ""
+	for substr, o := range m.Outputs {
+		if strings.Contains(command, substr) {
+			out = o
+		}
+	}
 	for substr, code := range m.Overrides {
 		if strings.Contains(command, substr) {
-			return "", code, nil
+			return out, code, nil
 		}
 	}
-	return "", m.ExitCode, nil
+	return out, m.ExitCode, nil
 }
 
 func TestEvaluate_AllPass(t *testing.T) {
@@ -261,3 +274,104 @@ func TestWriteLog(t *testing.T) {
 		t.Errorf("log should contain summary, got:\n%s", output)
 	}
 }
+
+// sleepExecutor sleeps for the duration named in the command ("sleep 50ms")
+// unless the context ends first.
+type sleepExecutor struct{}
+
+func (sleepExecutor) Execute(ctx context.Context, command string) (string, int, error) {
+	arg, _, _ := strings.Cut(strings.TrimPrefix(command, "RESULT=$(sleep "), ")")
+	d, err := time.ParseDuration(arg)
+	if err != nil {
+		return "", -1, err
+	}
+	select {
+	case <-time.After(d):
+		return command, 0, nil
+	case <-ctx.Done():
+		return "", -1, ctx.Err()
+	}
+}
+
+func TestEvaluate_ParallelDeterministicOrder(t *testing.T) {
+	var contracts []Contract
+	for i := 0; i < 8; i++ {
+		// Earlier checks take longer, so completion order is reversed
+		contracts = append(contracts, Contract{
+			ID:   fmt.Sprintf("CON-%d", i),
+			Type: "detective",
+			Checks: []Check{{
+				Name:    "c",
+				Command: &CmdCheck{Run: fmt.Sprintf("sleep %dms", 80-i*10), Test: "true"},
+			}},
+		})
+	}
+
+	start := time.Now()
+	result := Evaluate(context.Background(), contracts, "/tmp", sleepExecutor{})
+	elapsed := time.Since(start)
+
+	for i, cr := range result.Results {
+		if cr.ContractID != fmt.Sprintf("CON-%d", i) {
+			t.Errorf("result %d is %s, want CON-%d", i, cr.ContractID, i)
+		}
+	}
+	if result.Passed != 8 {
+		t.Errorf("Passed = %d, want 8", result.Passed)
+	}
+	// Sequential would take 80+70+...+10 = 360ms
+	if elapsed > 300*time.Millisecond {
+		t.Errorf("evaluation took %v, checks do not appear to run concurrently", elapsed)
+	}
+}
+
+func TestEvaluate_PerCheckTimeout(t *testing.T) {
+	contracts := []Contract{
+		{
+			ID:   "CON-SLOW",
+			Type: "detective",
+			Checks: []Check{{
+				Name:    "slow",
+				Command: &CmdCheck{Run: "sleep 5s", Test: "true", Timeout: "50ms"},
+			}},
+		},
+		{
+			ID:   "CON-FAST",
+			Type: "detective",
+			Checks: []Check{{
+				Name:    "fast",
+				Command: &CmdCheck{Run: "sleep 1ms", Test: "true"},
+			}},
+		},
+	}
+
+	result := Evaluate(context.Background(), contracts, "/tmp", sleepExecutor{})
+	if result.Results[0].Passed || result.Results[0].Error == nil || !strings.Contains(result.Results[0].Error.Error(), "timed out") {
+		t.Errorf("slow check should time out, got %+v", result.Results[0])
+	}
+	if !result.Results[1].Passed {
+		t.Errorf("fast check should pass despite the slow one, got %+v", result.Results[1])
+	}
+}
+
+func TestCheckTimeoutDefault(t *testing.T) {
+	if got := checkTimeout(Check{Command: &CmdCheck{}}); got != DefaultCheckTimeout {
+		t.Errorf("default timeout = %v, want %v", got, DefaultCheckTimeout)
+	}
+	if got := checkTimeout(Check{Script: &ScriptCheck{Timeout: "10s"}}); got != 10*time.Second {
+		t.Errorf("script timeout = %v, want 10s", got)
+	}
+}
+
+func TestDefaultExecutor_Timeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := (&DefaultExecutor{}).Execute(ctx, "sleep 5 & sleep 5")
+	if err == nil {
+		t.Error("expected error from timed-out command")
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Error("timed-out command should be killed with its children")
+	}
+}
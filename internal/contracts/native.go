@@ -0,0 +1,612 @@
+package contracts
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Proc paths, overridden in tests.
+var (
+	procDir     = "/proc"
+	procMeminfo = "/proc/meminfo"
+	procLoadavg = "/proc/loadavg"
+	procNet     = "/proc/net"
+)
+
+// unitName matches the systemd unit names native checks accept. Units are
+// passed to systemctl through the shell, so only these characters are allowed.
+var unitName = regexp.MustCompile(`^[A-Za-z0-9@._:-]+$`)
+
+// DiskFreeCheck passes when the filesystem holding Path has at least MinPct free.
+type DiskFreeCheck struct {
+	Path   string `yaml:"path"`
+	MinPct int    `yaml:"min_pct"`
+}
+
+// MemFreeCheck passes when MemAvailable is at least MinPct of MemTotal.
+type MemFreeCheck struct {
+	MinPct int `yaml:"min_pct"`
+}
+
+// LoadCheck passes when the 1-minute load average per CPU is at most MaxPerCPU.
+type LoadCheck struct {
+	MaxPerCPU float64 `yaml:"max_per_cpu"`
+}
+
+// FileModeCheck passes when Path has the given owner, group and permission
+// bits, and (with NoACL) carries no extended POSIX ACL entries.
+// Unset fields are not checked. With Optional, a missing file passes.
+type FileModeCheck struct {
+	Path     string `yaml:"path"`
+	Owner    string `yaml:"owner"`
+	Group    string `yaml:"group"`
+	Mode     string `yaml:"mode"` // octal, e.g. "0600"
+	NoACL    bool   `yaml:"no_acl"`
+	Optional bool   `yaml:"optional"`
+}
+
+// FileHashCheck passes when the sha256 of Path equals SHA256 (hex).
+type FileHashCheck struct {
+	Path   string `yaml:"path"`
+	SHA256 string `yaml:"sha256"`
+}
+
+// ProcessRunningCheck passes when a process named Name is running (as User,
+// if set). With Absent, it passes when no such process is running.
+type ProcessRunningCheck struct {
+	Name   string `yaml:"name"`
+	User   string `yaml:"user"`
+	Absent bool   `yaml:"absent"`
+}
+
+// UnitActiveCheck passes when the systemd unit is active.
+type UnitActiveCheck struct {
+	Unit string `yaml:"unit"`
+}
+
+// PortListeningCheck passes when a local socket listens on Port.
+type PortListeningCheck struct {
+	Port  int    `yaml:"port"`
+	Proto string `yaml:"proto"` // tcp (default) | udp
+}
+
+// DirSizeCheck passes when the total size of files under Path is at most Max.
+type DirSizeCheck struct {
+	Path string `yaml:"path"`
+	Max  string `yaml:"max"` // bytes, or with a K/M/G/T suffix
+}
+
+// FileAgeCheck passes when Path was modified no longer than MaxAge ago.
+type FileAgeCheck struct {
+	Path   string `yaml:"path"`
+	MaxAge string `yaml:"max_age"` // Go duration, e.g. "15m"
+}
+
+// nativeCheck is a check implemented in Go rather than a shell command.
+type nativeCheck interface {
+	validate() error
+	// run returns a description of the observed value and whether it passed.
+	run(ctx context.Context, executor CommandExecutor) (string, bool, error)
+}
+
+// kinds returns the check kinds set on ch, by YAML name.
+func (ch Check) kinds() []string {
+	var set []string
+	if ch.Command != nil {
+		set = append(set, "command")
+	}
+	if ch.Script != nil {
+		set = append(set, "script")
+	}
+	for name := range ch.natives() {
+		set = append(set, name)
+	}
+	sort.Strings(set)
+	return set
+}
+
+// natives maps each native kind set on the check to its value.
+func (ch Check) natives() map[string]nativeCheck {
+	m := map[string]nativeCheck{}
+	if ch.DiskFree != nil {
+		m["disk_free"] = ch.DiskFree
+	}
+	if ch.MemFree != nil {
+		m["mem_free"] = ch.MemFree
+	}
+	if ch.Load != nil {
+		m["load"] = ch.Load
+	}
+	if ch.FileMode != nil {
+		m["file_mode"] = ch.FileMode
+	}
+	if ch.FileHash != nil {
+		m["file_hash"] = ch.FileHash
+	}
+	if ch.ProcessRunning != nil {
+		m["process_running"] = ch.ProcessRunning
+	}
+	if ch.UnitActive != nil {
+		m["systemd_unit_active"] = ch.UnitActive
+	}
+	if ch.PortListening != nil {
+		m["port_listening"] = ch.PortListening
+	}
+	if ch.DirSize != nil {
+		m["dir_size"] = ch.DirSize
+	}
+	if ch.FileAge != nil {
+		m["file_age"] = ch.FileAge
+	}
+	if ch.ACL != nil {
+		m["acl"] = ch.ACL
+	}
+	if ch.UnitDirective != nil {
+		m["unit_directive"] = ch.UnitDirective
+	}
+	if ch.Sudoers != nil {
+		m["sudoers"] = ch.Sudoers
+	}
+	return m
+}
+
+// native returns the check's native kind, or nil for command/script checks.
+func (ch Check) native() nativeCheck {
+	for _, n := range ch.natives() {
+		return n
+	}
+	return nil
+}
+
+func (c *DiskFreeCheck) validate() error {
+	if c.Path == "" {
+		return fmt.Errorf("disk_free: path is required")
+	}
+	return validatePct("disk_free", c.MinPct)
+}
+
+func (c *DiskFreeCheck) run(ctx context.Context, _ CommandExecutor) (string, bool, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(c.Path, &st); err != nil {
+		return "", false, fmt.Errorf("statfs %s: %w", c.Path, err)
+	}
+	if st.Blocks == 0 {
+		return "", false, fmt.Errorf("statfs %s: no blocks", c.Path)
+	}
+	free := int(st.Bavail * 100 / st.Blocks)
+	return fmt.Sprintf("%s free %d%% (min %d%%)", c.Path, free, c.MinPct), free >= c.MinPct, nil
+}
+
+func (c *MemFreeCheck) validate() error {
+	return validatePct("mem_free", c.MinPct)
+}
+
+func (c *MemFreeCheck) run(ctx context.Context, _ CommandExecutor) (string, bool, error) {
+	info, err := readMeminfo()
+	if err != nil {
+		return "", false, err
+	}
+	total, available := info["MemTotal"], info["MemAvailable"]
+	if total == 0 {
+		return "", false, fmt.Errorf("%s: MemTotal missing", procMeminfo)
+	}
+	free := int(available * 100 / total)
+	return fmt.Sprintf("memory available %d%% (min %d%%)", free, c.MinPct), free >= c.MinPct, nil
+}
+
+func (c *LoadCheck) validate() error {
+	if c.MaxPerCPU <= 0 {
+		return fmt.Errorf("load: max_per_cpu must be positive")
+	}
+	return nil
+}
+
+func (c *LoadCheck) run(ctx context.Context, _ CommandExecutor) (string, bool, error) {
+	data, err := os.ReadFile(procLoadavg)
+	if err != nil {
+		return "", false, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "", false, fmt.Errorf("%s: empty", procLoadavg)
+	}
+	load, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %w", procLoadavg, err)
+	}
+	perCPU := load / float64(runtime.NumCPU())
+	return fmt.Sprintf("load %.2f per cpu (max %.2f)", perCPU, c.MaxPerCPU), perCPU <= c.MaxPerCPU, nil
+}
+
+func (c *FileModeCheck) validate() error {
+	if c.Path == "" {
+		return fmt.Errorf("file_mode: path is required")
+	}
+	if c.Mode != "" {
+		if _, err := strconv.ParseUint(c.Mode, 8, 32); err != nil {
+			return fmt.Errorf("file_mode: invalid mode %q (want octal, e.g. 0600)", c.Mode)
+		}
+	}
+	if c.Owner == "" && c.Group == "" && c.Mode == "" && !c.NoACL {
+		return fmt.Errorf("file_mode: set at least one of owner, group, mode, no_acl")
+	}
+	return nil
+}
+
+func (c *FileModeCheck) run(ctx context.Context, _ CommandExecutor) (string, bool, error) {
+	info, err := os.Lstat(c.Path)
+	if errors.Is(err, fs.ErrNotExist) && c.Optional {
+		return c.Path + " absent", true, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", false, fmt.Errorf("%s: no ownership information", c.Path)
+	}
+	owner := strconv.Itoa(int(st.Uid))
+	if u, err := user.LookupId(owner); err == nil {
+		owner = u.Username
+	}
+	group := strconv.Itoa(int(st.Gid))
+	if g, err := user.LookupGroupId(group); err == nil {
+		group = g.Name
+	}
+	mode := fmt.Sprintf("%04o", info.Mode().Perm())
+
+	var problems []string
+	if c.Owner != "" && owner != c.Owner {
+		problems = append(problems, fmt.Sprintf("owner %s (want %s)", owner, c.Owner))
+	}
+	if c.Group != "" && group != c.Group {
+		problems = append(problems, fmt.Sprintf("group %s (want %s)", group, c.Group))
+	}
+	if c.Mode != "" {
+		want, _ := strconv.ParseUint(c.Mode, 8, 32)
+		if uint64(info.Mode().Perm()) != want {
+			problems = append(problems, fmt.Sprintf("mode %s (want %04o)", mode, want))
+		}
+	}
+	if c.NoACL && hasACL(c.Path) {
+		problems = append(problems, "has extended ACL")
+	}
+	if len(problems) > 0 {
+		return c.Path + ": " + strings.Join(problems, ", "), false, nil
+	}
+	return fmt.Sprintf("%s %s:%s %s", c.Path, owner, group, mode), true, nil
+}
+
+// hasACL reports whether path carries a POSIX access ACL beyond its mode bits.
+func hasACL(path string) bool {
+	sz, err := syscall.Getxattr(path, "system.posix_acl_access", nil)
+	return err == nil && sz > 0
+}
+
+func (c *FileHashCheck) validate() error {
+	if c.Path == "" {
+		return fmt.Errorf("file_hash: path is required")
+	}
+	if _, err := hex.DecodeString(c.SHA256); err != nil || len(c.SHA256) != 64 {
+		return fmt.Errorf("file_hash: sha256 must be 64 hex characters")
+	}
+	return nil
+}
+
+func (c *FileHashCheck) run(ctx context.Context, _ CommandExecutor) (string, bool, error) {
+	f, err := os.Open(c.Path)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, ctxReader{ctx, f}); err != nil {
+		return "", false, err
+	}
+	got := fmt.Sprintf("%x", h.Sum(nil))
+	return fmt.Sprintf("%s sha256 %s", c.Path, got), strings.EqualFold(got, c.SHA256), nil
+}
+
+func (c *ProcessRunningCheck) validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("process_running: name is required")
+	}
+	return nil
+}
+
+func (c *ProcessRunningCheck) run(ctx context.Context, _ CommandExecutor) (string, bool, error) {
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return "", false, err
+	}
+	var uid string
+	if c.User != "" {
+		u, err := user.Lookup(c.User)
+		if err != nil {
+			return "", false, err
+		}
+		uid = u.Uid
+	}
+	count := 0
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return "", false, ctx.Err()
+		}
+		if _, err := strconv.Atoi(e.Name()); err != nil {
+			continue
+		}
+		dir := filepath.Join(procDir, e.Name())
+		if !processNamed(dir, c.Name) {
+			continue
+		}
+		if uid != "" {
+			info, err := os.Stat(dir)
+			if err != nil {
+				continue
+			}
+			if st, ok := info.Sys().(*syscall.Stat_t); !ok || strconv.Itoa(int(st.Uid)) != uid {
+				continue
+			}
+		}
+		count++
+	}
+	desc := fmt.Sprintf("%d %s process(es) running", count, c.Name)
+	if c.Absent {
+		return desc, count == 0, nil
+	}
+	return desc, count > 0, nil
+}
+
+func (c *UnitActiveCheck) validate() error {
+	if !unitName.MatchString(c.Unit) {
+		return fmt.Errorf("systemd_unit_active: invalid unit %q", c.Unit)
+	}
+	return nil
+}
+
+func (c *UnitActiveCheck) run(ctx context.Context, executor CommandExecutor) (string, bool, error) {
+	out, code, err := executor.Execute(ctx, "systemctl is-active "+c.Unit)
+	if err != nil {
+		return "", false, err
+	}
+	return fmt.Sprintf("%s %s", c.Unit, out), code == 0, nil
+}
+
+func (c *PortListeningCheck) validate() error {
+	if c.Port <= 0 || c.Port > 65535 {
+		return fmt.Errorf("port_listening: invalid port %d", c.Port)
+	}
+	if c.Proto != "" && c.Proto != "tcp" && c.Proto != "udp" {
+		return fmt.Errorf("port_listening: proto must be tcp or udp, got %q", c.Proto)
+	}
+	return nil
+}
+
+func (c *PortListeningCheck) run(ctx context.Context, _ CommandExecutor) (string, bool, error) {
+	proto := c.Proto
+	if proto == "" {
+		proto = "tcp"
+	}
+	// Listening sockets: TCP state 0A (LISTEN), UDP state 07 (unconnected)
+	state := "0A"
+	if proto == "udp" {
+		state = "07"
+	}
+	found, readAny := false, false
+	for _, file := range []string{proto, proto + "6"} {
+		f, err := os.Open(filepath.Join(procNet, file))
+		if err != nil {
+			continue
+		}
+		readAny = true
+		scanner := bufio.NewScanner(f)
+		scanner.Scan() // header
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 4 || fields[3] != state {
+				continue
+			}
+			_, portHex, ok := strings.Cut(fields[1], ":")
+			if port, err := strconv.ParseInt(portHex, 16, 32); ok && err == nil && int(port) == c.Port {
+				found = true
+			}
+		}
+		f.Close()
+	}
+	if !readAny {
+		return "", false, fmt.Errorf("reading %s/%s: no socket tables", procNet, proto)
+	}
+	if found {
+		return fmt.Sprintf("%s/%d listening", proto, c.Port), true, nil
+	}
+	return fmt.Sprintf("%s/%d not listening", proto, c.Port), false, nil
+}
+
+func (c *DirSizeCheck) validate() error {
+	if c.Path == "" {
+		return fmt.Errorf("dir_size: path is required")
+	}
+	if _, err := parseSize(c.Max); err != nil {
+		return fmt.Errorf("dir_size: %w", err)
+	}
+	return nil
+}
+
+func (c *DirSizeCheck) run(ctx context.Context, _ CommandExecutor) (string, bool, error) {
+	max, _ := parseSize(c.Max)
+	var total int64
+	err := filepath.WalkDir(c.Path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return fmt.Sprintf("%s %d bytes (max %d)", c.Path, total, max), total <= max, nil
+}
+
+func (c *FileAgeCheck) validate() error {
+	if c.Path == "" {
+		return fmt.Errorf("file_age: path is required")
+	}
+	if d, err := time.ParseDuration(c.MaxAge); err != nil || d <= 0 {
+		return fmt.Errorf("file_age: invalid max_age %q", c.MaxAge)
+	}
+	return nil
+}
+
+func (c *FileAgeCheck) run(ctx context.Context, _ CommandExecutor) (string, bool, error) {
+	info, err := os.Stat(c.Path)
+	if err != nil {
+		return "", false, err
+	}
+	max, _ := time.ParseDuration(c.MaxAge)
+	age := time.Since(info.ModTime()).Round(time.Second)
+	return fmt.Sprintf("%s modified %s ago (max %s)", c.Path, age, max), age <= max, nil
+}
+
+// commLen is the length the kernel truncates /proc/<pid>/comm to.
+const commLen = 15
+
+// processNamed reports whether the process at dir is called name. comm is
+// truncated to commLen bytes, so longer names are confirmed against the
+// basename of argv[0].
+func processNamed(dir, name string) bool {
+	comm, err := os.ReadFile(filepath.Join(dir, "comm"))
+	if err != nil {
+		return false
+	}
+	if len(name) <= commLen {
+		return strings.TrimSpace(string(comm)) == name
+	}
+	if strings.TrimSpace(string(comm)) != name[:commLen] {
+		return false
+	}
+	cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline"))
+	if err != nil {
+		return false
+	}
+	argv0, _, _ := strings.Cut(string(cmdline), "\x00")
+	return argv0 != "" && filepath.Base(argv0) == name
+}
+
+// ctxReader fails reads once ctx is done, so long copies stop on timeout.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// runNativeCheck runs n under ctx. Native kinds make syscalls that cannot be
+// cancelled (statfs on a hung mount, for one), so when ctx ends first the
+// result is abandoned rather than waited for.
+func runNativeCheck(ctx context.Context, n nativeCheck, executor CommandExecutor) (string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+	type result struct {
+		out    string
+		passed bool
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out, passed, err := n.run(ctx, executor)
+		done <- result{out, passed, err}
+	}()
+	select {
+	case r := <-done:
+		return r.out, r.passed, r.err
+	case <-ctx.Done():
+		return "", false, ctx.Err()
+	}
+}
+
+func validatePct(kind string, pct int) error {
+	if pct < 1 || pct > 100 {
+		return fmt.Errorf("%s: min_pct must be 1-100, got %d", kind, pct)
+	}
+	return nil
+}
+
+// readMeminfo parses /proc/meminfo into kB values.
+func readMeminfo() (map[string]int64, error) {
+	data, err := os.ReadFile(procMeminfo)
+	if err != nil {
+		return nil, err
+	}
+	info := map[string]int64{}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		if v, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			info[key] = v
+		}
+	}
+	return info, nil
+}
+
+// parseSize parses a byte count with an optional K/M/G/T suffix (powers of 1024).
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(strings.ToUpper(s))
+	s = strings.TrimSuffix(s, "B")
+	mult := int64(1)
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		case 'T':
+			mult = 1 << 40
+		}
+		if mult > 1 {
+			s = s[:n-1]
+		}
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return v * mult, nil
+}
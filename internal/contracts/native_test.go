@@ -0,0 +1,249 @@
+package contracts
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withProc points the proc path vars at dir for the duration of the test.
+func withProc(t *testing.T, dir string) {
+	t.Helper()
+	oldDir, oldMem, oldLoad, oldNet := procDir, procMeminfo, procLoadavg, procNet
+	procDir = dir
+	procMeminfo = filepath.Join(dir, "meminfo")
+	procLoadavg = filepath.Join(dir, "loadavg")
+	procNet = filepath.Join(dir, "net")
+	t.Cleanup(func() { procDir, procMeminfo, procLoadavg, procNet = oldDir, oldMem, oldLoad, oldNet })
+}
+
+func runNative(t *testing.T, ch Check) CheckResult {
+	t.Helper()
+	return runCheck(context.Background(), "CON-T", ch, "/tmp", &MockExecutor{})
+}
+
+func TestNative_MemFree(t *testing.T) {
+	dir := t.TempDir()
+	withProc(t, dir)
+	os.WriteFile(procMeminfo, []byte("MemTotal:       1000 kB\nMemFree:         50 kB\nMemAvailable:    200 kB\n"), 0644)
+
+	if cr := runNative(t, Check{MemFree: &MemFreeCheck{MinPct: 10}}); !cr.Passed {
+		t.Errorf("20%% available should pass min 10%%: %+v", cr)
+	}
+	if cr := runNative(t, Check{MemFree: &MemFreeCheck{MinPct: 30}}); cr.Passed {
+		t.Errorf("20%% available should fail min 30%%: %+v", cr)
+	}
+}
+
+func TestNative_Load(t *testing.T) {
+	dir := t.TempDir()
+	withProc(t, dir)
+	load := float64(runtime.NumCPU()) * 1.5
+	os.WriteFile(procLoadavg, []byte(fmt.Sprintf("%.2f 0.50 0.40 1/100 1234\n", load)), 0644)
+
+	if cr := runNative(t, Check{Load: &LoadCheck{MaxPerCPU: 2}}); !cr.Passed {
+		t.Errorf("1.5 per cpu should pass max 2: %+v", cr)
+	}
+	if cr := runNative(t, Check{Load: &LoadCheck{MaxPerCPU: 1}}); cr.Passed {
+		t.Errorf("1.5 per cpu should fail max 1: %+v", cr)
+	}
+}
+
+func TestNative_DiskFree(t *testing.T) {
+	dir := t.TempDir()
+	if cr := runNative(t, Check{DiskFree: &DiskFreeCheck{Path: dir, MinPct: 0}}); !cr.Passed {
+		t.Errorf("min 0%% should always pass: %+v", cr)
+	}
+	if cr := runNative(t, Check{DiskFree: &DiskFreeCheck{Path: "/nonexistent-path", MinPct: 0}}); cr.Passed || cr.Error == nil {
+		t.Errorf("missing path should fail with error: %+v", cr)
+	}
+}
+
+func TestNative_FileMode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "env")
+	os.WriteFile(path, []byte("x"), 0600)
+
+	if cr := runNative(t, Check{FileMode: &FileModeCheck{Path: path, Mode: "0600", NoACL: true}}); !cr.Passed {
+		t.Errorf("mode 0600 should pass: %+v", cr)
+	}
+	os.Chmod(path, 0644)
+	if cr := runNative(t, Check{FileMode: &FileModeCheck{Path: path, Mode: "0600"}}); cr.Passed {
+		t.Errorf("mode 0644 should fail: %+v", cr)
+	}
+
+	missing := filepath.Join(dir, "missing")
+	if cr := runNative(t, Check{FileMode: &FileModeCheck{Path: missing, Mode: "0400", Optional: true}}); !cr.Passed {
+		t.Errorf("optional missing file should pass: %+v", cr)
+	}
+	if cr := runNative(t, Check{FileMode: &FileModeCheck{Path: missing, Mode: "0400"}}); cr.Passed {
+		t.Errorf("required missing file should fail: %+v", cr)
+	}
+}
+
+func TestNative_FileHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f")
+	os.WriteFile(path, []byte("content"), 0644)
+	sum := fmt.Sprintf("%x", sha256.Sum256([]byte("content")))
+
+	if cr := runNative(t, Check{FileHash: &FileHashCheck{Path: path, SHA256: sum}}); !cr.Passed {
+		t.Errorf("matching hash should pass: %+v", cr)
+	}
+	os.WriteFile(path, []byte("tampered"), 0644)
+	if cr := runNative(t, Check{FileHash: &FileHashCheck{Path: path, SHA256: sum}}); cr.Passed {
+		t.Errorf("changed file should fail: %+v", cr)
+	}
+}
+
+func TestNative_ProcessRunning(t *testing.T) {
+	dir := t.TempDir()
+	withProc(t, dir)
+	os.MkdirAll(filepath.Join(dir, "42"), 0755)
+	os.WriteFile(filepath.Join(dir, "42", "comm"), []byte("nginx\n"), 0644)
+	os.MkdirAll(filepath.Join(dir, "self"), 0755)
+
+	if cr := runNative(t, Check{ProcessRunning: &ProcessRunningCheck{Name: "nginx"}}); !cr.Passed {
+		t.Errorf("nginx should be found: %+v", cr)
+	}
+	if cr := runNative(t, Check{ProcessRunning: &ProcessRunningCheck{Name: "picoclaw"}}); cr.Passed {
+		t.Errorf("picoclaw should not be found: %+v", cr)
+	}
+	if cr := runNative(t, Check{ProcessRunning: &ProcessRunningCheck{Name: "picoclaw", Absent: true}}); !cr.Passed {
+		t.Errorf("absent picoclaw should pass: %+v", cr)
+	}
+}
+
+func TestNative_ProcessRunningLongName(t *testing.T) {
+	dir := t.TempDir()
+	withProc(t, dir)
+	os.MkdirAll(filepath.Join(dir, "7"), 0755)
+	os.WriteFile(filepath.Join(dir, "7", "comm"), []byte("tailscaled-help\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "7", "cmdline"), []byte("/usr/sbin/tailscaled-helper\x00--verbose\x00"), 0644)
+
+	if cr := runNative(t, Check{ProcessRunning: &ProcessRunningCheck{Name: "tailscaled-helper"}}); !cr.Passed {
+		t.Errorf("name longer than comm should match argv[0]: %+v", cr)
+	}
+	if cr := runNative(t, Check{ProcessRunning: &ProcessRunningCheck{Name: "tailscaled-helpdesk"}}); cr.Passed {
+		t.Errorf("shared comm prefix should not match: %+v", cr)
+	}
+}
+
+func TestNative_HonoursContext(t *testing.T) {
+	dir := t.TempDir()
+	withProc(t, dir)
+	os.MkdirAll(filepath.Join(dir, "42"), 0755)
+	os.WriteFile(filepath.Join(dir, "42", "comm"), []byte("nginx\n"), 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, ch := range []Check{
+		{ProcessRunning: &ProcessRunningCheck{Name: "nginx"}},
+		{DiskFree: &DiskFreeCheck{Path: dir, MinPct: 1}},
+	} {
+		if cr := runCheck(ctx, "CON-T", ch, "/tmp", &MockExecutor{}); cr.Passed || cr.Error == nil {
+			t.Errorf("cancelled native check should fail: %+v", cr)
+		}
+	}
+
+	f := ctxReader{ctx, strings.NewReader("data")}
+	if _, err := f.Read(make([]byte, 4)); err != context.Canceled {
+		t.Errorf("ctxReader after cancel: got %v", err)
+	}
+}
+
+func TestNative_UnitActive(t *testing.T) {
+	exec := &MockExecutor{Overrides: map[string]int{"con-broken": 3}}
+	cr := runCheck(context.Background(), "CON-T", Check{UnitActive: &UnitActiveCheck{Unit: "con-ok.path"}}, "/tmp", exec)
+	if !cr.Passed || exec.Calls[0] != "systemctl is-active con-ok.path" {
+		t.Errorf("active unit should pass via systemctl: %+v, calls %v", cr, exec.Calls)
+	}
+	cr = runCheck(context.Background(), "CON-T", Check{UnitActive: &UnitActiveCheck{Unit: "con-broken.service"}}, "/tmp", exec)
+	if cr.Passed {
+		t.Errorf("inactive unit should fail: %+v", cr)
+	}
+}
+
+func TestNative_PortListening(t *testing.T) {
+	dir := t.TempDir()
+	withProc(t, dir)
+	os.MkdirAll(procNet, 0755)
+	os.WriteFile(filepath.Join(procNet, "tcp"), []byte(
+		"  sl  local_address rem_address   st tx_queue rx_queue\n"+
+			"   0: 00000000:1F90 00000000:0000 0A 00000000:00000000\n"+ // 8080 LISTEN
+			"   1: 0100007F:0016 0100007F:D431 01 00000000:00000000\n"), 0644) // 22 ESTABLISHED
+
+	if cr := runNative(t, Check{PortListening: &PortListeningCheck{Port: 8080}}); !cr.Passed {
+		t.Errorf("8080 should be listening: %+v", cr)
+	}
+	if cr := runNative(t, Check{PortListening: &PortListeningCheck{Port: 22}}); cr.Passed {
+		t.Errorf("22 is established, not listening: %+v", cr)
+	}
+}
+
+func TestNative_DirSizeAndFileAge(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a"), make([]byte, 2048), 0644)
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "sub", "b"), make([]byte, 2048), 0644)
+
+	if cr := runNative(t, Check{DirSize: &DirSizeCheck{Path: dir, Max: "4K"}}); !cr.Passed {
+		t.Errorf("4096 bytes should pass max 4K: %+v", cr)
+	}
+	if cr := runNative(t, Check{DirSize: &DirSizeCheck{Path: dir, Max: "3K"}}); cr.Passed {
+		t.Errorf("4096 bytes should fail max 3K: %+v", cr)
+	}
+
+	path := filepath.Join(dir, "a")
+	if cr := runNative(t, Check{FileAge: &FileAgeCheck{Path: path, MaxAge: "1h"}}); !cr.Passed {
+		t.Errorf("fresh file should pass: %+v", cr)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(path, old, old)
+	if cr := runNative(t, Check{FileAge: &FileAgeCheck{Path: path, MaxAge: "1h"}}); cr.Passed {
+		t.Errorf("2h-old file should fail max 1h: %+v", cr)
+	}
+}
+
+func TestNative_Validation(t *testing.T) {
+	invalid := []Check{
+		{Name: "c", DiskFree: &DiskFreeCheck{MinPct: 10}},
+		{Name: "c", MemFree: &MemFreeCheck{MinPct: 150}},
+		{Name: "c", DiskFree: &DiskFreeCheck{Path: "/", MinPct: 0}},
+		{Name: "c", Load: &LoadCheck{}},
+		{Name: "c", FileMode: &FileModeCheck{Path: "/x", Mode: "rw-"}},
+		{Name: "c", FileMode: &FileModeCheck{Path: "/x"}},
+		{Name: "c", FileHash: &FileHashCheck{Path: "/x", SHA256: "abc"}},
+		{Name: "c", ProcessRunning: &ProcessRunningCheck{}},
+		{Name: "c", UnitActive: &UnitActiveCheck{Unit: "x; rm -rf /"}},
+		{Name: "c", UnitActive: &UnitActiveCheck{Unit: "x\nid"}},
+		{Name: "c", UnitActive: &UnitActiveCheck{Unit: "x>/etc/passwd"}},
+		{Name: "c", UnitActive: &UnitActiveCheck{Unit: "$(id)"}},
+		{Name: "c", FileHash: &FileHashCheck{Path: "/x", SHA256: strings.Repeat("g", 64)}},
+		{Name: "c", PortListening: &PortListeningCheck{Port: 70000}},
+		{Name: "c", DirSize: &DirSizeCheck{Path: "/x", Max: "lots"}},
+		{Name: "c", FileAge: &FileAgeCheck{Path: "/x", MaxAge: "old"}},
+		{Name: "c", MemFree: &MemFreeCheck{MinPct: 10}, Load: &LoadCheck{MaxPerCPU: 1}},
+		{Name: "c", MemFree: &MemFreeCheck{MinPct: 10}, Command: &CmdCheck{Run: "true", Test: "true"}},
+	}
+	for i, ch := range invalid {
+		c := Contract{ID: "CON-V", Type: "detective", Checks: []Check{ch}}
+		if err := validate(c); err == nil {
+			t.Errorf("case %d: expected validation error for %+v", i, ch)
+		}
+	}
+
+	valid := Contract{ID: "CON-V", Type: "detective", Checks: []Check{
+		{Name: "disk", DiskFree: &DiskFreeCheck{Path: "/", MinPct: 15}},
+		{Name: "size", DirSize: &DirSizeCheck{Path: "/srv/con/logs", Max: "500M"}},
+		{Name: "unit", UnitActive: &UnitActiveCheck{Unit: "con-web@1.service"}},
+	}}
+	if err := validate(valid); err != nil {
+		t.Errorf("valid native checks rejected: %v", err)
+	}
+}
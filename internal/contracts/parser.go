@@ -4,38 +4,55 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 // LoadDir reads all .yaml files from dir and returns parsed contracts.
-func LoadDir(dir string) ([]Contract, error) {
+// ${dotted.name} references in each file are expanded from vars first.
+func LoadDir(dir string, vars map[string]string) ([]Contract, error) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return nil, fmt.Errorf("reading contracts dir: %w", err)
 	}
 
 	var contracts []Contract
+	files := map[string]string{} // id -> file that declared it
 	for _, e := range entries {
 		if e.IsDir() || !strings.HasSuffix(e.Name(), ".yaml") {
 			continue
 		}
-		c, err := LoadFile(filepath.Join(dir, e.Name()))
+		c, err := LoadFile(filepath.Join(dir, e.Name()), vars)
 		if err != nil {
 			return nil, fmt.Errorf("parsing %s: %w", e.Name(), err)
 		}
+		if prev, ok := files[c.ID]; ok {
+			return nil, fmt.Errorf("contract %s declared in both %s and %s", c.ID, prev, e.Name())
+		}
+		files[c.ID] = e.Name()
 		contracts = append(contracts, c)
 	}
+	if err := validateDeps(contracts, true); err != nil {
+		return nil, err
+	}
 	return contracts, nil
 }
 
-// LoadFile reads and parses a single contract YAML file.
-func LoadFile(path string) (Contract, error) {
+// LoadFile reads and parses a single contract YAML file, expanding
+// ${dotted.name} references from vars before parsing.
+func LoadFile(path string, vars map[string]string) (Contract, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return Contract{}, fmt.Errorf("reading %s: %w", path, err)
 	}
+	data, err = expandVars(data, vars)
+	if err != nil {
+		return Contract{}, fmt.Errorf("expanding %s: %w", filepath.Base(path), err)
+	}
 
 	var c Contract
 	if err := yaml.Unmarshal(data, &c); err != nil {
@@ -49,6 +66,34 @@ func LoadFile(path string) (Contract, error) {
 	return c, nil
 }
 
+// varRef matches ${a.b} references. A dot is required so plain shell
+// expansions like ${HOME} in check commands are left alone.
+var varRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z0-9_]+)+)\}`)
+
+// expandVars substitutes ${dotted.name} references with values from vars.
+// Any reference without a value is an error listing every undefined name.
+func expandVars(data []byte, vars map[string]string) ([]byte, error) {
+	undefined := map[string]bool{}
+	out := varRef.ReplaceAllFunc(data, func(ref []byte) []byte {
+		name := string(varRef.FindSubmatch(ref)[1])
+		v, ok := vars[name]
+		if !ok {
+			undefined[name] = true
+			return ref
+		}
+		return []byte(v)
+	})
+	if len(undefined) > 0 {
+		names := make([]string, 0, len(undefined))
+		for n := range undefined {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("undefined variable(s): %s", strings.Join(names, ", "))
+	}
+	return out, nil
+}
+
 // validate checks a contract for structural correctness.
 func validate(c Contract) error {
 	if c.ID == "" {
@@ -57,28 +102,99 @@ func validate(c Contract) error {
 	if c.Type != "detective" && c.Type != "preventive" {
 		return fmt.Errorf("contract %s: type must be 'detective' or 'preventive', got %q", c.ID, c.Type)
 	}
-
-	// Preventive contracts are registry-only, no checks needed
-	if c.Type == "preventive" {
-		return nil
+	if strings.Contains(c.ID, "@") {
+		return fmt.Errorf("contract %s: id must not contain '@' (reserved for per-agent instances)", c.ID)
+	}
+	if err := validateScope(c.Scope); err != nil {
+		return fmt.Errorf("contract %s: %w", c.ID, err)
+	}
+	if c.Frequency != "" {
+		if d, err := time.ParseDuration(c.Frequency); err != nil || d <= 0 {
+			return fmt.Errorf("contract %s: invalid frequency %q", c.ID, c.Frequency)
+		}
+	}
+	// Per-agent contracts are validated as an instance, so $AGENT in unit
+	// names and the like is not rejected
+	if IsTemplate(c.Scope) {
+		c = c.forAgent("agent")
 	}
 
-	// Detective contracts must have at least one check
-	if len(c.Checks) == 0 {
+	// Preventive contracts may be registry-only; their checks, if any,
+	// assert that the mechanism is in place. Detective contracts must
+	// have at least one check.
+	if len(c.Checks) == 0 && c.Type != "preventive" {
 		return fmt.Errorf("contract %s: detective contract must have at least one check", c.ID)
 	}
 
+	// Results, state and depends_on refer to a check by contract ID and name
+	names := map[string]bool{}
 	for i, ch := range c.Checks {
-		if ch.Command == nil && ch.Script == nil {
-			return fmt.Errorf("contract %s check %d (%s): must have command or script", c.ID, i, ch.Name)
+		if names[ch.Name] {
+			return fmt.Errorf("contract %s check %d: duplicate check name %q", c.ID, i, ch.Name)
 		}
-		if ch.Command != nil && ch.Script != nil {
-			return fmt.Errorf("contract %s check %d (%s): cannot have both command and script", c.ID, i, ch.Name)
+		names[ch.Name] = true
+	}
+
+	for i, ch := range c.Checks {
+		switch kinds := ch.kinds(); len(kinds) {
+		case 0:
+			return fmt.Errorf("contract %s check %d (%s): must have command, script or a native check", c.ID, i, ch.Name)
+		case 1:
+		default:
+			return fmt.Errorf("contract %s check %d (%s): cannot have more than one of %s", c.ID, i, ch.Name, strings.Join(kinds, ", "))
+		}
+		if n := ch.native(); n != nil {
+			if err := n.validate(); err != nil {
+				return fmt.Errorf("contract %s check %d (%s): %w", c.ID, i, ch.Name, err)
+			}
+		}
+		if ch.FailAfter < 0 || ch.RecoverAfter < 0 {
+			return fmt.Errorf("contract %s check %d (%s): fail_after and recover_after must not be negative", c.ID, i, ch.Name)
+		}
+		if err := validateTimeout(ch); err != nil {
+			return fmt.Errorf("contract %s check %d (%s): %w", c.ID, i, ch.Name, err)
 		}
 		if ch.OnFail.Action != "" && !validActions[ch.OnFail.Action] {
 			return fmt.Errorf("contract %s check %d (%s): invalid action %q", c.ID, i, ch.Name, ch.OnFail.Action)
 		}
+		if ch.OnRecover.Action != "" && !validRecoverActions[ch.OnRecover.Action] {
+			return fmt.Errorf("contract %s check %d (%s): invalid on_recover action %q", c.ID, i, ch.Name, ch.OnRecover.Action)
+		}
+		for _, ref := range ch.DependsOn {
+			if id, _ := splitRef(ref); id == "" {
+				return fmt.Errorf("contract %s check %d (%s): invalid depends_on %q", c.ID, i, ch.Name, ref)
+			}
+		}
+	}
+	for _, ref := range c.DependsOn {
+		if id, _ := splitRef(ref); id == "" {
+			return fmt.Errorf("contract %s: invalid depends_on %q", c.ID, ref)
+		}
 	}
 
+	// Other contracts are resolved by LoadDir; cycles within this one are
+	// caught here
+	if err := validateDeps([]Contract{c}, false); err != nil {
+		return fmt.Errorf("contract %s: %w", c.ID, err)
+	}
+
+	return nil
+}
+
+// validateTimeout checks that a declared check timeout is a positive duration.
+func validateTimeout(ch Check) error {
+	var timeout string
+	switch {
+	case ch.Command != nil:
+		timeout = ch.Command.Timeout
+	case ch.Script != nil:
+		timeout = ch.Script.Timeout
+	}
+	if timeout == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(timeout); err != nil || d <= 0 {
+		return fmt.Errorf("invalid timeout %q", timeout)
+	}
 	return nil
 }
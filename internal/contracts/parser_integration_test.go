@@ -3,6 +3,7 @@ package contracts
 import (
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -12,22 +13,41 @@ func TestLoadDir_SystemContracts(t *testing.T) {
 	projectRoot := filepath.Join(filepath.Dir(thisFile), "..", "..")
 	contractsDir := filepath.Join(projectRoot, "configs", "default", "contracts")
 
-	contracts, err := LoadDir(contractsDir)
+	// Mirrors config.ContractVars with the con.toml defaults
+	vars := map[string]string{
+		"system.name":                           "conspiracy",
+		"contracts.system.disk_min_free_pct":    "15",
+		"contracts.system.mem_min_free_pct":     "10",
+		"contracts.system.max_load_factor":      "2",
+		"contracts.system.max_session_min":      "30",
+		"contracts.system.healthcheck_interval": "60s",
+	}
+	contracts, err := LoadDir(contractsDir, vars)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if len(contracts) != 8 {
-		t.Errorf("LoadDir returned %d contracts, want 8", len(contracts))
+	if len(contracts) != 12 {
+		t.Errorf("LoadDir returned %d contracts, want 12", len(contracts))
 	}
 
-	// Verify all have IDs and are detective type
+	// Verify all have IDs and checks; the preventive ones assert their mechanism
+	preventive := map[string]bool{"CON-SYS-007": true, "CON-SYS-008": true}
 	for _, c := range contracts {
 		if c.ID == "" {
 			t.Error("contract has empty ID")
 		}
-		if c.Type != "detective" {
-			t.Errorf("contract %s: type = %q, want detective", c.ID, c.Type)
+		if want := map[bool]string{true: "preventive", false: "detective"}[preventive[c.ID]]; c.Type != want {
+			t.Errorf("contract %s: type = %q, want %s", c.ID, c.Type, want)
+		}
+		if len(c.Checks) == 0 {
+			t.Errorf("contract %s has no checks", c.ID)
+		}
+		if c.ID == "CON-SYS-001" && c.Checks[0].DiskFree.MinPct != 15 {
+			t.Errorf("CON-SYS-001 min_pct = %v, want 15 from vars", c.Checks[0].DiskFree.MinPct)
+		}
+		if strings.HasPrefix(c.ID, "CON-AGENT-") && !IsTemplate(c.Scope) {
+			t.Errorf("%s scope = %q, want one instance per agent", c.ID, c.Scope)
 		}
 	}
 }
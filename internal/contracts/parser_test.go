@@ -3,6 +3,7 @@ package contracts
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -61,7 +62,7 @@ func TestLoadFile_Detective(t *testing.T) {
 	dir := t.TempDir()
 	path := writeTemp(t, dir, "CON-SYS-001.yaml", detectiveYAML)
 
-	c, err := LoadFile(path)
+	c, err := LoadFile(path, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -99,7 +100,7 @@ func TestLoadFile_Preventive(t *testing.T) {
 	dir := t.TempDir()
 	path := writeTemp(t, dir, "CON-117.yaml", preventiveYAML)
 
-	c, err := LoadFile(path)
+	c, err := LoadFile(path, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -117,11 +118,48 @@ func TestLoadFile_Preventive(t *testing.T) {
 	}
 }
 
+func TestLoadFile_PreventiveAssertions(t *testing.T) {
+	path := writeTemp(t, t.TempDir(), "CON-118.yaml", `id: CON-118
+description: workers cannot gain privileges
+type: preventive
+mechanism: systemd
+checks:
+  - name: no_new_privileges
+    unit_directive:
+      unit: con-researcher.service
+      directive: NoNewPrivileges
+      value: "yes"
+    on_fail:
+      action: alert
+      escalate: sysadmin
+      message: "CON-118 DRIFTED"
+`)
+	c, err := LoadFile(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Checks) != 1 || c.Checks[0].UnitDirective == nil || c.Checks[0].UnitDirective.Value != "yes" {
+		t.Errorf("Checks = %+v", c.Checks)
+	}
+
+	bad := writeTemp(t, t.TempDir(), "CON-119.yaml", `id: CON-119
+type: preventive
+mechanism: acl
+checks:
+  - name: audit_acl
+    acl:
+      path: /srv/con/logs/audit/
+`)
+	if _, err := LoadFile(bad, nil); err == nil {
+		t.Error("expected validation error for an acl assertion without entries")
+	}
+}
+
 func TestLoadFile_ScriptCheck(t *testing.T) {
 	dir := t.TempDir()
 	path := writeTemp(t, dir, "CON-042.yaml", scriptCheckYAML)
 
-	c, err := LoadFile(path)
+	c, err := LoadFile(path, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -149,7 +187,7 @@ scope: system
 	dir := t.TempDir()
 	path := writeTemp(t, dir, "bad.yaml", yaml)
 
-	_, err := LoadFile(path)
+	_, err := LoadFile(path, nil)
 	if err == nil {
 		t.Error("expected validation error for detective with no checks")
 	}
@@ -169,7 +207,7 @@ checks:
 	dir := t.TempDir()
 	path := writeTemp(t, dir, "bad2.yaml", yaml)
 
-	_, err := LoadFile(path)
+	_, err := LoadFile(path, nil)
 	if err == nil {
 		t.Error("expected validation error for check without command or script")
 	}
@@ -192,7 +230,7 @@ checks:
 	dir := t.TempDir()
 	path := writeTemp(t, dir, "bad3.yaml", yaml)
 
-	_, err := LoadFile(path)
+	_, err := LoadFile(path, nil)
 	if err == nil {
 		t.Error("expected validation error for invalid action")
 	}
@@ -204,7 +242,7 @@ func TestLoadDir(t *testing.T) {
 	writeTemp(t, dir, "CON-117.yaml", preventiveYAML)
 	writeTemp(t, dir, "readme.txt", "not a yaml")
 
-	contracts, err := LoadDir(dir)
+	contracts, err := LoadDir(dir, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -215,7 +253,7 @@ func TestLoadDir(t *testing.T) {
 
 func TestLoadDir_Empty(t *testing.T) {
 	dir := t.TempDir()
-	contracts, err := LoadDir(dir)
+	contracts, err := LoadDir(dir, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -223,3 +261,96 @@ func TestLoadDir_Empty(t *testing.T) {
 		t.Errorf("LoadDir returned %d contracts, want 0", len(contracts))
 	}
 }
+
+func TestLoadFile_ValidationError_InvalidTimeout(t *testing.T) {
+	path := writeTemp(t, t.TempDir(), "timeout.yaml", `id: CON-T
+type: detective
+checks:
+  - name: bad_timeout
+    command:
+      run: echo 1
+      test: "true"
+      timeout: soon
+`)
+	if _, err := LoadFile(path, nil); err == nil {
+		t.Error("expected validation error for invalid timeout")
+	}
+}
+
+func TestLoadFile_ValidationError_InvalidFrequency(t *testing.T) {
+	for _, freq := range []string{"hourly", "0s", "-5m"} {
+		path := writeTemp(t, t.TempDir(), "freq.yaml", `id: CON-F
+type: detective
+frequency: `+freq+`
+checks:
+  - name: c
+    command: {run: echo 1, test: "true"}
+`)
+		if _, err := LoadFile(path, nil); err == nil || !strings.Contains(err.Error(), "invalid frequency") {
+			t.Errorf("frequency %q: expected invalid frequency error, got %v", freq, err)
+		}
+	}
+}
+
+func TestLoadFile_ExpandsVars(t *testing.T) {
+	path := writeTemp(t, t.TempDir(), "vars.yaml", `id: CON-V
+type: detective
+checks:
+  - name: disk
+    disk_free:
+      path: /srv/con
+      min_pct: ${contracts.system.disk_min_free_pct}
+    on_fail:
+      action: alert
+      message: "disk below ${contracts.system.disk_min_free_pct}% (home ${HOME})"
+`)
+	c, err := LoadFile(path, map[string]string{"contracts.system.disk_min_free_pct": "25"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Checks[0].DiskFree.MinPct; got != 25 {
+		t.Errorf("MinPct = %v, want 25", got)
+	}
+	if got := c.Checks[0].OnFail.Message; got != "disk below 25% (home ${HOME})" {
+		t.Errorf("Message = %q, want shell ${HOME} left alone", got)
+	}
+}
+
+func TestLoadDir_UndefinedVar(t *testing.T) {
+	dir := t.TempDir()
+	writeTemp(t, dir, "CON-V.yaml", `id: CON-V
+type: detective
+checks:
+  - name: mem
+    mem_free:
+      min_pct: ${contracts.system.typo}
+`)
+	_, err := LoadDir(dir, map[string]string{"contracts.system.mem_min_free_pct": "10"})
+	if err == nil || !strings.Contains(err.Error(), "contracts.system.typo") {
+		t.Errorf("expected undefined variable error naming contracts.system.typo, got %v", err)
+	}
+}
+
+func TestLoadFile_ValidationError_DuplicateCheckName(t *testing.T) {
+	path := writeTemp(t, t.TempDir(), "dup.yaml", `id: CON-D
+type: detective
+checks:
+  - name: disk
+    command: {run: echo 1, test: "true"}
+  - name: disk
+    command: {run: echo 2, test: "true"}
+`)
+	if _, err := LoadFile(path, nil); err == nil || !strings.Contains(err.Error(), "duplicate check name") {
+		t.Errorf("expected duplicate check name error, got %v", err)
+	}
+}
+
+func TestLoadDir_DuplicateContractID(t *testing.T) {
+	dir := t.TempDir()
+	writeTemp(t, dir, "CON-SYS-001.yaml", detectiveYAML)
+	writeTemp(t, dir, "CON-SYS-001-copy.yaml", detectiveYAML)
+	_, err := LoadDir(dir, nil)
+	if err == nil || !strings.Contains(err.Error(), "CON-SYS-001-copy.yaml") {
+		t.Errorf("expected duplicate contract id error naming both files, got %v", err)
+	}
+}
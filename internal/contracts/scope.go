@@ -0,0 +1,152 @@
+package contracts
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/ConspiracyOS/agent-runner/internal/config"
+)
+
+// agentRef matches $AGENT and ${AGENT} in agent-scoped contracts.
+var agentRef = regexp.MustCompile(`\$\{AGENT\}|\$AGENT\b`)
+
+// validTiers are the tiers a tier:<tier> scope may name.
+var validTiers = map[string]bool{"officer": true, "operator": true, "worker": true}
+
+// IsTemplate reports whether scope expands to one contract per agent:
+// "agent:*" for every agent, "tier:<tier>" for the agents of a tier.
+func IsTemplate(scope string) bool {
+	return scope == "agent:*" || strings.HasPrefix(scope, "tier:")
+}
+
+// matches reports whether a template scope selects agent.
+func matches(scope string, agent AgentInfo) bool {
+	if scope == "agent:*" {
+		return true
+	}
+	tier, ok := strings.CutPrefix(scope, "tier:")
+	return ok && tier == agent.Tier
+}
+
+// BaseID is the contract's ID without the @<agent> suffix of an instance.
+func (c Contract) BaseID() string {
+	id, _, _ := strings.Cut(c.ID, "@")
+	return id
+}
+
+// Expand replaces each agent:* and tier:<tier> contract with one instance
+// per matching agent: ID "<id>@<agent>", scope "agent:<agent>" (so actions
+// such as quarantine target that agent), and $AGENT replaced by the agent's
+// name throughout. Commands and scripts also get AGENT in their
+// environment. depends_on references to a template resolve to the
+// instance for the same agent, or to every instance. Agents whose names
+// are not [a-z0-9-]+ get no instances. Other contracts are returned as
+// they are.
+func Expand(contracts []Contract, agents []AgentInfo) []Contract {
+	instances := map[string][]string{} // template ID -> agents
+	var out []Contract
+	for _, c := range contracts {
+		if !IsTemplate(c.Scope) {
+			out = append(out, c)
+			continue
+		}
+		instances[c.ID] = []string{}
+		for _, a := range agents {
+			// $AGENT is substituted into shell commands: only valid names get instances
+			if !matches(c.Scope, a) || !config.ValidAgentName(a.Name) {
+				continue
+			}
+			inst := c.forAgent(a.Name)
+			inst.ID = c.ID + "@" + a.Name
+			inst.Scope = "agent:" + a.Name
+			out = append(out, inst)
+			instances[c.ID] = append(instances[c.ID], a.Name)
+		}
+	}
+
+	resolve := func(refs []string, agent string) []string {
+		var resolved []string
+		for _, ref := range refs {
+			id, check := splitRef(ref)
+			agents, ok := instances[id]
+			if !ok {
+				resolved = append(resolved, ref)
+				continue
+			}
+			if agent != "" && contains(agents, agent) {
+				agents = []string{agent}
+			}
+			for _, a := range agents {
+				r := id + "@" + a
+				if check != "" {
+					r += "/" + check
+				}
+				resolved = append(resolved, r)
+			}
+		}
+		return resolved
+	}
+	for i := range out {
+		out[i].DependsOn = resolve(out[i].DependsOn, out[i].Instance)
+		for j := range out[i].Checks {
+			out[i].Checks[j].DependsOn = resolve(out[i].Checks[j].DependsOn, out[i].Instance)
+		}
+	}
+	return out
+}
+
+// forAgent returns a deep copy of c with $AGENT replaced by agent.
+func (c Contract) forAgent(agent string) Contract {
+	inst := substitute(reflect.ValueOf(c), agent).Interface().(Contract)
+	inst.Instance = agent
+	for i := range inst.Checks {
+		inst.Checks[i].agent = agent
+	}
+	return inst
+}
+
+// substitute deep-copies v, replacing $AGENT in every string.
+func substitute(v reflect.Value, agent string) reflect.Value {
+	switch v.Kind() {
+	case reflect.String:
+		out := reflect.New(v.Type()).Elem()
+		out.SetString(agentRef.ReplaceAllLiteralString(v.String(), agent))
+		return out
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type().Elem())
+		out.Elem().Set(substitute(v.Elem(), agent))
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(substitute(v.Index(i), agent))
+		}
+		return out
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v) // unexported fields are copied as they are
+		for i := 0; i < v.NumField(); i++ {
+			if out.Field(i).CanSet() {
+				out.Field(i).Set(substitute(v.Field(i), agent))
+			}
+		}
+		return out
+	}
+	return v
+}
+
+// validateScope checks a template scope names a known tier.
+func validateScope(scope string) error {
+	if tier, ok := strings.CutPrefix(scope, "tier:"); ok && !validTiers[tier] {
+		return fmt.Errorf("invalid scope %q (tier must be officer/operator/worker)", scope)
+	}
+	return nil
+}
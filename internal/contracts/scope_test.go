@@ -0,0 +1,130 @@
+package contracts
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+var scopeAgents = []AgentInfo{
+	{Name: "concierge", Tier: "operator", Mode: "on-demand"},
+	{Name: "researcher", Tier: "worker", Mode: "on-demand"},
+	{Name: "scraper", Tier: "worker", Mode: "cron"},
+}
+
+func TestExpand(t *testing.T) {
+	inbox := Contract{ID: "CON-INBOX", Type: "detective", Scope: "agent:*", Checks: []Check{{
+		Name:     "inbox_mode",
+		FileMode: &FileModeCheck{Path: "/srv/con/agents/$AGENT/inbox", Mode: "0770"},
+		OnFail:   FailAction{Action: "quarantine", Message: "inbox of ${AGENT} drifted"},
+	}}}
+	workers := Contract{ID: "CON-WORK", Type: "detective", Scope: "tier:worker", Checks: []Check{{
+		Name:      "session",
+		Command:   &CmdCheck{Run: "cat /srv/con/agents/$AGENT/run.json", Test: "true"},
+		DependsOn: []string{"CON-INBOX/inbox_mode"},
+	}}}
+	system := Contract{ID: "CON-SYS", Type: "detective", Scope: "system", DependsOn: []string{"CON-WORK"},
+		Checks: []Check{{Name: "disk", Command: &CmdCheck{Run: "true", Test: "true"}}}}
+
+	got := Expand([]Contract{inbox, workers, system}, scopeAgents)
+
+	var ids []string
+	for _, c := range got {
+		ids = append(ids, c.ID)
+	}
+	if want := "CON-INBOX@concierge,CON-INBOX@researcher,CON-INBOX@scraper,CON-WORK@researcher,CON-WORK@scraper,CON-SYS"; strings.Join(ids, ",") != want {
+		t.Fatalf("ids = %v, want %s", ids, want)
+	}
+
+	researcher := got[1]
+	if researcher.Scope != "agent:researcher" || researcher.Instance != "researcher" || researcher.BaseID() != "CON-INBOX" {
+		t.Errorf("instance = %+v", researcher)
+	}
+	if p := researcher.Checks[0].FileMode.Path; p != "/srv/con/agents/researcher/inbox" {
+		t.Errorf("path = %q, want $AGENT substituted", p)
+	}
+	if m := researcher.Checks[0].OnFail.Message; m != "inbox of researcher drifted" {
+		t.Errorf("message = %q", m)
+	}
+	// The template itself is untouched
+	if inbox.Checks[0].FileMode.Path != "/srv/con/agents/$AGENT/inbox" {
+		t.Error("Expand must not modify the template")
+	}
+
+	// Dependencies on a template resolve to the same agent's instance, or to all
+	if deps := got[3].Checks[0].DependsOn; len(deps) != 1 || deps[0] != "CON-INBOX@researcher/inbox_mode" {
+		t.Errorf("worker deps = %v", deps)
+	}
+	if deps := got[5].DependsOn; strings.Join(deps, ",") != "CON-WORK@researcher,CON-WORK@scraper" {
+		t.Errorf("system deps = %v", deps)
+	}
+}
+
+func TestExpandedCommandGetsAgent(t *testing.T) {
+	c := Contract{ID: "CON-RUN", Type: "detective", Scope: "agent:*", Checks: []Check{{
+		Name:    "probe",
+		Command: &CmdCheck{Run: "ls /srv/con/agents/$AGENT", Test: "true"},
+	}}}
+	exec := &MockExecutor{}
+	Evaluate(context.Background(), Expand([]Contract{c}, scopeAgents[:1]), "/tmp", exec)
+
+	if len(exec.Calls) != 1 || !strings.HasPrefix(exec.Calls[0], "AGENT='concierge'; export AGENT; ") ||
+		!strings.Contains(exec.Calls[0], "ls /srv/con/agents/concierge") {
+		t.Errorf("calls = %v", exec.Calls)
+	}
+}
+
+func TestExpandSkipsUnsafeAgentNames(t *testing.T) {
+	c := Contract{ID: "CON-RUN", Type: "detective", Scope: "agent:*", Checks: []Check{{
+		Name:    "probe",
+		Command: &CmdCheck{Run: "ls /srv/con/agents/$AGENT", Test: "true"},
+	}}}
+	agents := []AgentInfo{{Name: "x'; rm -rf /; '"}, {Name: "Bad_Name"}, {Name: "ok-1"}}
+	got := Expand([]Contract{c}, agents)
+	if len(got) != 1 || got[0].ID != "CON-RUN@ok-1" {
+		t.Errorf("instances = %+v, want only CON-RUN@ok-1", got)
+	}
+}
+
+func TestDispatchTargetsFailingInstance(t *testing.T) {
+	withQuarantineDirs(t)
+	c := Expand([]Contract{{ID: "CON-Q", Type: "detective", Scope: "tier:worker", Checks: []Check{{
+		Name:   "egress",
+		OnFail: FailAction{Action: "quarantine", Message: "$AGENT reached a blocked host"},
+	}}}}, scopeAgents)[1]
+
+	exec := &MockExecutor{}
+	d := &Dispatcher{Executor: exec}
+	if _, err := d.Fail(context.Background(), c.ID, c.Checks[0], c.Scope); err != nil {
+		t.Fatal(err)
+	}
+	joined := strings.Join(exec.Calls, "\n")
+	if !strings.Contains(joined, "con-scraper") || strings.Contains(joined, "con-researcher") {
+		t.Errorf("quarantine should target only scraper:\n%s", joined)
+	}
+}
+
+func TestValidateTemplateScope(t *testing.T) {
+	dir := t.TempDir()
+	ok := writeTemp(t, dir, "ok.yaml", `id: CON-U
+type: detective
+scope: agent:*
+checks:
+  - name: unit
+    systemd_unit_active:
+      unit: con-$AGENT.path
+`)
+	if _, err := LoadFile(ok, nil); err != nil {
+		t.Errorf("$AGENT in a unit name of an agent:* contract: %v", err)
+	}
+	bad := writeTemp(t, dir, "bad.yaml", `id: CON-U
+type: detective
+scope: tier:intern
+checks:
+  - name: c
+    command: {run: "true", test: "true"}
+`)
+	if _, err := LoadFile(bad, nil); err == nil {
+		t.Error("expected an error for an unknown tier")
+	}
+}
@@ -0,0 +1,295 @@
+package contracts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DefaultStatePath is where the healthcheck persists contract state between
+// timer ticks. It lives outside /srv/con so it is not part of git snapshots.
+const DefaultStatePath = "/var/lib/con/contracts-state.json"
+
+// dueSlack lets a contract run on a timer tick that fires slightly before
+// its frequency has fully elapsed, so a 60s contract on a 60s timer does
+// not skip every other tick.
+const dueSlack = 5 * time.Second
+
+// State is the healthcheck's persisted memory of past runs.
+type State struct {
+	Contracts   map[string]*ContractState   `json:"contracts"`
+	Escalations map[string]*EscalationState `json:"escalations,omitempty"`
+
+	path string
+}
+
+// ContractState is the persisted state of one contract.
+type ContractState struct {
+	LastRun time.Time              `json:"last_run"`
+	Checks  map[string]*CheckState `json:"checks,omitempty"`
+}
+
+// CheckState is the run history of one check. Failing is the debounced
+// state: it flips only after fail_after consecutive failures or
+// recover_after consecutive passes, so a flapping check does not trigger
+// its action on every run.
+type CheckState struct {
+	Failing             bool      `json:"failing"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	ConsecutivePasses   int       `json:"consecutive_passes"`
+	FirstFailedAt       time.Time `json:"first_failed_at,omitempty"`
+	LastFailedAt        time.Time `json:"last_failed_at,omitempty"`
+	LastPassedAt        time.Time `json:"last_passed_at,omitempty"`
+
+	// Units this check's halt changed, so on_recover restarts exactly those.
+	// Quarantine keeps its own record (see package quarantine).
+	Halted []string `json:"halted,omitempty"`
+
+	// Holding is every unit (or unit pattern) this check's halt keeps down,
+	// running or not. While the check is failing no other check's recovery
+	// starts them (see Dispatcher.Recover).
+	Holding []string `json:"holding,omitempty"`
+}
+
+// LoadState reads the state file at path. A missing file yields empty state.
+// On a read or parse error the returned state is still usable (empty), so a
+// corrupt file is replaced on the next Save.
+func LoadState(path string) (*State, error) {
+	empty := &State{Contracts: map[string]*ContractState{}, path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return empty, nil
+	}
+	if err != nil {
+		return empty, fmt.Errorf("reading contract state: %w", err)
+	}
+	s := &State{path: path}
+	if err := json.Unmarshal(data, s); err != nil {
+		return empty, fmt.Errorf("parsing contract state %s: %w", path, err)
+	}
+	if s.Contracts == nil {
+		s.Contracts = map[string]*ContractState{}
+	}
+	return s, nil
+}
+
+// Save writes the state back to the file it was loaded from (atomic rename).
+func (s *State) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// contract returns the state for id, creating it if needed.
+func (s *State) contract(id string) *ContractState {
+	cs, ok := s.Contracts[id]
+	if !ok {
+		cs = &ContractState{}
+		s.Contracts[id] = cs
+	}
+	return cs
+}
+
+// check returns the state for a check of contract id, creating it if needed.
+func (s *State) check(id, name string) *CheckState {
+	cs := s.contract(id)
+	if cs.Checks == nil {
+		cs.Checks = map[string]*CheckState{}
+	}
+	st, ok := cs.Checks[name]
+	if !ok {
+		st = &CheckState{}
+		cs.Checks[name] = st
+	}
+	return st
+}
+
+// Record folds one run of ch into its state and returns the resulting
+// transition, if any.
+func (s *State) Record(contractID string, ch Check, passed bool, now time.Time) Transition {
+	st := s.check(contractID, ch.Name)
+	if passed {
+		st.ConsecutivePasses++
+		st.ConsecutiveFailures = 0
+		st.LastPassedAt = now
+		if st.Failing && st.ConsecutivePasses >= atLeastOne(ch.RecoverAfter) {
+			st.Failing = false
+			st.FirstFailedAt = time.Time{}
+			return TransitionRecovered
+		}
+		return ""
+	}
+
+	if st.ConsecutiveFailures == 0 {
+		st.FirstFailedAt = now
+	}
+	st.ConsecutiveFailures++
+	st.ConsecutivePasses = 0
+	st.LastFailedAt = now
+	if !st.Failing && st.ConsecutiveFailures >= atLeastOne(ch.FailAfter) {
+		st.Failing = true
+		return TransitionFailed
+	}
+	return ""
+}
+
+// Retry reverts the state flip of transition t for a check whose action
+// could not be dispatched, so the next run reports the transition, and
+// dispatches its action, again.
+func (s *State) Retry(contractID, name string, t Transition) {
+	st := s.check(contractID, name)
+	switch t {
+	case TransitionFailed:
+		st.Failing = false
+	case TransitionRecovered:
+		st.Failing = true
+	}
+}
+
+// Prune forgets the state of contracts and checks that are no longer
+// loaded, so a check that was removed while failing does not hold units
+// down (see Dispatcher.Recover) for good.
+func (s *State) Prune(contracts []Contract) {
+	loaded := map[string]map[string]bool{}
+	for _, c := range contracts {
+		names := map[string]bool{}
+		for _, ch := range c.Checks {
+			names[ch.Name] = true
+		}
+		loaded[c.ID] = names
+	}
+	for id, cs := range s.Contracts {
+		names, ok := loaded[id]
+		if !ok {
+			delete(s.Contracts, id)
+			continue
+		}
+		for name := range cs.Checks {
+			if !names[name] {
+				delete(cs.Checks, name)
+			}
+		}
+	}
+}
+
+func atLeastOne(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// FailingChecks returns "<contract>/<check>" for each check of contracts
+// that is currently failing, sorted. Checks of contracts that are no
+// longer loaded are ignored.
+func (s *State) FailingChecks(contracts []Contract) []string {
+	var failing []string
+	for _, c := range contracts {
+		cs, ok := s.Contracts[c.ID]
+		if !ok {
+			continue
+		}
+		for _, ch := range c.Checks {
+			if st, ok := cs.Checks[ch.Name]; ok && st.Failing {
+				failing = append(failing, c.ID+"/"+ch.Name)
+			}
+		}
+	}
+	sort.Strings(failing)
+	return failing
+}
+
+// failing reports whether a depends_on reference is failing: the check,
+// or any check of the contract.
+func (s *State) failing(ref string) bool {
+	id, check := splitRef(ref)
+	cs, ok := s.Contracts[id]
+	if !ok {
+		return false
+	}
+	for name, st := range cs.Checks {
+		if (check == "" || name == check) && st.Failing {
+			return true
+		}
+	}
+	return false
+}
+
+// Due reports whether c should run at now given its frequency. Contracts
+// with no frequency, or that have never run, are always due. (Parsing
+// rejects a frequency that is not a positive duration.)
+func (s *State) Due(c Contract, now time.Time) bool {
+	freq, err := time.ParseDuration(c.Frequency)
+	if err != nil || freq <= 0 {
+		return true
+	}
+	cs, ok := s.Contracts[c.ID]
+	if !ok || cs.LastRun.IsZero() {
+		return true
+	}
+	return !now.Before(cs.LastRun.Add(freq - dueSlack))
+}
+
+// EvaluateDue runs the contracts that are due according to state,
+// records their run time and check history, and sets each result's
+// Transition. Contracts that are not due are counted in RunResult.NotDue.
+// The caller saves the state.
+func EvaluateDue(ctx context.Context, contracts []Contract, contractsDir string, executor CommandExecutor, state *State) RunResult {
+	return evaluateRecorded(ctx, contracts, contractsDir, executor, state, false)
+}
+
+// EvaluateNow is EvaluateDue for contracts that were explicitly selected:
+// they run whatever their frequency.
+func EvaluateNow(ctx context.Context, contracts []Contract, contractsDir string, executor CommandExecutor, state *State) RunResult {
+	return evaluateRecorded(ctx, contracts, contractsDir, executor, state, true)
+}
+
+func evaluateRecorded(ctx context.Context, contracts []Contract, contractsDir string, executor CommandExecutor, state *State, force bool) RunResult {
+	now := time.Now()
+	var due []Contract
+	notDue := 0
+	for _, c := range contracts {
+		if force || len(c.Checks) == 0 || state.Due(c, now) {
+			due = append(due, c)
+		} else {
+			notDue++
+		}
+	}
+
+	// Dependencies outside this run (not due) count as satisfied unless
+	// their last known state is failing
+	result := evaluate(ctx, due, contractsDir, executor, func(ref string) bool { return !state.failing(ref) })
+	result.NotDue = notDue
+	checks := map[string]Check{}
+	for _, c := range due {
+		if len(c.Checks) == 0 {
+			continue // registry-only preventive contract
+		}
+		state.contract(c.ID).LastRun = now
+		for _, ch := range c.Checks {
+			checks[c.ID+"/"+ch.Name] = ch
+		}
+	}
+	for i, cr := range result.Results {
+		if cr.Blocked {
+			continue // not run: its history and debounced state are unchanged
+		}
+		if ch, ok := checks[cr.ContractID+"/"+cr.CheckName]; ok {
+			result.Results[i].Transition = state.Record(cr.ContractID, ch, cr.Passed, now)
+		}
+	}
+	return result
+}
@@ -0,0 +1,201 @@
+package contracts
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStateDue(t *testing.T) {
+	s, err := LoadState(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	hourly := Contract{ID: "CON-H", Frequency: "1h"}
+	noFreq := Contract{ID: "CON-N"}
+
+	if !s.Due(hourly, now) {
+		t.Error("never-run contract should be due")
+	}
+	s.contract("CON-H").LastRun = now.Add(-30 * time.Minute)
+	if s.Due(hourly, now) {
+		t.Error("contract run 30m ago with 1h frequency should not be due")
+	}
+	s.contract("CON-H").LastRun = now.Add(-time.Hour + 2*time.Second)
+	if !s.Due(hourly, now) {
+		t.Error("contract within slack of its frequency should be due")
+	}
+	s.contract("CON-N").LastRun = now
+	if !s.Due(noFreq, now) {
+		t.Error("contract without frequency should always be due")
+	}
+}
+
+func TestStateSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "state.json")
+	s, _ := LoadState(path)
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.contract("CON-1").LastRun = ts
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	if !loaded.Contracts["CON-1"].LastRun.Equal(ts) {
+		t.Errorf("LastRun = %v, want %v", loaded.Contracts["CON-1"].LastRun, ts)
+	}
+
+	// Corrupt file: usable empty state plus an error
+	os.WriteFile(path, []byte("{not json"), 0644)
+	loaded, err = LoadState(path)
+	if err == nil || loaded == nil || len(loaded.Contracts) != 0 {
+		t.Errorf("corrupt state: got %v, %v; want empty state and error", loaded, err)
+	}
+}
+
+func TestEvaluateDue(t *testing.T) {
+	check := []Check{{Name: "c", Command: &CmdCheck{Run: "true", Test: "true"}}}
+	contracts := []Contract{
+		{ID: "CON-FAST", Type: "detective", Frequency: "60s", Checks: check},
+		{ID: "CON-SLOW", Type: "detective", Frequency: "1h", Checks: check},
+	}
+	state, _ := LoadState(filepath.Join(t.TempDir(), "state.json"))
+	state.contract("CON-SLOW").LastRun = time.Now().Add(-10 * time.Minute)
+	state.contract("CON-FAST").LastRun = time.Now().Add(-2 * time.Minute)
+
+	exec := &MockExecutor{}
+	result := EvaluateDue(context.Background(), contracts, "/tmp", exec, state)
+
+	if len(result.Results) != 1 || result.Results[0].ContractID != "CON-FAST" {
+		t.Fatalf("expected only CON-FAST to run, got %+v", result.Results)
+	}
+	if result.NotDue != 1 {
+		t.Errorf("NotDue = %d, want 1", result.NotDue)
+	}
+	if time.Since(state.Contracts["CON-FAST"].LastRun) > time.Second {
+		t.Error("CON-FAST LastRun should be updated")
+	}
+	if time.Since(state.Contracts["CON-SLOW"].LastRun) < 5*time.Minute {
+		t.Error("CON-SLOW LastRun should be unchanged")
+	}
+}
+
+func TestEvaluateNowIgnoresFrequency(t *testing.T) {
+	check := []Check{{Name: "c", Command: &CmdCheck{Run: "true", Test: "true"}}}
+	contracts := []Contract{{ID: "CON-SLOW", Type: "detective", Frequency: "1h", Checks: check}}
+	state, _ := LoadState(filepath.Join(t.TempDir(), "state.json"))
+	state.contract("CON-SLOW").LastRun = time.Now().Add(-10 * time.Minute)
+
+	result := EvaluateNow(context.Background(), contracts, "/tmp", &MockExecutor{}, state)
+	if len(result.Results) != 1 || result.NotDue != 0 {
+		t.Fatalf("expected CON-SLOW to run, got %+v", result)
+	}
+	if time.Since(state.Contracts["CON-SLOW"].LastRun) > time.Second {
+		t.Error("CON-SLOW LastRun should be updated")
+	}
+}
+
+func TestStateRecord_FailAfterRecoverAfter(t *testing.T) {
+	s, _ := LoadState(filepath.Join(t.TempDir(), "state.json"))
+	ch := Check{Name: "c", FailAfter: 3, RecoverAfter: 2}
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(i int) time.Time { return t0.Add(time.Duration(i) * time.Minute) }
+
+	// Flapping below fail_after never transitions
+	for i, passed := range []bool{false, false, true, false, false, true} {
+		if tr := s.Record("CON-F", ch, passed, at(i)); tr != "" {
+			t.Fatalf("run %d: unexpected transition %q while flapping", i, tr)
+		}
+	}
+
+	// Third consecutive failure transitions once, further failures are quiet
+	var got []Transition
+	for i := 6; i < 10; i++ {
+		got = append(got, s.Record("CON-F", ch, false, at(i)))
+	}
+	if got[0] != "" || got[1] != "" || got[2] != TransitionFailed || got[3] != "" {
+		t.Errorf("transitions = %v, want failed on the third failure only", got)
+	}
+	st := s.Contracts["CON-F"].Checks["c"]
+	if st.ConsecutiveFailures != 4 || !st.FirstFailedAt.Equal(at(6)) || !st.LastFailedAt.Equal(at(9)) {
+		t.Errorf("state = %+v", st)
+	}
+
+	// One pass is not enough to recover with recover_after: 2
+	if tr := s.Record("CON-F", ch, true, at(10)); tr != "" {
+		t.Errorf("single pass: transition %q, want none", tr)
+	}
+	if tr := s.Record("CON-F", ch, true, at(11)); tr != TransitionRecovered {
+		t.Errorf("second pass: transition %q, want recovered", tr)
+	}
+	if st.Failing || !st.FirstFailedAt.IsZero() || !st.LastPassedAt.Equal(at(11)) {
+		t.Errorf("after recovery state = %+v", st)
+	}
+}
+
+func TestStateRetry(t *testing.T) {
+	s, _ := LoadState(filepath.Join(t.TempDir(), "state.json"))
+	ch := Check{Name: "c"}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if tr := s.Record("CON-R", ch, false, now); tr != TransitionFailed {
+		t.Fatalf("transition = %q, want failed", tr)
+	}
+	// The on_fail action could not be dispatched: the next failure reports it again
+	s.Retry("CON-R", "c", TransitionFailed)
+	if tr := s.Record("CON-R", ch, false, now.Add(time.Minute)); tr != TransitionFailed {
+		t.Errorf("after retry: transition = %q, want failed again", tr)
+	}
+
+	if tr := s.Record("CON-R", ch, true, now.Add(2*time.Minute)); tr != TransitionRecovered {
+		t.Fatalf("transition = %q, want recovered", tr)
+	}
+	s.Retry("CON-R", "c", TransitionRecovered)
+	if tr := s.Record("CON-R", ch, true, now.Add(3*time.Minute)); tr != TransitionRecovered {
+		t.Errorf("after retry: transition = %q, want recovered again", tr)
+	}
+}
+
+func TestStatePrune(t *testing.T) {
+	s, _ := LoadState(filepath.Join(t.TempDir(), "state.json"))
+	s.check("CON-GONE", "c").Holding = []string{"con-*.service"}
+	s.check("CON-KEEP", "old").Failing = true
+	s.check("CON-KEEP", "c").Failing = true
+
+	s.Prune([]Contract{{ID: "CON-KEEP", Checks: []Check{{Name: "c"}}}})
+	if _, ok := s.Contracts["CON-GONE"]; ok {
+		t.Error("state of a removed contract should be pruned")
+	}
+	if checks := s.Contracts["CON-KEEP"].Checks; len(checks) != 1 || checks["c"] == nil {
+		t.Errorf("checks = %v, want only the loaded check", checks)
+	}
+}
+
+func TestEvaluateDue_Transitions(t *testing.T) {
+	contracts := []Contract{{ID: "CON-T", Type: "detective", Checks: []Check{
+		{Name: "c", Command: &CmdCheck{Run: "probe", Test: "true"}},
+	}}}
+	path := filepath.Join(t.TempDir(), "state.json")
+	state, _ := LoadState(path)
+
+	failing := &MockExecutor{ExitCode: 1}
+	if r := EvaluateDue(context.Background(), contracts, "/tmp", failing, state); r.Results[0].Transition != TransitionFailed {
+		t.Errorf("first failure: transition %q, want failed", r.Results[0].Transition)
+	}
+	state.Save()
+
+	// Persisted across runs: a second failure is not a new transition
+	state, _ = LoadState(path)
+	if r := EvaluateDue(context.Background(), contracts, "/tmp", failing, state); r.Results[0].Transition != "" {
+		t.Errorf("repeat failure: transition %q, want none", r.Results[0].Transition)
+	}
+	if r := EvaluateDue(context.Background(), contracts, "/tmp", &MockExecutor{}, state); r.Results[0].Transition != TransitionRecovered {
+		t.Errorf("pass after failure: transition %q, want recovered", r.Results[0].Transition)
+	}
+}
@@ -8,27 +8,68 @@ type Contract struct {
 	Description string  `yaml:"description"`
 	Type        string  `yaml:"type"`      // "detective" | "preventive"
 	Frequency   string  `yaml:"frequency"` // e.g. "60s"
-	Scope       string  `yaml:"scope"`     // "system" | "agent:<name>"
+	Scope       string  `yaml:"scope"`     // "system" | "agent:<name>" | "agent:*" | "tier:<tier>"
 	Checks      []Check `yaml:"checks"`
 
-	// Preventive-only fields (for registry/auditability)
+	// DependsOn lists contracts ("CON-X") or checks ("CON-X/check") that
+	// must pass before any of this contract's checks is meaningful.
+	DependsOn []string `yaml:"depends_on,omitempty"`
+
+	// Instance is the agent an agent:* or tier:<tier> contract was expanded
+	// for (see Expand); empty otherwise.
+	Instance string `yaml:"-"`
+
+	// Preventive-only fields (for registry/auditability). A preventive
+	// contract's checks assert that its mechanism is in place; without
+	// checks it is registry-only.
 	Mechanism   string `yaml:"mechanism,omitempty"`
 	Agent       string `yaml:"agent,omitempty"`
 	Enforcement string `yaml:"enforcement,omitempty"`
 }
 
-// Check is a single check within a detective contract.
+// Check is a single check within a contract. Exactly one kind is
+// set: a shell command, a script, or one of the native kinds (see native.go).
 type Check struct {
 	Name    string       `yaml:"name"`
 	Command *CmdCheck    `yaml:"command,omitempty"`
 	Script  *ScriptCheck `yaml:"script,omitempty"`
-	OnFail  FailAction   `yaml:"on_fail"`
+
+	DiskFree       *DiskFreeCheck       `yaml:"disk_free,omitempty"`
+	MemFree        *MemFreeCheck        `yaml:"mem_free,omitempty"`
+	Load           *LoadCheck           `yaml:"load,omitempty"`
+	FileMode       *FileModeCheck       `yaml:"file_mode,omitempty"`
+	FileHash       *FileHashCheck       `yaml:"file_hash,omitempty"`
+	ProcessRunning *ProcessRunningCheck `yaml:"process_running,omitempty"`
+	UnitActive     *UnitActiveCheck     `yaml:"systemd_unit_active,omitempty"`
+	PortListening  *PortListeningCheck  `yaml:"port_listening,omitempty"`
+	DirSize        *DirSizeCheck        `yaml:"dir_size,omitempty"`
+	FileAge        *FileAgeCheck        `yaml:"file_age,omitempty"`
+
+	// Assertions, mainly for preventive contracts (see assertions.go)
+	ACL           *ACLCheck           `yaml:"acl,omitempty"`
+	UnitDirective *UnitDirectiveCheck `yaml:"unit_directive,omitempty"`
+	Sudoers       *SudoersCheck       `yaml:"sudoers,omitempty"`
+
+	// FailAfter and RecoverAfter debounce flapping checks: the check is
+	// only considered failing after FailAfter consecutive failed runs and
+	// recovered after RecoverAfter consecutive passes. Both default to 1.
+	FailAfter    int `yaml:"fail_after,omitempty"`
+	RecoverAfter int `yaml:"recover_after,omitempty"`
+
+	// DependsOn is Contract.DependsOn for this check only.
+	DependsOn []string `yaml:"depends_on,omitempty"`
+
+	agent string // exported to commands and scripts as AGENT (see Expand)
+
+	OnFail    FailAction    `yaml:"on_fail"`
+	OnRecover RecoverAction `yaml:"on_recover,omitempty"`
 }
 
 // CmdCheck: inline shell command + test expression.
 type CmdCheck struct {
-	Run  string `yaml:"run"`  // shell command that produces $RESULT
-	Test string `yaml:"test"` // test expression using $RESULT
+	Run     string `yaml:"run"`     // shell command that produces $RESULT
+	Test    string `yaml:"test"`    // test expression using $RESULT
+	Timeout string `yaml:"timeout"` // e.g. "10s"; default DefaultCheckTimeout
 }
 
 // ScriptCheck: external script.
@@ -42,6 +83,17 @@ type FailAction struct {
 	Action   string `yaml:"action"`   // halt_agents | halt_workers | kill_session | quarantine | alert
 	Escalate string `yaml:"escalate"` // agent name to receive escalation task
 	Message  string `yaml:"message"`
+
+	// Exclude lists agents that halt_agents/halt_workers leave running,
+	// e.g. the sysadmin needed to fix the problem.
+	Exclude []string `yaml:"exclude,omitempty"`
+}
+
+// RecoverAction defines what happens when a failing check passes again.
+type RecoverAction struct {
+	Action   string `yaml:"action"`   // restart | lift_quarantine | notify
+	Escalate string `yaml:"escalate"` // agent name to notify
+	Message  string `yaml:"message"`
 }
 
 // CheckResult captures the outcome of one check execution.
@@ -52,15 +104,56 @@ type CheckResult struct {
 	Output     string
 	Error      error
 	Duration   time.Duration
+
+	// Preventive marks an assertion of a preventive contract: it is
+	// reported as enforced or drifted rather than passed or failed.
+	Preventive bool
+
+	// Blocked is set when a dependency did not pass: the check was not run,
+	// is neither passed nor failed, and its actions are not dispatched.
+	Blocked   bool
+	BlockedBy []string
+
+	// Transition is set by EvaluateDue when this run changed the check's
+	// debounced state (see CheckState). Empty when nothing changed.
+	Transition Transition
 }
 
+// Transition is a change in a check's debounced pass/fail state.
+type Transition string
+
+const (
+	TransitionFailed    Transition = "failed"
+	TransitionRecovered Transition = "recovered"
+)
+
 // RunResult captures the outcome of a full healthcheck run.
 type RunResult struct {
 	Timestamp time.Time
 	Results   []CheckResult
 	Passed    int
 	Failed    int
-	Skipped   int // preventive contracts
+	Skipped   int // preventive contracts without assertions
+	NotDue    int // contracts whose frequency has not elapsed
+	Enforced  int // preventive assertions that hold
+	Drifted   int // preventive assertions that do not
+	Blocked   int // checks not run because a dependency did not pass
+}
+
+// Status is the log word for a check result: PASS or FAIL, ENFORCED or
+// DRIFTED for a preventive assertion, or BLOCKED.
+func (cr CheckResult) Status() string {
+	switch {
+	case cr.Blocked:
+		return "BLOCKED"
+	case cr.Preventive && cr.Passed:
+		return "ENFORCED"
+	case cr.Preventive:
+		return "DRIFTED"
+	case cr.Passed:
+		return "PASS"
+	}
+	return "FAIL"
 }
 
 // Valid failure actions.
@@ -71,3 +164,11 @@ var validActions = map[string]bool{
 	"quarantine":   true,
 	"alert":        true,
 }
+
+// Valid recovery actions. restart only restarts units the check's own
+// halt stopped; lift_quarantine undoes the check's quarantine.
+var validRecoverActions = map[string]bool{
+	"restart":         true,
+	"lift_quarantine": true,
+	"notify":          true,
+}
@@ -0,0 +1,284 @@
+// Package quarantine isolates an agent reversibly: it records the agent's
+// ACLs and units, stops and masks the units, sets its pending tasks aside
+// and strips its inbox ACLs, and Release restores exactly that prior state.
+package quarantine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Dir holds one subdirectory per quarantined agent (root-only).
+var Dir = "/var/lib/con/quarantine"
+
+// AgentsDir is the root of the agents' directories.
+var AgentsDir = "/srv/con/agents"
+
+// Executor runs a shell command and returns its output and exit code.
+// contracts.DefaultExecutor satisfies it.
+type Executor interface {
+	Execute(ctx context.Context, command string) (string, int, error)
+}
+
+// Marker records why an agent is quarantined and what was changed.
+type Marker struct {
+	Agent   string    `json:"agent"`
+	Reason  string    `json:"reason"`
+	Source  string    `json:"source,omitempty"` // e.g. the contract that triggered it
+	Since   time.Time `json:"since"`
+	Method  string    `json:"method,omitempty"`  // how the units were masked (MaskRuntime)
+	Units   []string  `json:"units"`             // units that were masked
+	Enabled []string  `json:"enabled,omitempty"` // units that were enabled, disabled until release
+	Active  []string  `json:"active,omitempty"`  // units that were running, restarted on release
+	Held    []string  `json:"held,omitempty"`    // inbox tasks moved aside
+	Failed  string    `json:"failed,omitempty"`  // the isolation step that failed, if any
+}
+
+// MaskRuntime masks units under /run/systemd/system. A persistent mask
+// would clash with the unit files bootstrap installs in /etc/systemd/system,
+// and a runtime mask does not survive a reboot, so enabled units are also
+// disabled.
+const MaskRuntime = "mask --runtime"
+
+// Isolated reports whether every isolation step succeeded.
+func (m Marker) Isolated() bool { return m.Failed == "" }
+
+const (
+	markerFile = "marker.json"
+	aclFile    = "acl"
+	heldDir    = "held"
+)
+
+func agentDir(agent string) string { return filepath.Join(Dir, agent) }
+
+func inboxDir(agent string) string { return filepath.Join(AgentsDir, agent, "inbox") }
+
+// units returns the agent's installed systemd units, triggers first so
+// nothing restarts the service while it is being stopped, and the ones
+// among them that are enabled. The service is always generated by
+// bootstrap, so it is the fallback when systemd cannot be asked.
+func units(ctx context.Context, executor Executor, agent string) (found, enabled []string) {
+	svc := "con-" + agent
+	candidates := []string{svc + ".path", svc + ".timer", svc + ".service"}
+	out, code, err := executor.Execute(ctx, "systemctl list-unit-files --plain --no-legend "+strings.Join(candidates, " "))
+	state := map[string]string{}
+	if err == nil && code == 0 {
+		for _, line := range strings.Split(out, "\n") {
+			if fields := strings.Fields(line); len(fields) > 1 {
+				state[fields[0]] = fields[1]
+			} else if len(fields) == 1 {
+				state[fields[0]] = ""
+			}
+		}
+	}
+	for _, u := range candidates {
+		st, ok := state[u]
+		if !ok {
+			continue
+		}
+		found = append(found, u)
+		if st == "enabled" {
+			enabled = append(enabled, u)
+		}
+	}
+	if len(found) == 0 {
+		return []string{svc + ".service"}, nil
+	}
+	return found, enabled
+}
+
+// unitNames returns the first field of each line of systemctl --plain output.
+func unitNames(out string) []string {
+	var names []string
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			names = append(names, fields[0])
+		}
+	}
+	return names
+}
+
+// Quarantine isolates agent and returns the commands it ran. Quarantining
+// an agent that is already quarantined is an error so the recorded prior
+// state is never overwritten with the isolated one. If an isolation step
+// fails the marker records it (see Marker.Isolated); the record is kept so
+// what did change can still be released.
+func Quarantine(ctx context.Context, executor Executor, agent, reason, source string) ([]string, error) {
+	if _, err := Get(agent); err == nil {
+		return nil, fmt.Errorf("agent %s is already quarantined", agent)
+	}
+	dir := agentDir(agent)
+	if err := os.MkdirAll(filepath.Join(dir, heldDir), 0700); err != nil {
+		return nil, fmt.Errorf("creating quarantine dir: %w", err)
+	}
+	m := Marker{Agent: agent, Reason: reason, Source: source, Since: time.Now().UTC(), Method: MaskRuntime}
+	m.Units, m.Enabled = units(ctx, executor, agent)
+	var cmds []string
+
+	// Snapshot before changing anything
+	base := filepath.Join(AgentsDir, agent) + "/"
+	acl, code, err := executor.Execute(ctx, fmt.Sprintf("getfacl -p %s %s/", base, inboxDir(agent)))
+	if err != nil || code != 0 {
+		return nil, fmt.Errorf("snapshotting ACLs of %s: exit %d: %v", agent, code, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, aclFile), []byte(acl), 0600); err != nil {
+		return nil, fmt.Errorf("writing ACL snapshot: %w", err)
+	}
+	if out, code, err := executor.Execute(ctx, "systemctl list-units --state=active --plain --no-legend "+strings.Join(m.Units, " ")); err == nil && code == 0 {
+		m.Active = unitNames(out)
+	}
+
+	// Write the marker first: from here on a partial quarantine can still be released
+	if err := writeMarker(m); err != nil {
+		return nil, err
+	}
+
+	steps := []string{"systemctl " + MaskRuntime + " --now " + strings.Join(m.Units, " ")}
+	if len(m.Enabled) > 0 {
+		steps = append(steps, "systemctl disable "+strings.Join(m.Enabled, " "))
+	}
+	steps = append(steps, fmt.Sprintf("setfacl -b %s/", inboxDir(agent)))
+	for _, cmd := range steps {
+		cmds = append(cmds, cmd)
+		if _, code, err := executor.Execute(ctx, cmd); err != nil || code != 0 {
+			err = fmt.Errorf("%s: exit %d: %v", cmd, code, err)
+			m.Failed = err.Error()
+			if werr := writeMarker(m); werr != nil {
+				return cmds, fmt.Errorf("%w (recording the failure: %v)", err, werr)
+			}
+			return cmds, err
+		}
+	}
+
+	held, err := moveTasks(inboxDir(agent), filepath.Join(dir, heldDir))
+	m.Held = held
+	if werr := writeMarker(m); werr != nil && err == nil {
+		err = werr
+	}
+	if err != nil {
+		return cmds, fmt.Errorf("holding inbox tasks: %w", err)
+	}
+	return cmds, nil
+}
+
+// Release restores a quarantined agent: held tasks go back to its inbox,
+// its ACLs are restored from the snapshot, its units are unmasked and the
+// ones that were running are started again. The quarantine record is
+// removed only when everything succeeded.
+func Release(ctx context.Context, executor Executor, agent string) ([]string, error) {
+	m, err := Get(agent)
+	if err != nil {
+		return nil, err
+	}
+	dir := agentDir(agent)
+	var cmds []string
+
+	if _, err := moveTasks(filepath.Join(dir, heldDir), inboxDir(agent)); err != nil {
+		return nil, fmt.Errorf("restoring held tasks: %w", err)
+	}
+	// Markers written before Method was recorded used a persistent mask
+	unmask := "systemctl unmask "
+	if m.Method == MaskRuntime {
+		unmask = "systemctl unmask --runtime "
+	}
+	steps := []string{
+		"setfacl --restore=" + filepath.Join(dir, aclFile),
+		unmask + strings.Join(m.Units, " "),
+	}
+	if len(m.Enabled) > 0 {
+		steps = append(steps, "systemctl enable "+strings.Join(m.Enabled, " "))
+	}
+	if len(m.Active) > 0 {
+		steps = append(steps, "systemctl start "+strings.Join(m.Active, " "))
+	}
+	for _, cmd := range steps {
+		cmds = append(cmds, cmd)
+		if _, code, err := executor.Execute(ctx, cmd); err != nil || code != 0 {
+			return cmds, fmt.Errorf("%s: exit %d: %v", cmd, code, err)
+		}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return cmds, fmt.Errorf("removing quarantine record: %w", err)
+	}
+	return cmds, nil
+}
+
+// Get returns the marker of a quarantined agent.
+func Get(agent string) (Marker, error) {
+	var m Marker
+	data, err := os.ReadFile(filepath.Join(agentDir(agent), markerFile))
+	if os.IsNotExist(err) {
+		return m, fmt.Errorf("agent %s is not quarantined", agent)
+	}
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("parsing quarantine marker for %s: %w", agent, err)
+	}
+	return m, nil
+}
+
+// List returns the markers of all quarantined agents, sorted by agent.
+func List() ([]Marker, error) {
+	entries, err := os.ReadDir(Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var markers []Marker
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		m, err := Get(e.Name())
+		if err != nil {
+			continue // directory without a marker, e.g. a half-removed record
+		}
+		markers = append(markers, m)
+	}
+	sort.Slice(markers, func(i, j int) bool { return markers[i].Agent < markers[j].Agent })
+	return markers, nil
+}
+
+func writeMarker(m Marker) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(agentDir(m.Agent), markerFile), append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("writing quarantine marker: %w", err)
+	}
+	return nil
+}
+
+// moveTasks moves every .task file from src to dst and returns their names.
+// Rename keeps ownership and mode, so released tasks keep their trust level.
+func moveTasks(src, dst string) ([]string, error) {
+	entries, err := os.ReadDir(src)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var moved []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".task") {
+			continue
+		}
+		if err := os.Rename(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+			return moved, err
+		}
+		moved = append(moved, e.Name())
+	}
+	return moved, nil
+}
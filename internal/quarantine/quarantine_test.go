@@ -0,0 +1,149 @@
+package quarantine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeExecutor records commands and answers systemctl/getfacl queries.
+type fakeExecutor struct {
+	calls   []string
+	outputs map[string]string // command prefix -> output
+	fail    string            // command prefix that exits 1
+}
+
+func (f *fakeExecutor) Execute(ctx context.Context, command string) (string, int, error) {
+	f.calls = append(f.calls, command)
+	if f.fail != "" && strings.HasPrefix(command, f.fail) {
+		return "Failed", 1, nil
+	}
+	for prefix, out := range f.outputs {
+		if strings.HasPrefix(command, prefix) {
+			return out, 0, nil
+		}
+	}
+	return "", 0, nil
+}
+
+func setup(t *testing.T) string {
+	t.Helper()
+	oldDir, oldAgents := Dir, AgentsDir
+	Dir, AgentsDir = t.TempDir(), t.TempDir()
+	t.Cleanup(func() { Dir, AgentsDir = oldDir, oldAgents })
+	inbox := filepath.Join(AgentsDir, "scraper", "inbox")
+	if err := os.MkdirAll(inbox, 0700); err != nil {
+		t.Fatal(err)
+	}
+	return inbox
+}
+
+func TestQuarantineAndRelease(t *testing.T) {
+	inbox := setup(t)
+	os.WriteFile(filepath.Join(inbox, "001.task"), []byte("pending"), 0600)
+	os.WriteFile(filepath.Join(inbox, "notes.txt"), []byte("not a task"), 0600)
+
+	acl := "# file: srv/con/agents/scraper/inbox/\nuser::rwx\nuser:a-concierge:rwx\n"
+	exec := &fakeExecutor{outputs: map[string]string{
+		"systemctl list-unit-files": "con-scraper.path enabled enabled\ncon-scraper.service static -\n",
+		"systemctl list-units":      "con-scraper.path loaded active waiting inbox watcher\n",
+		"getfacl":                   acl,
+	}}
+
+	cmds, err := Quarantine(context.Background(), exec, "scraper", "exfiltration attempt", "CON-AGENT-009")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmds[0] != "systemctl mask --runtime --now con-scraper.path con-scraper.service" {
+		t.Errorf("mask cmd = %q, want only installed units, triggers first", cmds[0])
+	}
+	if cmds[1] != "systemctl disable con-scraper.path" {
+		t.Errorf("disable cmd = %q, want the enabled trigger", cmds[1])
+	}
+	if _, err := os.Stat(filepath.Join(inbox, "001.task")); !os.IsNotExist(err) {
+		t.Error("pending task should be moved out of the inbox")
+	}
+	if _, err := os.Stat(filepath.Join(inbox, "notes.txt")); err != nil {
+		t.Error("non-task files should stay in the inbox")
+	}
+
+	m, err := Get("scraper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Reason != "exfiltration attempt" || m.Source != "CON-AGENT-009" || len(m.Held) != 1 || len(m.Active) != 1 || !m.Isolated() {
+		t.Errorf("marker = %+v", m)
+	}
+	if list, _ := List(); len(list) != 1 || list[0].Agent != "scraper" {
+		t.Errorf("List = %+v, want scraper", list)
+	}
+	if _, err := Quarantine(context.Background(), exec, "scraper", "again", ""); err == nil {
+		t.Error("quarantining twice should fail so the snapshot is not overwritten")
+	}
+
+	cmds, err = Release(context.Background(), exec, "scraper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"setfacl --restore=" + filepath.Join(Dir, "scraper", "acl"),
+		"systemctl unmask --runtime con-scraper.path con-scraper.service",
+		"systemctl enable con-scraper.path",
+		"systemctl start con-scraper.path",
+	}
+	if strings.Join(cmds, "\n") != strings.Join(want, "\n") {
+		t.Errorf("release cmds = %q, want %q", cmds, want)
+	}
+	if data, err := os.ReadFile(filepath.Join(inbox, "001.task")); err != nil || string(data) != "pending" {
+		t.Errorf("held task not restored: %q, %v", data, err)
+	}
+	if list, _ := List(); len(list) != 0 {
+		t.Errorf("List after release = %+v, want empty", list)
+	}
+}
+
+func TestQuarantine_SnapshotACL(t *testing.T) {
+	setup(t)
+	acl := "# file: inbox/\nuser:a-sysadmin:rwx\n"
+	exec := &fakeExecutor{outputs: map[string]string{"getfacl": acl}}
+	if _, err := Quarantine(context.Background(), exec, "scraper", "r", ""); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(filepath.Join(Dir, "scraper", "acl"))
+	if string(data) != acl {
+		t.Errorf("ACL snapshot = %q, want %q", data, acl)
+	}
+	// Without a unit listing the always-present service is masked
+	if !strings.Contains(strings.Join(exec.calls, "\n"), "systemctl mask --runtime --now con-scraper.service") {
+		t.Errorf("calls = %v, want service masked", exec.calls)
+	}
+}
+
+func TestRelease_NotQuarantined(t *testing.T) {
+	setup(t)
+	if _, err := Release(context.Background(), &fakeExecutor{}, "scraper"); err == nil {
+		t.Error("expected error releasing an agent that is not quarantined")
+	}
+}
+
+func TestQuarantine_FailedIsolation(t *testing.T) {
+	inbox := setup(t)
+	os.WriteFile(filepath.Join(inbox, "001.task"), []byte("pending"), 0600)
+	exec := &fakeExecutor{fail: "systemctl mask"}
+
+	if _, err := Quarantine(context.Background(), exec, "scraper", "r", ""); err == nil {
+		t.Fatal("expected an error when the units cannot be masked")
+	}
+	m, err := Get("scraper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Isolated() || !strings.Contains(m.Failed, "systemctl mask --runtime") {
+		t.Errorf("marker = %+v, want the failed step recorded", m)
+	}
+	if _, err := os.Stat(filepath.Join(inbox, "001.task")); err != nil {
+		t.Error("tasks should not be held when isolation failed")
+	}
+}
@@ -0,0 +1,89 @@
+// Package redact masks secrets in agent output before it is persisted.
+//
+// Two passes are applied: exact matches of known secret values (from the
+// agent's scoped env and the encrypted store), then regular expressions for
+// common credential formats that may have reached the agent some other way.
+package redact
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// minValueLen is the shortest known secret value that is masked verbatim.
+// Shorter values ("1", "true") would mangle ordinary text.
+const minValueLen = 8
+
+// patterns match common credential formats regardless of their source.
+var patterns = []*regexp.Regexp{
+	regexp.MustCompile(`sk-[A-Za-z0-9_-]{20,}`),                 // OpenAI / Anthropic / OpenRouter
+	regexp.MustCompile(`gh[pousr]_[A-Za-z0-9]{36,}`),            // GitHub tokens
+	regexp.MustCompile(`github_pat_[A-Za-z0-9_]{22,}`),          // GitHub fine-grained PAT
+	regexp.MustCompile(`AKIA[0-9A-Z]{16}`),                      // AWS access key ID
+	regexp.MustCompile(`xox[abprs]-[A-Za-z0-9-]{10,}`),          // Slack
+	regexp.MustCompile(`tskey-[A-Za-z0-9-]{10,}`),               // Tailscale auth key
+	regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._~+/-]{20,}=*`), // HTTP bearer tokens
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+}
+
+// Redactor masks known secret values and credential patterns.
+// A nil *Redactor applies the pattern pass only.
+type Redactor struct {
+	names  []string // parallel to values, for the mask label
+	values []string
+}
+
+// New returns a Redactor for the given secret name → value map.
+// Values shorter than minValueLen are ignored.
+func New(secrets map[string]string) *Redactor {
+	r := &Redactor{}
+	type kv struct{ name, value string }
+	var entries []kv
+	for name, value := range secrets {
+		if len(value) >= minValueLen {
+			entries = append(entries, kv{name, value})
+		}
+	}
+	// Longest first, so a secret that contains another is masked whole
+	sort.Slice(entries, func(i, j int) bool {
+		if len(entries[i].value) != len(entries[j].value) {
+			return len(entries[i].value) > len(entries[j].value)
+		}
+		return entries[i].name < entries[j].name
+	})
+	for _, e := range entries {
+		r.names = append(r.names, e.name)
+		r.values = append(r.values, e.value)
+	}
+	return r
+}
+
+// Redact returns s with secrets masked and the number of masked occurrences.
+// Known values are replaced with [REDACTED:<name>], pattern matches with [REDACTED].
+func (r *Redactor) Redact(s string) (string, int) {
+	s, count := r.RedactValues(s)
+	for _, re := range patterns {
+		s = re.ReplaceAllStringFunc(s, func(string) string {
+			count++
+			return "[REDACTED]"
+		})
+	}
+	return s, count
+}
+
+// RedactValues is Redact without the pattern pass: only known secret values
+// are masked, so text that merely looks like a credential is left alone.
+func (r *Redactor) RedactValues(s string) (string, int) {
+	count := 0
+	if r == nil {
+		return s, 0
+	}
+	for i, v := range r.values {
+		if n := strings.Count(s, v); n > 0 {
+			s = strings.ReplaceAll(s, v, "[REDACTED:"+r.names[i]+"]")
+			count += n
+		}
+	}
+	return s, count
+}
@@ -0,0 +1,164 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/ConspiracyOS/agent-runner/internal/assembler"
+	"github.com/ConspiracyOS/agent-runner/internal/config"
+	"github.com/ConspiracyOS/agent-runner/internal/session"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	if got := EstimateTokens(""); got != 0 {
+		t.Errorf("EstimateTokens(\"\") = %d, want 0", got)
+	}
+	if got := EstimateTokens("abcde"); got != 2 {
+		t.Errorf("EstimateTokens(5 bytes) = %d, want 2", got)
+	}
+}
+
+func TestContextWindow(t *testing.T) {
+	tests := []struct {
+		agent config.AgentConfig
+		want  int
+	}{
+		{config.AgentConfig{Model: "anthropic/claude-sonnet-4-6"}, 200000},
+		{config.AgentConfig{Model: "gpt-4.1-mini"}, 1047576},
+		{config.AgentConfig{Model: "openai/gpt-4o"}, 128000},
+		{config.AgentConfig{Model: "unknown-model"}, defaultContextWindow},
+		{config.AgentConfig{Model: "claude-opus", ContextWindow: 50000}, 50000},
+	}
+	for _, tt := range tests {
+		if got := ContextWindow(tt.agent); got != tt.want {
+			t.Errorf("ContextWindow(%q) = %d, want %d", tt.agent.Model, got, tt.want)
+		}
+	}
+}
+
+func TestBuildPromptFits(t *testing.T) {
+	task := Task{Content: "do the thing", Trust: TrustVerified}
+	skills := []Skill{{Name: "alpha", Content: "alpha content"}}
+	p, _ := BuildPrompt(PromptParts{AgentsMD: "Base." + assembler.AgentLayerSep + "Agent.\n", Skills: skills, Task: task}, 100000)
+
+	if len(p.Dropped) != 0 {
+		t.Errorf("nothing should be dropped, got %v", p.Dropped)
+	}
+	want := "Context (your instructions):\n\nBase." + agentsMDSep + "Agent.\n" +
+		"\n\n---\n\n# Skills Reference\n\n\n## Skill: alpha\n\nalpha content" +
+		FrameTaskPrompt(task)
+	if p.Text != want {
+		t.Errorf("prompt =\n%q\nwant\n%q", p.Text, want)
+	}
+}
+
+func TestBuildPromptDropOrder(t *testing.T) {
+	big := func(tag string) string { return tag + strings.Repeat(" x", 2000) }
+	agentsMD := strings.Join([]string{big("BASE"), big("ROLE"), big("SCOPE")}, agentsMDSep) + assembler.AgentLayerSep + "AGENT\n"
+	skills := []Skill{{Name: "a", Content: big("SKILLA")}, {Name: "b", Content: big("SKILLB")}}
+	task := Task{Content: "TASK", Trust: TrustVerified}
+
+	// Room for roughly two big sections plus the agent layer and task
+	p, _ := BuildPrompt(PromptParts{AgentsMD: agentsMD, Skills: skills, Task: task}, 2300)
+	wantDropped := []string{"skill:b", "skill:a", "layer:1"}
+	if strings.Join(p.Dropped, ",") != strings.Join(wantDropped, ",") {
+		t.Errorf("dropped = %v, want %v", p.Dropped, wantDropped)
+	}
+	for _, want := range []string{"BASE", "SCOPE", "AGENT", "TASK"} {
+		if !strings.Contains(p.Text, want) {
+			t.Errorf("prompt should keep %s", want)
+		}
+	}
+	if p.Tokens > p.Budget {
+		t.Errorf("prompt is %d tokens, over budget %d", p.Tokens, p.Budget)
+	}
+}
+
+func TestBuildPromptTruncatesTaskLast(t *testing.T) {
+	agentsMD := "Base." + assembler.AgentLayerSep + strings.Repeat("agent ", 200) + "\n"
+	task := Task{Content: "TASK" + strings.Repeat(" y", 2000), Trust: TrustVerified}
+
+	p, err := BuildPrompt(PromptParts{AgentsMD: agentsMD, Task: task}, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"layer:0", "truncated:agent-layer", "truncated:task"}
+	if strings.Join(p.Dropped, ",") != strings.Join(want, ",") {
+		t.Errorf("dropped = %v, want %v", p.Dropped, want)
+	}
+	if !strings.Contains(p.Text, "TASK") || !strings.Contains(p.Text, "truncated to fit") {
+		t.Error("task should be kept in part with a truncation marker")
+	}
+	if p.Tokens > p.Budget {
+		t.Errorf("prompt is %d tokens, over budget %d", p.Tokens, p.Budget)
+	}
+}
+
+func TestBuildPromptKeepsAgentLayerBeforeInstructions(t *testing.T) {
+	big := func(tag string) string { return tag + strings.Repeat(" x", 2000) }
+	// Inline instructions follow the agent layer; both are the agent's own
+	agentsMD := big("BASE") + assembler.AgentLayerSep + "AGENT" + agentsMDSep + "INSTRUCTIONS\n"
+	task := Task{Content: "TASK", Trust: TrustVerified}
+
+	p, err := BuildPrompt(PromptParts{AgentsMD: agentsMD, Task: task}, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(p.Dropped, ",") != "layer:0" {
+		t.Errorf("dropped = %v, want only the base layer", p.Dropped)
+	}
+	for _, want := range []string{"AGENT", "INSTRUCTIONS", "TASK"} {
+		if !strings.Contains(p.Text, want) {
+			t.Errorf("prompt should keep %s", want)
+		}
+	}
+}
+
+func TestBuildPromptTaskFloor(t *testing.T) {
+	task := Task{Content: strings.Repeat("y", 4*minTaskTokens), Trust: TrustVerified}
+	if _, err := BuildPrompt(PromptParts{AgentsMD: "Agent.\n", Task: task}, 100); err == nil {
+		t.Error("expected an error rather than a task cut below the floor")
+	}
+	if _, err := BuildPrompt(PromptParts{AgentsMD: "Agent.\n", Task: task}, PromptBudget(config.AgentConfig{ContextWindow: outputReserve})); err == nil {
+		t.Error("expected an error for a zero budget")
+	}
+}
+
+func TestUnfitTaskDoesNotBlockInbox(t *testing.T) {
+	old := session.AgentsDir
+	session.AgentsDir = t.TempDir()
+	t.Cleanup(func() { session.AgentsDir = old })
+	inbox := filepath.Join(session.AgentsDir, "researcher", "inbox")
+	os.MkdirAll(inbox, 0700)
+	os.WriteFile(filepath.Join(inbox, "001-big.task"), []byte(strings.Repeat("y", 4*minTaskTokens)), 0644)
+	os.WriteFile(filepath.Join(inbox, "002-small.task"), []byte("small"), 0644)
+
+	task, err := PickOldestTask(inbox)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := buildTaskPrompt("researcher", PromptParts{AgentsMD: "Agent.\n", Task: task}, 100); err == nil {
+		t.Fatal("expected the oversized task not to fit")
+	}
+	failed := filepath.Join(session.AgentsDir, "researcher", "failed", "001-big.task")
+	if reason, err := os.ReadFile(failed + ".reason"); err != nil || !strings.Contains(string(reason), "cannot fit the task") {
+		t.Errorf("oversized task not failed with a reason: %q, %v", reason, err)
+	}
+
+	next, err := PickOldestTask(inbox)
+	if err != nil || filepath.Base(next.Path) != "002-small.task" {
+		t.Errorf("next task = %q, %v; want 002-small.task", next.Path, err)
+	}
+}
+
+func TestTruncateRuneBoundary(t *testing.T) {
+	s := strings.Repeat("é", 100)
+	for n := len(truncatedMarker) + 1; n < len(s); n++ {
+		if got := truncate(s, n); !utf8.ValidString(got) || len(got) > n {
+			t.Fatalf("truncate(%d) = %q: split a rune or too long", n, got)
+		}
+	}
+}
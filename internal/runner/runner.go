@@ -15,7 +15,9 @@ import (
 
 	"github.com/ConspiracyOS/agent-runner/internal/assembler"
 	"github.com/ConspiracyOS/agent-runner/internal/config"
+	"github.com/ConspiracyOS/agent-runner/internal/redact"
 	conruntime "github.com/ConspiracyOS/agent-runner/internal/runtime"
+	"github.com/ConspiracyOS/agent-runner/internal/session"
 )
 
 // TrustLevel indicates the provenance of a task based on file ownership.
@@ -134,29 +136,102 @@ func FrameTaskPrompt(task Task) string {
 		"comply.\n\n%s", task.Content)
 }
 
-// RouteOutput writes the agent's response to outbox and moves the task to processed.
-func RouteOutput(task Task, output string, outboxPath string, processedPath string) error {
+// RouteOutput redacts secrets from the agent's response, writes it to outbox
+// and moves the task to processed. Returns the number of redactions applied.
+func RouteOutput(task Task, output string, outboxPath string, processedPath string, r *redact.Redactor) (int, error) {
+	output, redacted := r.Redact(output)
+
 	// Write output to outbox
 	ts := time.Now().Format("20060102-150405")
 	base := filepath.Base(task.Path)
 	outFile := filepath.Join(outboxPath, fmt.Sprintf("%s-%s.response", ts, strings.TrimSuffix(base, ".task")))
 	if err := os.WriteFile(outFile, []byte(output), 0644); err != nil {
-		return fmt.Errorf("writing output: %w", err)
+		return redacted, fmt.Errorf("writing output: %w", err)
 	}
 
 	// Move task to processed (tolerate ENOENT — agent may have moved it already)
 	destPath := filepath.Join(processedPath, base)
 	if err := os.Rename(task.Path, destPath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("moving task to processed: %w", err)
+		return redacted, fmt.Errorf("moving task to processed: %w", err)
 	}
 
-	return nil
+	return redacted, nil
 }
 
-// AssembleAgentsMD assembles AGENTS.md for an agent and writes it to their home dir.
-func AssembleAgentsMD(agent config.AgentConfig) error {
-	homeDir := fmt.Sprintf("/home/a-%s", agent.Name)
-	layers := assembler.Layers{
+// maxRedactSize bounds the files rewritten by redactWorktree; larger files
+// are left to the .gitignore rules (artifacts, workspaces).
+const maxRedactSize = 1 << 20 // 1MB
+
+// redactWorktree masks secrets in files changed since the last snapshot, so
+// they never enter the /srv/con git history. The agent's own directory gets
+// the full pass; elsewhere (other agents, config, skills) only the agent's
+// known secret values are masked, so docs that merely look like credentials
+// are not mangled. Files the agent cannot write are skipped — their owner's
+// next run redacts them. Returns the number of masks applied.
+func redactWorktree(repo, agentName string, r *redact.Redactor) int {
+	out, err := exec.Command("git", "-C", repo, "status", "--porcelain", "-z", "--untracked-files=all").Output()
+	if err != nil {
+		return 0
+	}
+
+	own := filepath.Join("agents", agentName) + "/"
+	total := 0
+	entries := strings.Split(string(out), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		status, path := entry[:2], entry[3:]
+		if status[0] == 'R' || status[0] == 'C' {
+			i++ // renames and copies are followed by the source path
+		}
+		if status[0] == 'D' || status[1] == 'D' {
+			continue
+		}
+
+		full := filepath.Join(repo, path)
+		info, err := os.Lstat(full)
+		if err != nil || !info.Mode().IsRegular() || info.Size() > maxRedactSize {
+			continue
+		}
+		data, err := os.ReadFile(full)
+		if err != nil {
+			continue
+		}
+		redactFn := r.RedactValues
+		if strings.HasPrefix(path, own) {
+			redactFn = r.Redact
+		}
+		clean, n := redactFn(string(data))
+		if n == 0 {
+			continue
+		}
+		if err := os.WriteFile(full, []byte(clean), info.Mode().Perm()); err == nil {
+			total += n
+		}
+	}
+	return total
+}
+
+// agentRedactor builds a Redactor from the secret values visible to the agent:
+// everything it is granted in config, resolved the same way the runtime does.
+func agentRedactor(cfg *config.Config, agentName string) *redact.Redactor {
+	values := map[string]string{}
+	for _, name := range cfg.AgentSecrets(agentName) {
+		if v := config.LookupSecret(agentName, name); v != "" {
+			values[name] = v
+		}
+	}
+	return redact.New(values)
+}
+
+// AgentLayers returns the assembler layers for an agent, with template
+// variables resolved from config. Mission comes from /etc/con/mission.md.
+func AgentLayers(cfg *config.Config, name string) assembler.Layers {
+	agent := cfg.ResolvedAgent(name)
+	mission, _ := os.ReadFile("/etc/con/mission.md")
+	return assembler.Layers{
 		OuterRoot:          "/etc/con",
 		InnerRoot:          "/srv/con/config",
 		Roles:              agent.Roles,
@@ -164,8 +239,24 @@ func AssembleAgentsMD(agent config.AgentConfig) error {
 		Scopes:             agent.Scopes,
 		AgentName:          agent.Name,
 		InlineInstructions: agent.Instructions,
+		Vars: assembler.Vars{
+			Name:    agent.Name,
+			Tier:    agent.Tier,
+			Roles:   agent.Roles,
+			Groups:  agent.Groups,
+			Scopes:  agent.Scopes,
+			Model:   agent.Model,
+			CanTask: cfg.TaskTargets(agent.Name),
+			System:  cfg.System.Name,
+			Mission: strings.TrimSpace(string(mission)),
+		},
 	}
-	agentsMD, err := assembler.Assemble(layers)
+}
+
+// AssembleAgentsMD assembles AGENTS.md for an agent and writes it to their home dir.
+func AssembleAgentsMD(cfg *config.Config, name string) error {
+	homeDir := fmt.Sprintf("/home/a-%s", name)
+	agentsMD, err := assembler.Assemble(AgentLayers(cfg, name))
 	if err != nil {
 		return err
 	}
@@ -184,26 +275,6 @@ func AssembleAgentsMD(agent config.AgentConfig) error {
 	return nil
 }
 
-// ReadSkills reads all .md files from skillsDir and returns concatenated skill content.
-// Returns an empty string if the directory does not exist or contains no .md files.
-func ReadSkills(skillsDir string) string {
-	var skillsContent string
-	entries, err := os.ReadDir(skillsDir)
-	if err != nil {
-		return ""
-	}
-	for _, e := range entries {
-		if e.IsDir() || !strings.HasSuffix(e.Name(), ".md") {
-			continue
-		}
-		data, err := os.ReadFile(filepath.Join(skillsDir, e.Name()))
-		if err == nil {
-			skillsContent += fmt.Sprintf("\n\n## Skill: %s\n\n%s", strings.TrimSuffix(e.Name(), ".md"), string(data))
-		}
-	}
-	return skillsContent
-}
-
 // Run executes a single agent run: assemble context, pick task, invoke PicoClaw, route output.
 func Run(agentName string, cfg *config.Config) error {
 	agent := cfg.ResolvedAgent(agentName)
@@ -231,20 +302,57 @@ func Run(agentName string, cfg *config.Config) error {
 		return fmt.Errorf("picking task: %w", err)
 	}
 
-	// 3. Build the prompt: AGENTS.md + skills + task content
-	skillsDir := filepath.Join(agentDir, "workspace", "skills")
-	skillsContent := ReadSkills(skillsDir)
+	// Record the in-flight run so kill_session can terminate exactly this session
+	run := session.State{Agent: agentName, Task: task.Path, PID: os.Getpid(), Started: time.Now()}
+	if err := session.Write(run); err != nil {
+		fmt.Fprintf(os.Stderr, "recording run state: %v\n", err)
+	}
+	defer session.Clear(agentName)
 
-	prompt := fmt.Sprintf("Context (your instructions):\n\n%s", agentsMD)
-	if skillsContent != "" {
-		prompt += fmt.Sprintf("\n\n---\n\n# Skills Reference\n%s", skillsContent)
+	// 3. Build the prompt: AGENTS.md + skills + task content, within the model's context window
+	// Skills must match the root-owned manifest written at bootstrap (CON-AGENT-003)
+	skillsDir := filepath.Join(agentDir, "workspace", "skills")
+	verified, rejected := VerifySkills(LoadSkills(skillsDir), SkillManifestPath(agentName))
+	if len(rejected) > 0 {
+		fmt.Fprintf(os.Stderr, "skills rejected (not in manifest or modified): %s\n", strings.Join(rejected, ", "))
+	}
+	verified, badTier := CheckSkillTiers(verified)
+	if len(badTier) > 0 {
+		fmt.Fprintf(os.Stderr, "skills skipped (unknown tier): %s\n", strings.Join(badTier, ", "))
+	}
+	skills, index := SelectSkills(verified, task.Content, agent.Tier)
+	built, err := buildTaskPrompt(agentName, PromptParts{
+		AgentsMD: agentsMD,
+		Skills:   skills,
+		Index:    index,
+		Notes:    LoadSkills(filepath.Join(agentDir, "workspace", "notes")),
+		Task:     task,
+	}, PromptBudget(agent))
+	if err != nil {
+		return fmt.Errorf("building prompt: %w", err)
 	}
-	prompt += FrameTaskPrompt(task)
+	if len(built.Dropped) > 0 {
+		fmt.Fprintf(os.Stderr, "prompt budget %d tokens: %s\n", built.Budget, strings.Join(built.Dropped, ", "))
+	}
+	prompt := built.Text
 
 	// 4. Invoke runtime
 	sessionKey := fmt.Sprintf("con:%s", agentName)
 	ctx := context.Background()
 	rt := conruntime.New(agent)
+	toolSpecs, invalidTools := SkillTools(verified, agent.Tier)
+	if len(invalidTools) > 0 {
+		fmt.Fprintf(os.Stderr, "skill tools skipped (invalid or duplicate): %s\n", strings.Join(invalidTools, ", "))
+	}
+	switch r := rt.(type) {
+	case *conruntime.PicoClaw:
+		r.Tools = toolSpecs
+	case *conruntime.Exec:
+		r.OnStart = func(pid int) {
+			run.ChildPGID = pid
+			session.Write(run)
+		}
+	}
 	output, err := rt.Invoke(ctx, prompt, sessionKey)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "agent runtime error: %v\n", err)
@@ -263,22 +371,37 @@ func Run(agentName string, cfg *config.Config) error {
 		lf.Close()
 	}
 
-	// 6. Write audit log
-	auditLine := fmt.Sprintf("%s [%s] run: processed %s [trust:%s]\n",
+	// 6. Route output (redacted — the response is committed to git below)
+	redactor := agentRedactor(cfg, agentName)
+	redacted, err := RouteOutput(task, output, outboxDir, processedDir, redactor)
+	if err != nil {
+		return fmt.Errorf("routing output: %w", err)
+	}
+
+	// 7. Redact anything else the run left in the worktree before it is snapshotted
+	redacted += redactWorktree("/srv/con", agentName, redactor)
+
+	// 8. Write audit log
+	auditLine := fmt.Sprintf("%s [%s] run: processed %s [trust:%s]",
 		now.Format(time.RFC3339), agentName, filepath.Base(task.Path), task.Trust)
+	if redacted > 0 {
+		auditLine += fmt.Sprintf(" [redacted:%d]", redacted)
+	}
+	if len(rejected) > 0 {
+		auditLine += fmt.Sprintf(" [skills-rejected:%s]", strings.Join(rejected, ","))
+	}
+	if len(built.Dropped) > 0 {
+		auditLine += fmt.Sprintf(" [budget:%d dropped:%s]", built.Budget, strings.Join(built.Dropped, ","))
+	}
+	auditLine, _ = redactor.Redact(auditLine)
 	auditPath := fmt.Sprintf("/srv/con/logs/audit/%s.log", now.Format("2006-01-02"))
 	f, err := os.OpenFile(auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err == nil {
-		f.WriteString(auditLine)
+		f.WriteString(auditLine + "\n")
 		f.Close()
 	}
 
-	// 7. Route output
-	if err := RouteOutput(task, output, outboxDir, processedDir); err != nil {
-		return fmt.Errorf("routing output: %w", err)
-	}
-
-	// 8. Snapshot /srv/con/ state (best-effort, non-blocking)
+	// 9. Snapshot /srv/con/ state (best-effort, non-blocking)
 	commitMsg := fmt.Sprintf("%s: %s [%s]", agentName, filepath.Base(task.Path), task.Trust)
 	exec.Command("git", "-C", "/srv/con", "add", "-A").Run()
 	exec.Command("git", "-C", "/srv/con", "commit", "-m", commitMsg, "--allow-empty").Run()
@@ -286,6 +409,20 @@ func Run(agentName string, cfg *config.Config) error {
 	return nil
 }
 
+// buildTaskPrompt builds the prompt for parts.Task. A task that cannot fit
+// the budget never will, and would be picked again on every run: it is moved
+// to the agent's failed/ dir, as a killed session's task is.
+func buildTaskPrompt(agentName string, parts PromptParts, budget int) (Prompt, error) {
+	p, err := BuildPrompt(parts, budget)
+	if err == nil {
+		return p, nil
+	}
+	if _, ferr := session.FailTask(agentName, parts.Task.Path, "unfit: "+err.Error()); ferr != nil {
+		return p, fmt.Errorf("%w (failing task: %v)", err, ferr)
+	}
+	return p, err
+}
+
 // MoveOuterInboxTasks moves tasks from the outer inbox to the concierge's inbox.
 // Called before the concierge's main run loop.
 func MoveOuterInboxTasks() error {
@@ -2,10 +2,13 @@ package runner
 
 import (
 	"os"
+	"os/exec"
 	"os/user"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/ConspiracyOS/agent-runner/internal/redact"
 )
 
 func TestPickOldestTask(t *testing.T) {
@@ -52,7 +55,7 @@ func TestRouteOutput(t *testing.T) {
 	os.WriteFile(task.Path, []byte(task.Content), 0644)
 
 	output := "Task completed successfully"
-	err := RouteOutput(task, output, outbox, processed)
+	_, err := RouteOutput(task, output, outbox, processed, nil)
 	if err != nil {
 		t.Fatalf("RouteOutput failed: %v", err)
 	}
@@ -230,7 +233,7 @@ func TestRouteOutputTimestamp(t *testing.T) {
 	os.WriteFile(taskPath, []byte("task body"), 0644)
 
 	task := Task{Path: taskPath, Content: "task body"}
-	if err := RouteOutput(task, "done", outbox, processed); err != nil {
+	if _, err := RouteOutput(task, "done", outbox, processed, nil); err != nil {
 		t.Fatalf("RouteOutput failed: %v", err)
 	}
 
@@ -261,7 +264,7 @@ func TestRouteOutputMissingTask(t *testing.T) {
 		Path:    filepath.Join(agentDir, "inbox", "ghost.task"),
 		Content: "never existed",
 	}
-	if err := RouteOutput(task, "output", outbox, processed); err != nil {
+	if _, err := RouteOutput(task, "output", outbox, processed, nil); err != nil {
 		t.Errorf("RouteOutput should tolerate missing task file (ENOENT), got: %v", err)
 	}
 }
@@ -295,7 +298,7 @@ func TestMoveOuterInboxCrossDevice(t *testing.T) {
 	}
 }
 
-func TestReadSkills(t *testing.T) {
+func TestLoadSkills(t *testing.T) {
 	skillsDir := t.TempDir()
 
 	os.WriteFile(filepath.Join(skillsDir, "alpha.md"), []byte("alpha content"), 0644)
@@ -304,39 +307,25 @@ func TestReadSkills(t *testing.T) {
 	os.MkdirAll(filepath.Join(skillsDir, "subdir"), 0755)
 	os.WriteFile(filepath.Join(skillsDir, "subdir", "nested.md"), []byte("should be ignored"), 0644)
 
-	result := ReadSkills(skillsDir)
-
-	if !strings.Contains(result, "## Skill: alpha") {
-		t.Error("expected alpha skill section in output")
-	}
-	if !strings.Contains(result, "alpha content") {
-		t.Error("expected alpha skill content in output")
+	skills := LoadSkills(skillsDir)
+	if len(skills) != 2 || skills[0].Name != "alpha" || skills[1].Name != "beta" {
+		t.Fatalf("LoadSkills = %+v, want alpha and beta", skills)
 	}
-	if !strings.Contains(result, "## Skill: beta") {
-		t.Error("expected beta skill section in output")
-	}
-	if !strings.Contains(result, "beta content") {
-		t.Error("expected beta skill content in output")
-	}
-	if strings.Contains(result, "should be ignored") {
-		t.Error("non-.md files and subdirectory files should not appear in skill output")
+	if !strings.Contains(skills[0].Text(), "## Skill: alpha") || !strings.Contains(skills[0].Text(), "alpha content") {
+		t.Errorf("alpha text = %q", skills[0].Text())
 	}
 }
 
-func TestReadSkillsEmpty(t *testing.T) {
+func TestLoadSkillsEmpty(t *testing.T) {
 	// Directory exists but has no .md files
 	skillsDir := t.TempDir()
 	os.WriteFile(filepath.Join(skillsDir, "notes.txt"), []byte("not a skill"), 0644)
 
-	if result := ReadSkills(skillsDir); result != "" {
-		t.Errorf("expected empty string for dir with no .md files, got: %q", result)
+	if skills := LoadSkills(skillsDir); len(skills) != 0 {
+		t.Errorf("expected no skills for dir with no .md files, got: %+v", skills)
 	}
-}
-
-func TestReadSkillsMissingDir(t *testing.T) {
-	result := ReadSkills("/nonexistent/path/to/skills")
-	if result != "" {
-		t.Errorf("expected empty string for missing dir, got: %q", result)
+	if skills := LoadSkills("/nonexistent/path/to/skills"); len(skills) != 0 {
+		t.Errorf("expected no skills for missing dir, got: %+v", skills)
 	}
 }
 
@@ -391,3 +380,86 @@ func TestIsTrustedUID_WithGroupOverride(t *testing.T) {
 		t.Errorf("user in group %q should be trusted when TrustedGroupName=%q", g.Name, g.Name)
 	}
 }
+
+func TestRouteOutputRedacts(t *testing.T) {
+	agentDir := t.TempDir()
+	outbox := filepath.Join(agentDir, "outbox")
+	processed := filepath.Join(agentDir, "processed")
+	os.MkdirAll(outbox, 0755)
+	os.MkdirAll(processed, 0755)
+
+	task := Task{Path: filepath.Join(agentDir, "inbox", "001.task")}
+	r := redact.New(map[string]string{"CON_API_KEY": "super-secret-key-value"})
+
+	n, err := RouteOutput(task, "my key is super-secret-key-value", outbox, processed, r)
+	if err != nil {
+		t.Fatalf("RouteOutput failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("redactions = %d, want 1", n)
+	}
+
+	files, _ := os.ReadDir(outbox)
+	if len(files) != 1 {
+		t.Fatalf("expected 1 response, got %d", len(files))
+	}
+	data, _ := os.ReadFile(filepath.Join(outbox, files[0].Name()))
+	if strings.Contains(string(data), "super-secret-key-value") {
+		t.Errorf("response should be redacted, got %q", data)
+	}
+	if !strings.Contains(string(data), "[REDACTED:CON_API_KEY]") {
+		t.Errorf("response should carry the redaction marker, got %q", data)
+	}
+}
+
+func TestRedactWorktree(t *testing.T) {
+	repo := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("config", "user.name", "test")
+	git("config", "user.email", "test@localhost")
+
+	os.WriteFile(filepath.Join(repo, "committed.txt"), []byte("clean"), 0644)
+	git("add", "-A")
+	git("commit", "-qm", "initial")
+
+	// A modified tracked file and a new untracked file both leak the secret
+	doc := "example header: Bearer abcdefghijklmnopqrstuvwxyz0123"
+	os.WriteFile(filepath.Join(repo, "committed.txt"), []byte("leak super-secret-key-value\n"+doc), 0644)
+	os.MkdirAll(filepath.Join(repo, "agents", "a", "outbox"), 0755)
+	os.WriteFile(filepath.Join(repo, "agents", "a", "outbox", "x.response"), []byte("super-secret-key-value "+doc), 0644)
+	os.MkdirAll(filepath.Join(repo, "agents", "ab"), 0755)
+	os.WriteFile(filepath.Join(repo, "agents", "ab", "notes.md"), []byte(doc), 0644)
+	os.WriteFile(filepath.Join(repo, "untouched.txt"), []byte("nothing here"), 0644)
+
+	r := redact.New(map[string]string{"CON_API_KEY": "super-secret-key-value"})
+	if n := redactWorktree(repo, "a", r); n != 3 {
+		t.Errorf("redactWorktree = %d, want 3", n)
+	}
+
+	// Credential-like text is only masked in the running agent's own directory
+	if data, _ := os.ReadFile(filepath.Join(repo, "committed.txt")); !strings.Contains(string(data), doc) {
+		t.Errorf("shared file should keep pattern-like text, got %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(repo, "agents", "ab", "notes.md")); string(data) != doc {
+		t.Errorf("another agent's file should be unchanged, got %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(repo, "agents", "a", "outbox", "x.response")); strings.Contains(string(data), "abcdefghijklmnop") {
+		t.Errorf("own files get the pattern pass, got %q", data)
+	}
+
+	for _, p := range []string{"committed.txt", "agents/a/outbox/x.response"} {
+		data, _ := os.ReadFile(filepath.Join(repo, p))
+		if strings.Contains(string(data), "super-secret-key-value") {
+			t.Errorf("%s still contains the secret: %q", p, data)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(repo, "untouched.txt")); string(data) != "nothing here" {
+		t.Errorf("untouched.txt should be unchanged, got %q", data)
+	}
+}
@@ -0,0 +1,277 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	conruntime "github.com/ConspiracyOS/agent-runner/internal/runtime"
+)
+
+// SkillManifestDir holds per-agent skill hash manifests, outside the agents'
+// own directories so an agent cannot rewrite a skill and its hash together.
+var SkillManifestDir = "/srv/con/skills"
+
+// SkillManifestPath returns the path of an agent's skill manifest.
+func SkillManifestPath(agent string) string {
+	return filepath.Join(SkillManifestDir, agent+".sha256")
+}
+
+// tierRank orders tiers for a skill's required tier: an agent may use a
+// skill if its tier ranks at least as high.
+var tierRank = map[string]int{"worker": 1, "operator": 2, "officer": 3}
+
+// usableBy reports whether an agent of agentTier may use a skill requiring
+// tier. An unknown (e.g. misspelled) tier is usable by no one.
+func usableBy(tier, agentTier string) bool {
+	if tier == "" {
+		return true
+	}
+	rank, ok := tierRank[tier]
+	return ok && tierRank[agentTier] >= rank
+}
+
+// CheckSkillTiers splits skills into those whose tier is empty or known and
+// the names of those naming an unknown tier, which no agent may use.
+func CheckSkillTiers(skills []Skill) (valid []Skill, invalid []string) {
+	for _, s := range skills {
+		if _, ok := tierRank[s.Tier]; s.Tier != "" && !ok {
+			invalid = append(invalid, s.Name)
+			continue
+		}
+		valid = append(valid, s)
+	}
+	return valid, invalid
+}
+
+// Skill is one skill file from an agent's workspace/skills directory.
+// Metadata comes from optional YAML frontmatter:
+//
+//	---
+//	name: commission-agent
+//	description: Create a new agent within standing policy
+//	triggers: [commission, new agent, hire]
+//	tier: operator
+//	version: 2
+//	---
+type Skill struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Triggers    []string `yaml:"triggers"`
+	Tier        string   `yaml:"tier"`
+	Version     string   `yaml:"version"`
+	Path        string   `yaml:"-"`
+	Content     string   `yaml:"-"` // body without frontmatter
+	Hash        string   `yaml:"-"` // sha256 of the whole file, hex
+
+	// Tool optionally exposes a scripted procedure as a callable tool
+	Tool *conruntime.ToolSpec `yaml:"tool"`
+}
+
+// Text returns the skill formatted for the prompt's Skills Reference.
+func (s Skill) Text() string {
+	return fmt.Sprintf("\n\n## Skill: %s\n\n%s", s.Name, s.Content)
+}
+
+// IndexLine returns the skill's one-line entry in the prompt's skill index.
+func (s Skill) IndexLine() string {
+	line := "- " + s.Name
+	if s.Version != "" {
+		line += " (v" + s.Version + ")"
+	}
+	if s.Description != "" {
+		line += ": " + s.Description
+	}
+	return line + " — " + s.Path
+}
+
+// LoadSkills reads all .md files from skillsDir in name order.
+// Returns nil if the directory does not exist or contains no .md files.
+func LoadSkills(skillsDir string) []Skill {
+	entries, err := os.ReadDir(skillsDir)
+	if err != nil {
+		return nil
+	}
+	var skills []Skill
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".md") {
+			continue
+		}
+		path := filepath.Join(skillsDir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		skill := parseSkill(string(data))
+		if skill.Name == "" {
+			skill.Name = strings.TrimSuffix(e.Name(), ".md")
+		}
+		skill.Path = path
+		skill.Hash = fmt.Sprintf("%x", sha256.Sum256(data))
+		skills = append(skills, skill)
+	}
+	return skills
+}
+
+// parseSkill splits optional YAML frontmatter from a skill file. A file with
+// malformed frontmatter is kept whole as content, so it is never lost.
+func parseSkill(text string) Skill {
+	if !strings.HasPrefix(text, "---\n") {
+		return Skill{Content: text}
+	}
+	end := strings.Index(text[4:], "\n---")
+	if end < 0 {
+		return Skill{Content: text}
+	}
+	var s Skill
+	if err := yaml.Unmarshal([]byte(text[4:4+end]), &s); err != nil {
+		return Skill{Content: text}
+	}
+	s.Content = strings.TrimLeft(text[4+end+4:], "\n")
+	return s
+}
+
+// DeploySkills copies the .md files from sources into skillsDir, replacing
+// any skills already there, and writes a sha256sum-format manifest of them
+// to manifestPath. Files are written read-only; callers running as root
+// should also chown skillsDir and its files to root. Later sources override
+// earlier ones with the same file name. Returns the deployed file names.
+func DeploySkills(sources []string, skillsDir, manifestPath string) ([]string, error) {
+	files := map[string][]byte{}
+	for _, src := range sources {
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			continue // dir doesn't exist, skip
+		}
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), ".md") {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(src, e.Name()))
+			if err != nil {
+				continue
+			}
+			files[e.Name()] = data
+		}
+	}
+
+	// Remove stale skills, including any the agent added itself
+	if err := os.MkdirAll(skillsDir, 0755); err != nil {
+		return nil, err
+	}
+	os.Chmod(skillsDir, 0755)
+	if entries, err := os.ReadDir(skillsDir); err == nil {
+		for _, e := range entries {
+			os.RemoveAll(filepath.Join(skillsDir, e.Name()))
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var manifest strings.Builder
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(skillsDir, name), files[name], 0444); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&manifest, "%x  %s\n", sha256.Sum256(files[name]), name)
+	}
+	if err := os.Chmod(skillsDir, 0555); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(manifestPath), 0755); err != nil {
+		return nil, err
+	}
+	os.Remove(manifestPath)
+	if err := os.WriteFile(manifestPath, []byte(manifest.String()), 0444); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// VerifySkills checks skills against the manifest written by DeploySkills.
+// Skills that are not listed or whose hash differs are rejected, as are all
+// skills when the manifest cannot be read. Returns the verified skills and
+// the file names of the rejected ones.
+func VerifySkills(skills []Skill, manifestPath string) (verified []Skill, rejected []string) {
+	want := map[string]string{}
+	if data, err := os.ReadFile(manifestPath); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			hash, name, ok := strings.Cut(line, "  ")
+			if ok {
+				want[name] = hash
+			}
+		}
+	}
+	for _, s := range skills {
+		name := filepath.Base(s.Path)
+		if h, ok := want[name]; ok && h == s.Hash {
+			verified = append(verified, s)
+		} else {
+			rejected = append(rejected, name)
+		}
+	}
+	return verified, rejected
+}
+
+// SelectSkills splits skills into those loaded in full for a task and an
+// index of the rest. A skill without triggers is always loaded; otherwise it
+// is loaded when the task contains one of its triggers (case-insensitive).
+// Skills requiring a higher tier than agentTier are left out entirely.
+func SelectSkills(skills []Skill, taskContent, agentTier string) (full, index []Skill) {
+	lower := strings.ToLower(taskContent)
+	for _, s := range skills {
+		if !usableBy(s.Tier, agentTier) {
+			continue
+		}
+		if len(s.Triggers) == 0 || matchesAny(lower, s.Triggers) {
+			full = append(full, s)
+		} else {
+			index = append(index, s)
+		}
+	}
+	return full, index
+}
+
+// SkillTools returns the tool specs declared by skills that an agent of the
+// given tier may use. Invalid specs are skipped and reported in invalid.
+// Only pass verified skills: a tool runs commands as the agent.
+func SkillTools(skills []Skill, agentTier string) (specs []conruntime.ToolSpec, invalid []string) {
+	seen := map[string]bool{}
+	for _, s := range skills {
+		if s.Tool == nil {
+			continue
+		}
+		if !usableBy(s.Tier, agentTier) {
+			continue
+		}
+		if err := s.Tool.Validate(); err != nil || seen[s.Tool.Name] {
+			invalid = append(invalid, s.Name)
+			continue
+		}
+		if !s.Tool.AllowsTier(agentTier) {
+			continue
+		}
+		seen[s.Tool.Name] = true
+		specs = append(specs, *s.Tool)
+	}
+	return specs, invalid
+}
+
+func matchesAny(lowerText string, triggers []string) bool {
+	for _, t := range triggers {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" && strings.Contains(lowerText, t) {
+			return true
+		}
+	}
+	return false
+}
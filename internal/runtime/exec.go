@@ -18,6 +18,10 @@ type Exec struct {
 	Cmd       string
 	Args      []string
 	Workspace string
+
+	// OnStart, if set, is called with the CLI's PID once it has started.
+	// The CLI leads its own process group, so the PID is also its PGID.
+	OnStart func(pid int)
 }
 
 // Invoke runs the configured CLI, passing prompt via stdin and capturing stdout.
@@ -33,7 +37,13 @@ func (e *Exec) Invoke(ctx context.Context, prompt, sessionKey string) (string, e
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	err := cmd.Run()
+	err := cmd.Start()
+	if err == nil {
+		if e.OnStart != nil {
+			e.OnStart(cmd.Process.Pid)
+		}
+		err = cmd.Wait()
+	}
 
 	// Kill entire process group on context cancellation (child processes survive
 	// a regular SIGKILL to the parent).
@@ -23,6 +23,21 @@ func TestExecRuntime_Echo(t *testing.T) {
 	}
 }
 
+func TestExecRuntime_OnStart(t *testing.T) {
+	var started int
+	rt := &Exec{
+		Cmd:       "cat",
+		Workspace: t.TempDir(),
+		OnStart:   func(pid int) { started = pid },
+	}
+	if _, err := rt.Invoke(context.Background(), "x", "test-session"); err != nil {
+		t.Fatal(err)
+	}
+	if started <= 0 {
+		t.Errorf("OnStart pid = %d, want the CLI's pid", started)
+	}
+}
+
 func TestExecRuntime_WithArgs(t *testing.T) {
 	// Use "tr" to transform input — proves args are passed
 	rt := &Exec{
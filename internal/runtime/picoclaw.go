@@ -3,7 +3,6 @@ package runtime
 import (
 	"context"
 	"fmt"
-	"os"
 
 	pcagent "github.com/sipeed/picoclaw/pkg/agent"
 	"github.com/sipeed/picoclaw/pkg/bus"
@@ -16,6 +15,7 @@ import (
 // PicoClaw runs agents using the in-process PicoClaw library.
 type PicoClaw struct {
 	Agent conconfig.AgentConfig
+	Tools []ToolSpec // skill tools registered with the agent loop
 }
 
 func (p *PicoClaw) Invoke(ctx context.Context, prompt, sessionKey string) (string, error) {
@@ -30,6 +30,9 @@ func (p *PicoClaw) Invoke(ctx context.Context, prompt, sessionKey string) (strin
 	defer msgBus.Close()
 
 	loop := pcagent.NewAgentLoop(cfg, msgBus, provider)
+	for _, spec := range p.Tools {
+		loop.RegisterTool(&ScriptTool{Spec: spec, Dir: cfg.Agents.Defaults.Workspace})
+	}
 
 	return loop.ProcessDirect(ctx, prompt, sessionKey)
 }
@@ -52,34 +55,40 @@ func BuildPicoConfig(agent conconfig.AgentConfig) *pcconfig.Config {
 
 	// Resolve API key from config. The agent's APIKeyEnv field names the env var.
 	// Falls back to legacy env vars for backwards compatibility.
+	// Values come from the environment, the encrypted store's credentials
+	// directory, or the agent's scoped env file (see config.LookupSecret).
+	getenv := func(key string) string {
+		return conconfig.LookupSecret(agent.Name, key)
+	}
+
 	apiKey := ""
 	if agent.APIKeyEnv != "" {
-		apiKey = os.Getenv(agent.APIKeyEnv)
+		apiKey = getenv(agent.APIKeyEnv)
 	}
 
 	switch agent.Provider {
 	case "openrouter":
 		if apiKey == "" {
-			apiKey = os.Getenv("CON_OPENROUTER_API_KEY")
+			apiKey = getenv("CON_OPENROUTER_API_KEY")
 		}
 		cfg.Providers.OpenRouter = pcconfig.ProviderConfig{APIKey: apiKey}
 	case "anthropic":
 		if apiKey == "" {
-			apiKey = os.Getenv("CON_AUTH_ANTHROPIC")
+			apiKey = getenv("CON_AUTH_ANTHROPIC")
 		}
 		cfg.Providers.Anthropic = pcconfig.ProviderConfig{APIKey: apiKey}
 	case "openai":
 		if apiKey == "" {
-			apiKey = os.Getenv("CON_AUTH_OPENAI")
+			apiKey = getenv("CON_AUTH_OPENAI")
 		}
 		cfg.Providers.OpenAI = pcconfig.ProviderConfig{APIKey: apiKey}
 	default:
 		// No provider specified or unknown — try legacy env var cascade
-		if key := os.Getenv("CON_OPENROUTER_API_KEY"); key != "" {
+		if key := getenv("CON_OPENROUTER_API_KEY"); key != "" {
 			cfg.Providers.OpenRouter = pcconfig.ProviderConfig{APIKey: key}
-		} else if key := os.Getenv("CON_AUTH_ANTHROPIC"); key != "" {
+		} else if key := getenv("CON_AUTH_ANTHROPIC"); key != "" {
 			cfg.Providers.Anthropic = pcconfig.ProviderConfig{APIKey: key}
-		} else if key := os.Getenv("CON_AUTH_OPENAI"); key != "" {
+		} else if key := getenv("CON_AUTH_OPENAI"); key != "" {
 			cfg.Providers.OpenAI = pcconfig.ProviderConfig{APIKey: key}
 		}
 	}
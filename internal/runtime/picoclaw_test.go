@@ -1,6 +1,8 @@
 package runtime
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/ConspiracyOS/agent-runner/internal/config"
@@ -81,3 +83,28 @@ func TestBuildPicoConfigLegacyFallback(t *testing.T) {
 		t.Errorf("expected Anthropic key, got %q", pcfg.Providers.Anthropic.APIKey)
 	}
 }
+
+func TestBuildPicoConfigScopedEnvFallback(t *testing.T) {
+	// Outside the systemd unit the key is not in the environment —
+	// it is resolved from the agent's scoped env file instead.
+	dir := t.TempDir()
+	old := config.ScopedEnvDir
+	config.ScopedEnvDir = dir
+	defer func() { config.ScopedEnvDir = old }()
+
+	os.WriteFile(filepath.Join(dir, "scoped"), []byte("SCOPED_KEY=sk-scoped\n"), 0600)
+	t.Setenv("SCOPED_KEY", "")
+
+	agent := config.AgentConfig{Name: "scoped", Provider: "anthropic", APIKeyEnv: "SCOPED_KEY"}
+	pcfg := BuildPicoConfig(agent)
+	if pcfg.Providers.Anthropic.APIKey != "sk-scoped" {
+		t.Errorf("expected key from scoped env file, got %q", pcfg.Providers.Anthropic.APIKey)
+	}
+
+	// Environment takes precedence over the file
+	t.Setenv("SCOPED_KEY", "sk-env")
+	pcfg = BuildPicoConfig(agent)
+	if pcfg.Providers.Anthropic.APIKey != "sk-env" {
+		t.Errorf("expected key from environment, got %q", pcfg.Providers.Anthropic.APIKey)
+	}
+}
@@ -0,0 +1,294 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// defaultToolTimeout applies to skill tools that declare no timeout.
+const defaultToolTimeout = 60 * time.Second
+
+// maxToolOutput limits the tool output returned to the model.
+const maxToolOutput = 64 << 10 // 64KB
+
+// ToolSpec is a scripted tool declared in a skill's frontmatter:
+//
+//	tool:
+//	  name: commission_agent
+//	  description: Create a worker agent
+//	  parameters:
+//	    type: object
+//	    properties:
+//	      name: {type: string}
+//	    required: [name]
+//	  command: sudo /usr/local/bin/con-commission "$TOOL_ARG_NAME"
+//	  timeout: 2m
+//	  tiers: [operator, officer]
+//
+// The command runs via sh -c in the agent's workspace, as the agent's own
+// user (the runner's uid). Calls whose arguments do not match parameters
+// are rejected before it runs. Arguments are passed as TOOL_ARG_<NAME>
+// environment variables and as a JSON object on stdin. The runner's own
+// environment, which carries the agent's secrets, is not inherited: only
+// toolEnvKeep passes through.
+type ToolSpec struct {
+	Name        string         `yaml:"name"`
+	Description string         `yaml:"description"`
+	Parameters  map[string]any `yaml:"parameters"` // JSON schema for the arguments
+	Command     string         `yaml:"command"`
+	Timeout     string         `yaml:"timeout"` // Go duration, default 60s
+	Tiers       []string       `yaml:"tiers"`   // tiers allowed to call the tool; empty = all
+}
+
+// toolName is the form a skill tool's name must take.
+var toolName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// builtinTools are the tools PicoClaw registers itself; a skill tool may not
+// replace them.
+var builtinTools = map[string]bool{
+	"read_file": true, "write_file": true, "list_dir": true, "edit_file": true,
+	"append_file": true, "exec": true, "web_search": true, "web_fetch": true,
+	"i2c": true, "spi": true, "message": true, "spawn": true, "subagent": true,
+	"cron": true,
+}
+
+// schemaTypes are the JSON schema types a tool parameter may declare.
+var schemaTypes = map[string]bool{
+	"string": true, "integer": true, "number": true, "boolean": true,
+	"array": true, "object": true, "null": true,
+}
+
+// Validate reports whether the spec is complete enough to register.
+func (s ToolSpec) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("tool: name is required")
+	}
+	if !toolName.MatchString(s.Name) {
+		return fmt.Errorf("tool %q: invalid name (use a-z, 0-9 and _)", s.Name)
+	}
+	if builtinTools[s.Name] {
+		return fmt.Errorf("tool %s: name is reserved for a built-in tool", s.Name)
+	}
+	if s.Command == "" {
+		return fmt.Errorf("tool %s: command is required", s.Name)
+	}
+	if s.Timeout != "" {
+		if _, err := time.ParseDuration(s.Timeout); err != nil {
+			return fmt.Errorf("tool %s: invalid timeout %q: %w", s.Name, s.Timeout, err)
+		}
+	}
+	if s.Parameters != nil {
+		if typ, ok := s.Parameters["type"]; ok && typ != "object" {
+			return fmt.Errorf("tool %s: parameters must be of type object", s.Name)
+		}
+		for name, prop := range properties(s.Parameters) {
+			typ, _ := prop["type"].(string)
+			if _, set := prop["type"]; set && !schemaTypes[typ] {
+				return fmt.Errorf("tool %s: parameter %s: unsupported type %v", s.Name, name, prop["type"])
+			}
+		}
+	}
+	return nil
+}
+
+// CheckArgs reports whether args match the spec's parameter schema: every
+// required argument is present, arguments have their declared type, and,
+// with additionalProperties: false, none is undeclared.
+func (s ToolSpec) CheckArgs(args map[string]interface{}) error {
+	props := properties(s.Parameters)
+	if req, ok := s.Parameters["required"].([]interface{}); ok {
+		for _, r := range req {
+			if name, _ := r.(string); name != "" {
+				if _, ok := args[name]; !ok {
+					return fmt.Errorf("missing required argument %q", name)
+				}
+			}
+		}
+	}
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prop, ok := props[name]
+		if !ok {
+			if s.Parameters["additionalProperties"] == false {
+				return fmt.Errorf("unexpected argument %q", name)
+			}
+			continue
+		}
+		if typ, _ := prop["type"].(string); typ != "" && !hasType(args[name], typ) {
+			return fmt.Errorf("argument %q must be of type %s", name, typ)
+		}
+	}
+	return nil
+}
+
+// properties returns the declared properties of a parameter schema.
+func properties(schema map[string]any) map[string]map[string]any {
+	props := map[string]map[string]any{}
+	declared, _ := schema["properties"].(map[string]any)
+	for name, p := range declared {
+		prop, _ := p.(map[string]any)
+		props[name] = prop
+	}
+	return props
+}
+
+// hasType reports whether a JSON-decoded value is of the JSON schema type typ.
+func hasType(v interface{}, typ string) bool {
+	switch typ {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "number":
+		switch v.(type) {
+		case float64, float32, int, int64, json.Number:
+			return true
+		}
+	case "integer":
+		switch n := v.(type) {
+		case int, int64:
+			return true
+		case float64:
+			return n == float64(int64(n))
+		case json.Number:
+			_, err := n.Int64()
+			return err == nil
+		}
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	case "null":
+		return v == nil
+	}
+	return false
+}
+
+// AllowsTier reports whether an agent of the given tier may call the tool.
+func (s ToolSpec) AllowsTier(tier string) bool {
+	if len(s.Tiers) == 0 {
+		return true
+	}
+	for _, t := range s.Tiers {
+		if t == tier {
+			return true
+		}
+	}
+	return false
+}
+
+// ScriptTool runs a ToolSpec's command. It implements the PicoClaw tools.Tool interface.
+type ScriptTool struct {
+	Spec ToolSpec
+	Dir  string // working directory (the agent's workspace)
+}
+
+func (t *ScriptTool) Name() string        { return t.Spec.Name }
+func (t *ScriptTool) Description() string { return t.Spec.Description }
+
+func (t *ScriptTool) Parameters() map[string]interface{} {
+	if t.Spec.Parameters != nil {
+		return t.Spec.Parameters
+	}
+	return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+}
+
+func (t *ScriptTool) Execute(ctx context.Context, args map[string]interface{}) *tools.ToolResult {
+	if err := t.Spec.CheckArgs(args); err != nil {
+		return tools.ErrorResult(fmt.Sprintf("%s: %v", t.Spec.Name, err))
+	}
+	timeout := defaultToolTimeout
+	if d, err := time.ParseDuration(t.Spec.Timeout); err == nil && d > 0 {
+		timeout = d
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	input, err := json.Marshal(args)
+	if err != nil {
+		return tools.ErrorResult(fmt.Sprintf("encoding arguments: %v", err))
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", t.Spec.Command)
+	cmd.Dir = t.Dir
+	cmd.Env = append(baseToolEnv(), toolArgEnv(args)...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	// Kill the whole process group on timeout, so children holding the
+	// output pipe open do not keep Run waiting
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err = cmd.Run()
+
+	output := out.String()
+	if len(output) > maxToolOutput {
+		output = output[:maxToolOutput] + "\n... (truncated)"
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return tools.ErrorResult(fmt.Sprintf("%s timed out after %s\n%s", t.Spec.Name, timeout, output))
+	}
+	if err != nil {
+		return tools.ErrorResult(fmt.Sprintf("%s failed: %v\n%s", t.Spec.Name, err, output))
+	}
+	return tools.NewToolResult(output)
+}
+
+// toolEnvKeep are the runner's environment variables a tool command sees.
+var toolEnvKeep = []string{"PATH", "HOME", "USER", "LOGNAME", "LANG", "LC_ALL", "TZ"}
+
+// baseToolEnv returns the toolEnvKeep variables that are set.
+func baseToolEnv() []string {
+	var env []string
+	for _, k := range toolEnvKeep {
+		if v, ok := os.LookupEnv(k); ok {
+			env = append(env, k+"="+v)
+		}
+	}
+	return env
+}
+
+// toolArgEnv converts tool arguments to TOOL_ARG_<NAME>=value pairs.
+// Strings are passed as-is; other values are JSON-encoded.
+func toolArgEnv(args map[string]interface{}) []string {
+	var env []string
+	for k, v := range args {
+		name := strings.ToUpper(strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+				return r
+			}
+			return '_'
+		}, k))
+		value, ok := v.(string)
+		if !ok {
+			b, _ := json.Marshal(v)
+			value = string(b)
+		}
+		env = append(env, "TOOL_ARG_"+name+"="+value)
+	}
+	sort.Strings(env)
+	return env
+}
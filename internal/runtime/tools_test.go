@@ -0,0 +1,127 @@
+package runtime
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestScriptToolExecute(t *testing.T) {
+	tool := &ScriptTool{
+		Spec: ToolSpec{
+			Name:    "greet",
+			Command: `echo "hello $TOOL_ARG_NAME ($TOOL_ARG_COUNT)"; cat`,
+		},
+		Dir: t.TempDir(),
+	}
+	result := tool.Execute(context.Background(), map[string]interface{}{"name": "ops", "count": 2})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "hello ops (2)") {
+		t.Errorf("args not passed as env: %q", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, `{"count":2,"name":"ops"}`) {
+		t.Errorf("args not passed as JSON on stdin: %q", result.ForLLM)
+	}
+}
+
+func TestScriptToolMinimalEnv(t *testing.T) {
+	t.Setenv("CON_SECRET_TEST", "hunter2")
+	t.Setenv("PATH", "/usr/bin:/bin")
+	tool := &ScriptTool{Spec: ToolSpec{Name: "env", Command: "env"}, Dir: t.TempDir()}
+	result := tool.Execute(context.Background(), map[string]interface{}{"name": "ops"})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", result.ForLLM)
+	}
+	if strings.Contains(result.ForLLM, "hunter2") {
+		t.Errorf("runner environment leaked into tool: %q", result.ForLLM)
+	}
+	for _, want := range []string{"PATH=/usr/bin:/bin", "TOOL_ARG_NAME=ops"} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("tool env missing %s: %q", want, result.ForLLM)
+		}
+	}
+}
+
+func TestScriptToolFailureAndTimeout(t *testing.T) {
+	dir := t.TempDir()
+
+	failing := &ScriptTool{Spec: ToolSpec{Name: "fail", Command: "echo oops; exit 3"}, Dir: dir}
+	result := failing.Execute(context.Background(), nil)
+	if !result.IsError || !strings.Contains(result.ForLLM, "oops") {
+		t.Errorf("expected error result with output, got %+v", result)
+	}
+
+	slow := &ScriptTool{Spec: ToolSpec{Name: "slow", Command: "sleep 5", Timeout: "100ms"}, Dir: dir}
+	result = slow.Execute(context.Background(), nil)
+	if !result.IsError || !strings.Contains(result.ForLLM, "timed out") {
+		t.Errorf("expected timeout error, got %+v", result)
+	}
+}
+
+func TestToolSpecValidate(t *testing.T) {
+	tests := []struct {
+		spec    ToolSpec
+		wantErr bool
+	}{
+		{ToolSpec{Name: "ok", Command: "true"}, false},
+		{ToolSpec{Command: "true"}, true},
+		{ToolSpec{Name: "nocmd"}, true},
+		{ToolSpec{Name: "bad", Command: "true", Timeout: "soon"}, true},
+		{ToolSpec{Name: "exec", Command: "true"}, true},
+		{ToolSpec{Name: "read_file", Command: "true"}, true},
+		{ToolSpec{Name: "Deploy-Now", Command: "true"}, true},
+		{ToolSpec{Name: "x", Command: "true", Parameters: map[string]any{"type": "array"}}, true},
+		{ToolSpec{Name: "x", Command: "true", Parameters: map[string]any{
+			"properties": map[string]any{"n": map[string]any{"type": "int"}}}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.spec.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+		}
+	}
+
+	spec := ToolSpec{Tiers: []string{"officer"}}
+	if spec.AllowsTier("worker") || !spec.AllowsTier("officer") {
+		t.Error("AllowsTier should restrict to listed tiers")
+	}
+	if !(ToolSpec{}).AllowsTier("worker") {
+		t.Error("no tiers should allow all")
+	}
+}
+
+func TestScriptToolChecksArgs(t *testing.T) {
+	spec := ToolSpec{
+		Name:    "commission",
+		Command: `echo "ran $TOOL_ARG_NAME"`,
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"name":  map[string]any{"type": "string"},
+				"count": map[string]any{"type": "integer"},
+			},
+			"required":             []any{"name"},
+			"additionalProperties": false,
+		},
+	}
+	if err := spec.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	tool := &ScriptTool{Spec: spec, Dir: t.TempDir()}
+
+	if result := tool.Execute(context.Background(), map[string]interface{}{"name": "ops", "count": float64(2)}); result.IsError {
+		t.Errorf("valid call rejected: %s", result.ForLLM)
+	}
+	for _, args := range []map[string]interface{}{
+		{"count": float64(2)},                  // missing required
+		{"name": float64(1)},                   // wrong type
+		{"name": "ops", "count": 1.5},          // not an integer
+		{"name": "ops", "path": "/etc/shadow"}, // undeclared
+	} {
+		result := tool.Execute(context.Background(), args)
+		if !result.IsError || strings.Contains(result.ForLLM, "ran") {
+			t.Errorf("call with %v should be rejected before running, got %q", args, result.ForLLM)
+		}
+	}
+}
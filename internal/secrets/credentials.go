@@ -0,0 +1,65 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Materialize writes each named secret present in the store to dir/<name>,
+// mode 0400 and owned by uid:gid, replacing any previous contents of dir.
+// Names not present in the store, or not valid names, are skipped. dir is expected to be on
+// tmpfs (/run) so decrypted values never touch persistent storage.
+// Missing parents are created 0755 so the agent can reach its own dir;
+// only dir itself is restricted to the agent.
+func (s *Store) Materialize(dir string, names []string, uid, gid int) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("clearing %s: %w", dir, err)
+	}
+	if err := mkdirParents(filepath.Dir(dir)); err != nil {
+		return err
+	}
+	// Writable by root while we populate it
+	if err := os.Mkdir(dir, 0700); err != nil {
+		return fmt.Errorf("creating %s: %w", dir, err)
+	}
+
+	for _, name := range names {
+		value, ok := s.values[name]
+		if !ok || !ValidName(name) { // never a path outside dir
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(value), 0400); err != nil {
+			return fmt.Errorf("writing credential %s: %w", name, err)
+		}
+		if err := os.Chown(path, uid, gid); err != nil {
+			return fmt.Errorf("chown credential %s: %w", name, err)
+		}
+	}
+
+	if err := os.Chown(dir, uid, gid); err != nil {
+		return fmt.Errorf("chown %s: %w", dir, err)
+	}
+	return os.Chmod(dir, 0500)
+}
+
+// mkdirParents creates dir and its missing parents with mode 0755,
+// regardless of umask. Existing dirs are left as they are.
+func mkdirParents(dir string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	if err := mkdirParents(filepath.Dir(dir)); err != nil {
+		return err
+	}
+	if err := os.Mkdir(dir, 0755); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("creating %s: %w", dir, err)
+	}
+	return os.Chmod(dir, 0755)
+}
+
+// Clear removes a materialized credentials directory.
+func Clear(dir string) error {
+	return os.RemoveAll(dir)
+}
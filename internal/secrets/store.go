@@ -0,0 +1,276 @@
+// Package secrets implements the encrypted secret store behind `con secret`.
+//
+// Secrets are kept in a single NaCl secretbox-encrypted file. The 32-byte key
+// lives in a separate root-owned file (mode 0400). At unit start, the secrets
+// an agent is granted are decrypted into a per-agent directory on tmpfs
+// (/run/con/credentials/<agent>), in the style of systemd LoadCredential=.
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// Default locations of the encrypted store and its key.
+var (
+	DefaultPath    = "/etc/con/secrets.enc"
+	DefaultKeyPath = "/etc/con/secrets.key"
+)
+
+// validName matches secret names. Names become file names in the
+// credentials dir and env var names, so nothing else is allowed.
+var validName = regexp.MustCompile(`^[A-Z0-9_]+$`)
+
+// ValidName reports whether name is a valid secret name ([A-Z0-9_]+).
+func ValidName(name string) bool {
+	return validName.MatchString(name)
+}
+
+const (
+	keySize   = 32
+	nonceSize = 24
+)
+
+// Store is an in-memory view of the encrypted secret file.
+// Changes are only persisted by Save.
+type Store struct {
+	path    string
+	keyPath string
+	key     [keySize]byte
+	hasKey  bool
+	values  map[string]string
+}
+
+// Open loads the store at path, decrypting it with the key at keyPath.
+// A missing store (and key) yields an empty Store; the key is generated on
+// the first Save. A rotation interrupted after the store was re-encrypted is
+// completed here from the pending key (see Rotate).
+func Open(path, keyPath string) (*Store, error) {
+	s := &Store{path: path, keyPath: keyPath, values: map[string]string{}}
+
+	key, err := readKey(keyPath)
+	switch {
+	case os.IsNotExist(err):
+		if _, statErr := os.Stat(path); statErr == nil {
+			return nil, fmt.Errorf("secret store %s exists but key %s is missing", path, keyPath)
+		}
+		return s, nil
+	case err != nil:
+		return nil, err
+	}
+	s.key = key
+	s.hasKey = true
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading store: %w", err)
+	}
+	if len(data) < nonceSize+secretbox.Overhead {
+		return nil, fmt.Errorf("store %s: truncated", path)
+	}
+
+	plain, ok := open(data, &s.key)
+	if !ok {
+		pending, err := readKey(pendingKeyPath(keyPath))
+		if err == nil {
+			plain, ok = open(data, &pending)
+		}
+		if !ok {
+			return nil, fmt.Errorf("store %s: decryption failed (wrong key or corrupted file)", path)
+		}
+		s.key = pending
+		if err := os.Rename(pendingKeyPath(keyPath), keyPath); err != nil {
+			return nil, fmt.Errorf("completing key rotation: %w", err)
+		}
+	}
+	if err := json.Unmarshal(plain, &s.values); err != nil {
+		return nil, fmt.Errorf("store %s: %w", path, err)
+	}
+	for name := range s.values {
+		if !ValidName(name) {
+			return nil, fmt.Errorf("store %s: invalid secret name %q", path, name)
+		}
+	}
+	return s, nil
+}
+
+// readKey reads a key file.
+func readKey(path string) ([keySize]byte, error) {
+	var key [keySize]byte
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return key, err
+	}
+	if err != nil {
+		return key, fmt.Errorf("reading key: %w", err)
+	}
+	if len(data) != keySize {
+		return key, fmt.Errorf("key %s: expected %d bytes, got %d", path, keySize, len(data))
+	}
+	copy(key[:], data)
+	return key, nil
+}
+
+// open decrypts a sealed store (nonce followed by the secretbox).
+func open(data []byte, key *[keySize]byte) ([]byte, bool) {
+	var nonce [nonceSize]byte
+	copy(nonce[:], data[:nonceSize])
+	return secretbox.Open(nil, data[nonceSize:], &nonce, key)
+}
+
+// pendingKeyPath is where Rotate keeps the new key until the store has
+// been re-encrypted with it.
+func pendingKeyPath(keyPath string) string {
+	return keyPath + ".new"
+}
+
+// Get returns the value of a secret and whether it exists.
+func (s *Store) Get(name string) (string, bool) {
+	v, ok := s.values[name]
+	return v, ok
+}
+
+// Set creates or replaces a secret.
+func (s *Store) Set(name, value string) error {
+	if name == "" {
+		return errors.New("secret name is required")
+	}
+	if !ValidName(name) {
+		return fmt.Errorf("invalid secret name %q (use A-Z, 0-9 and _)", name)
+	}
+	s.values[name] = value
+	return nil
+}
+
+// Remove deletes a secret. Returns false if it did not exist.
+func (s *Store) Remove(name string) bool {
+	if _, ok := s.values[name]; !ok {
+		return false
+	}
+	delete(s.values, name)
+	return true
+}
+
+// List returns the sorted secret names. Values are never listed.
+func (s *Store) List() []string {
+	names := make([]string, 0, len(s.values))
+	for n := range s.values {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Values returns a copy of all secret values, for redaction and export.
+func (s *Store) Values() map[string]string {
+	out := make(map[string]string, len(s.values))
+	for k, v := range s.values {
+		out[k] = v
+	}
+	return out
+}
+
+// Save encrypts the store with a fresh nonce and atomically replaces the file.
+func (s *Store) Save() error {
+	if !s.hasKey {
+		if err := s.newKey(); err != nil {
+			return err
+		}
+		if err := s.writeKey(); err != nil {
+			return err
+		}
+	}
+	plain, err := json.Marshal(s.values)
+	if err != nil {
+		return err
+	}
+	var nonce [nonceSize]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return fmt.Errorf("generating nonce: %w", err)
+	}
+	sealed := secretbox.Seal(nonce[:], plain, &nonce, &s.key)
+	return writeAtomic(s.path, sealed, 0600)
+}
+
+// Rotate generates a new key and re-encrypts the store with it. The new key
+// is made durable next to the old one before the store is re-encrypted and
+// only then renamed into place, so after a crash at any point one of the two
+// key files still opens the store (Open finishes the rename).
+func (s *Store) Rotate() error {
+	old := s.key
+	if err := s.newKey(); err != nil {
+		return err
+	}
+	pending := pendingKeyPath(s.keyPath)
+	if err := writeAtomic(pending, s.key[:], 0400); err != nil {
+		s.key = old
+		return fmt.Errorf("writing rotated key: %w", err)
+	}
+	if err := s.Save(); err != nil {
+		s.key = old
+		os.Remove(pending)
+		return err
+	}
+	if err := os.Rename(pending, s.keyPath); err != nil {
+		return fmt.Errorf("installing rotated key (pending in %s): %w", pending, err)
+	}
+	return nil
+}
+
+func (s *Store) newKey() error {
+	if _, err := io.ReadFull(rand.Reader, s.key[:]); err != nil {
+		return fmt.Errorf("generating key: %w", err)
+	}
+	s.hasKey = true
+	return nil
+}
+
+func (s *Store) writeKey() error {
+	return writeAtomic(s.keyPath, s.key[:], 0400)
+}
+
+// writeAtomic writes data to a temp file in the same directory, syncs it and
+// renames it over path, so readers never observe a partially written file.
+func writeAtomic(path string, data []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	// Make the rename itself durable
+	if d, err := os.Open(filepath.Dir(path)); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
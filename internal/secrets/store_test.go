@@ -0,0 +1,197 @@
+package secrets
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openTemp(t *testing.T) (*Store, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secrets.enc")
+	keyPath := filepath.Join(dir, "secrets.key")
+	s, err := Open(path, keyPath)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	return s, path, keyPath
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	s, path, keyPath := openTemp(t)
+
+	s.Set("CON_API_KEY", "sk-secret-value")
+	s.Set("GITHUB_TOKEN", "ghp_abc")
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Ciphertext must not contain the plaintext value
+	data, _ := os.ReadFile(path)
+	if bytes.Contains(data, []byte("sk-secret-value")) {
+		t.Error("store file contains plaintext secret")
+	}
+	if info, _ := os.Stat(keyPath); info.Mode().Perm() != 0400 {
+		t.Errorf("key mode = %o, want 400", info.Mode().Perm())
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("store mode = %o, want 600", info.Mode().Perm())
+	}
+
+	reopened, err := Open(path, keyPath)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if v, ok := reopened.Get("CON_API_KEY"); !ok || v != "sk-secret-value" {
+		t.Errorf("Get(CON_API_KEY) = %q, %v", v, ok)
+	}
+	if got := reopened.List(); len(got) != 2 || got[0] != "CON_API_KEY" || got[1] != "GITHUB_TOKEN" {
+		t.Errorf("List = %v, want sorted names", got)
+	}
+}
+
+func TestStoreRemove(t *testing.T) {
+	s, _, _ := openTemp(t)
+	s.Set("A", "1")
+	if !s.Remove("A") {
+		t.Error("Remove(A) should report true")
+	}
+	if s.Remove("A") {
+		t.Error("second Remove(A) should report false")
+	}
+}
+
+func TestStoreRotate(t *testing.T) {
+	s, path, keyPath := openTemp(t)
+	s.Set("A", "value")
+	s.Save()
+	oldKey, _ := os.ReadFile(keyPath)
+
+	if err := s.Rotate(); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	newKey, _ := os.ReadFile(keyPath)
+	if bytes.Equal(oldKey, newKey) {
+		t.Error("Rotate should replace the key")
+	}
+
+	reopened, err := Open(path, keyPath)
+	if err != nil {
+		t.Fatalf("reopen after rotate failed: %v", err)
+	}
+	if v, _ := reopened.Get("A"); v != "value" {
+		t.Errorf("Get(A) after rotate = %q, want value", v)
+	}
+}
+
+func TestStoreWrongKey(t *testing.T) {
+	s, path, keyPath := openTemp(t)
+	s.Set("A", "value")
+	s.Save()
+
+	os.Chmod(keyPath, 0600)
+	os.WriteFile(keyPath, bytes.Repeat([]byte{1}, keySize), 0600)
+	if _, err := Open(path, keyPath); err == nil {
+		t.Error("expected decryption error with wrong key")
+	}
+
+	os.Remove(keyPath)
+	if _, err := Open(path, keyPath); err == nil {
+		t.Error("expected error when store exists but key is missing")
+	}
+}
+
+func TestMaterialize(t *testing.T) {
+	s, _, _ := openTemp(t)
+	s.Set("CON_API_KEY", "sk-1")
+	s.Set("OFFICER_KEY", "sk-2")
+
+	dir := filepath.Join(t.TempDir(), "credentials", "researcher")
+	if err := s.Materialize(dir, []string{"CON_API_KEY", "NOT_IN_STORE"}, os.Getuid(), os.Getgid()); err != nil {
+		t.Fatalf("Materialize failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "CON_API_KEY"))
+	if err != nil || string(data) != "sk-1" {
+		t.Errorf("CON_API_KEY credential = %q (err %v)", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "OFFICER_KEY")); !os.IsNotExist(err) {
+		t.Error("ungranted secret must not be materialized")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "NOT_IN_STORE")); !os.IsNotExist(err) {
+		t.Error("names missing from the store should be skipped")
+	}
+
+	if err := Clear(dir); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("Clear should remove the credentials dir")
+	}
+}
+
+func TestMaterializeParents(t *testing.T) {
+	s, _, _ := openTemp(t)
+	s.Set("CON_API_KEY", "sk-1")
+
+	run := filepath.Join(t.TempDir(), "run")
+	dir := filepath.Join(run, "con", "credentials", "researcher")
+	if err := s.Materialize(dir, []string{"CON_API_KEY"}, os.Getuid(), os.Getgid()); err != nil {
+		t.Fatalf("Materialize failed: %v", err)
+	}
+	for _, p := range []string{filepath.Join(run, "con"), filepath.Join(run, "con", "credentials")} {
+		if info, err := os.Stat(p); err != nil || info.Mode().Perm() != 0755 {
+			t.Errorf("%s: mode %v (err %v), want 755 so the agent can reach its dir", p, info.Mode().Perm(), err)
+		}
+	}
+	if info, _ := os.Stat(dir); info.Mode().Perm() != 0500 {
+		t.Errorf("credentials dir mode = %o, want 500", info.Mode().Perm())
+	}
+}
+
+func TestSecretNames(t *testing.T) {
+	s, path, keyPath := openTemp(t)
+	for _, name := range []string{"../x", "lower", "A-B", "A/B"} {
+		if err := s.Set(name, "v"); err == nil {
+			t.Errorf("Set(%q) should be rejected", name)
+		}
+	}
+
+	// A store holding an invalid name (written by an older version) does not load
+	s.values["../x"] = "v"
+	s.Save()
+	if _, err := Open(path, keyPath); err == nil {
+		t.Error("expected an error loading an invalid secret name")
+	}
+}
+
+func TestStoreRotateInterrupted(t *testing.T) {
+	s, path, keyPath := openTemp(t)
+	s.Set("A", "value")
+	s.Save()
+
+	// Crash after the store was re-encrypted but before the key was renamed
+	old := s.key
+	s.newKey()
+	writeAtomic(pendingKeyPath(keyPath), s.key[:], 0400)
+	s.Save()
+	if s.key == old {
+		t.Fatal("expected a new key")
+	}
+
+	reopened, err := Open(path, keyPath)
+	if err != nil {
+		t.Fatalf("reopen after interrupted rotate: %v", err)
+	}
+	if v, _ := reopened.Get("A"); v != "value" {
+		t.Errorf("Get(A) = %q, want value", v)
+	}
+	if key, _ := os.ReadFile(keyPath); !bytes.Equal(key, s.key[:]) {
+		t.Error("Open should install the pending key")
+	}
+	if _, err := os.Stat(pendingKeyPath(keyPath)); !os.IsNotExist(err) {
+		t.Error("pending key should be gone")
+	}
+}
@@ -0,0 +1,218 @@
+// Package session records the in-flight run of each agent so that
+// kill_session can terminate exactly that run and fail its task.
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// AgentsDir is the root of the agents' directories.
+var AgentsDir = "/srv/con/agents"
+
+// Grace is how long Kill waits after SIGTERM before sending SIGKILL.
+var Grace = 5 * time.Second
+
+// agentUID resolves the uid of the agent's Linux user. Kill only signals
+// processes owned by it: the state file is agent-writable, so its PIDs are
+// not trusted on their own.
+var agentUID = func(agent string) (uint32, error) {
+	u, err := user.Lookup("a-" + agent)
+	if err != nil {
+		return 0, err
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	return uint32(uid), err
+}
+
+// State describes an agent's in-flight run. PID is the `con run` process;
+// ChildPGID is the process group of an exec runtime (claude, codex), which
+// runs in its own group, or 0 for in-process runtimes such as PicoClaw.
+type State struct {
+	Agent     string    `json:"agent"`
+	Task      string    `json:"task"`
+	PID       int       `json:"pid"`
+	ChildPGID int       `json:"child_pgid,omitempty"`
+	Started   time.Time `json:"started"`
+}
+
+// ErrNoSession is returned when the agent has no run in flight.
+var ErrNoSession = errors.New("no session in flight")
+
+// Path returns the run-state file of agent.
+func Path(agent string) string {
+	return filepath.Join(AgentsDir, agent, "run.json")
+}
+
+// Write records s as the agent's in-flight run.
+func Write(s State) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := Path(s.Agent) + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, Path(s.Agent))
+}
+
+// Read returns the agent's in-flight run.
+func Read(agent string) (State, error) {
+	var s State
+	data, err := os.ReadFile(Path(agent))
+	if os.IsNotExist(err) {
+		return s, fmt.Errorf("agent %s: %w", agent, ErrNoSession)
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("parsing run state of %s: %w", agent, err)
+	}
+	return s, nil
+}
+
+// Clear removes the agent's run state at the end of a run.
+func Clear(agent string) {
+	os.Remove(Path(agent))
+}
+
+// Kill terminates the agent's in-flight session: SIGTERM to the runtime's
+// process group and the run, SIGKILL to whatever is left after Grace. The
+// task is then moved to the agent's failed/ dir with a .reason file beside
+// it, and the run state is cleared. Returns a description of each step.
+func Kill(agent, reason string) ([]string, error) {
+	s, err := Read(agent)
+	if err != nil {
+		return nil, err
+	}
+	uid, err := agentUID(agent)
+	if err != nil {
+		return nil, fmt.Errorf("resolving user of %s: %w", agent, err)
+	}
+
+	var steps []string
+	var targets []int // negative: process group
+	if s.ChildPGID > 0 && ownedBy(s.ChildPGID, uid) {
+		targets = append(targets, -s.ChildPGID)
+	}
+	if s.PID > 0 && ownedBy(s.PID, uid) {
+		targets = append(targets, s.PID)
+	}
+	if len(targets) > 0 {
+		for _, t := range targets {
+			syscall.Kill(t, syscall.SIGTERM)
+			steps = append(steps, fmt.Sprintf("SIGTERM %d", t))
+		}
+		if !waitGone(targets, Grace) {
+			for _, t := range targets {
+				if syscall.Kill(t, syscall.SIGKILL) == nil {
+					steps = append(steps, fmt.Sprintf("SIGKILL %d", t))
+				}
+			}
+		}
+	}
+
+	if s.Task != "" {
+		failed, err := FailTask(agent, s.Task, "killed: "+reason)
+		if err != nil {
+			return steps, fmt.Errorf("failing task: %w", err)
+		}
+		if failed != "" {
+			steps = append(steps, "moved task to "+failed)
+		}
+	}
+	Clear(agent)
+	return steps, nil
+}
+
+// ownedBy reports whether pid is alive and owned by uid.
+func ownedBy(pid int, uid uint32) bool {
+	info, err := os.Stat(filepath.Join("/proc", strconv.Itoa(pid)))
+	if err != nil {
+		return false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && st.Uid == uid
+}
+
+// waitGone polls until no target exists or the grace period ends.
+func waitGone(targets []int, grace time.Duration) bool {
+	deadline := time.Now().Add(grace)
+	for {
+		alive := false
+		for _, t := range targets {
+			if syscall.Kill(t, 0) == nil {
+				alive = true
+			}
+		}
+		if !alive {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// FailTask moves the task into the agent's failed/ dir with a reason file,
+// so it is not picked again. A task that already left the inbox (processed
+// or moved) is left alone. Returns the new path, or "" if there was none.
+func FailTask(agent, taskPath, reason string) (string, error) {
+	agentDir := filepath.Join(AgentsDir, agent)
+	// The path comes from the agent-writable state file: only accept tasks in its inbox
+	if filepath.Dir(taskPath) != filepath.Join(agentDir, "inbox") {
+		return "", fmt.Errorf("task %s is not in %s's inbox", taskPath, agent)
+	}
+	failedDir := filepath.Join(agentDir, "failed")
+	if err := mkdirLike(failedDir, agentDir); err != nil {
+		return "", err
+	}
+	dest := filepath.Join(failedDir, filepath.Base(taskPath))
+	if err := os.Rename(taskPath, dest); err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	note := fmt.Sprintf("%s %s\n", time.Now().Format(time.RFC3339), reason)
+	if err := os.WriteFile(dest+".reason", []byte(note), 0644); err != nil {
+		return dest, err
+	}
+	return dest, nil
+}
+
+// mkdirLike creates dir (mode 0700) owned like ref, the agent's base dir,
+// which bootstrap provisions as a-<agent>:agents. Kill runs as root or
+// sysadmin, so a plain mkdir would leave the agent a dir it cannot manage.
+// An existing dir is left as it is; one that cannot be chowned is removed.
+func mkdirLike(dir, ref string) error {
+	info, err := os.Stat(ref)
+	if err != nil {
+		return err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("%s: no ownership information", ref)
+	}
+	if err := os.Mkdir(dir, 0700); err != nil {
+		if os.IsExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := os.Lchown(dir, int(st.Uid), int(st.Gid)); err != nil {
+		os.Remove(dir)
+		return err
+	}
+	return nil
+}
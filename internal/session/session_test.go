@@ -0,0 +1,152 @@
+package session
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func setup(t *testing.T) string {
+	t.Helper()
+	oldDir, oldGrace, oldUID := AgentsDir, Grace, agentUID
+	AgentsDir = t.TempDir()
+	Grace = 200 * time.Millisecond
+	agentUID = func(string) (uint32, error) { return uint32(os.Getuid()), nil }
+	t.Cleanup(func() { AgentsDir, Grace, agentUID = oldDir, oldGrace, oldUID })
+	inbox := filepath.Join(AgentsDir, "researcher", "inbox")
+	if err := os.MkdirAll(inbox, 0700); err != nil {
+		t.Fatal(err)
+	}
+	return inbox
+}
+
+// start runs a command in its own process group and reaps it in the
+// background, so Kill sees it disappear once signalled.
+func start(t *testing.T, script string) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command("sh", "-c", script)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() { cmd.Wait(); close(done) }()
+	t.Cleanup(func() {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+	})
+	return cmd
+}
+
+func TestKill_TerminatesSessionAndFailsTask(t *testing.T) {
+	inbox := setup(t)
+	task := filepath.Join(inbox, "001.task")
+	os.WriteFile(task, []byte("long job"), 0600)
+
+	run := start(t, "sleep 30")
+	child := start(t, "sleep 30")
+	if err := Write(State{Agent: "researcher", Task: task, PID: run.Process.Pid, ChildPGID: child.Process.Pid, Started: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	steps, err := Kill("researcher", "CON-SYS-004: session too long")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(strings.Join(steps, "\n"), "SIGTERM") {
+		t.Errorf("steps = %v, want SIGTERM", steps)
+	}
+	time.Sleep(50 * time.Millisecond)
+	for _, pid := range []int{run.Process.Pid, child.Process.Pid} {
+		if syscall.Kill(pid, 0) == nil {
+			t.Errorf("pid %d still alive after Kill", pid)
+		}
+	}
+
+	failed := filepath.Join(AgentsDir, "researcher", "failed", "001.task")
+	if _, err := os.Stat(failed); err != nil {
+		t.Errorf("task not moved to failed/: %v", err)
+	}
+	if reason, _ := os.ReadFile(failed + ".reason"); !strings.Contains(string(reason), "session too long") {
+		t.Errorf("reason = %q", reason)
+	}
+	if _, err := Read("researcher"); err == nil {
+		t.Error("run state should be cleared after Kill")
+	}
+}
+
+func TestKill_FailedDirOwnedByAgent(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("needs root to chown")
+	}
+	inbox := setup(t)
+	agentDir := filepath.Dir(inbox)
+	if err := os.Chown(agentDir, 4242, 4343); err != nil {
+		t.Fatal(err)
+	}
+	task := filepath.Join(inbox, "001.task")
+	os.WriteFile(task, []byte("job"), 0600)
+	if err := Write(State{Agent: "researcher", Task: task}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Kill("researcher", "test"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(agentDir, "failed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := info.Sys().(*syscall.Stat_t); st.Uid != 4242 || st.Gid != 4343 {
+		t.Errorf("failed/ owned by %d:%d, want the agent's 4242:4343", st.Uid, st.Gid)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Errorf("failed/ mode = %o, want 0700", info.Mode().Perm())
+	}
+}
+
+func TestKill_EscalatesToSIGKILL(t *testing.T) {
+	setup(t)
+	run := start(t, "trap '' TERM; while :; do sleep 0.05; done")
+	time.Sleep(100 * time.Millisecond) // let the trap install
+	Write(State{Agent: "researcher", PID: run.Process.Pid, ChildPGID: run.Process.Pid})
+
+	steps, err := Kill("researcher", "stuck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(strings.Join(steps, "\n"), "SIGKILL") {
+		t.Errorf("steps = %v, want SIGKILL after the grace period", steps)
+	}
+}
+
+func TestKill_RejectsForeignProcessesAndPaths(t *testing.T) {
+	setup(t)
+	agentUID = func(string) (uint32, error) { return 4242, nil }
+	run := start(t, "sleep 30")
+	outside := filepath.Join(t.TempDir(), "victim.task")
+	os.WriteFile(outside, []byte("x"), 0600)
+	Write(State{Agent: "researcher", Task: outside, PID: run.Process.Pid})
+
+	steps, err := Kill("researcher", "r")
+	if len(steps) != 0 {
+		t.Errorf("steps = %v: must not signal processes the agent does not own", steps)
+	}
+	if err == nil {
+		t.Error("expected an error for a task outside the agent's inbox")
+	}
+	if _, serr := os.Stat(outside); serr != nil {
+		t.Error("task outside the inbox must not be moved")
+	}
+}
+
+func TestKill_NoSession(t *testing.T) {
+	setup(t)
+	if _, err := Kill("researcher", "r"); err == nil {
+		t.Error("expected ErrNoSession")
+	}
+}
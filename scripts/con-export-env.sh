@@ -4,9 +4,16 @@
 # Runs on every boot before other ConspiracyOS services.
 #
 # Mode 600 root:root — only root (systemd PID 1) can read.
-# Agents receive env vars via systemd EnvironmentFile= injection,
+# Agents receive env vars via systemd EnvironmentFile= injection of their
+# scoped file in /etc/con/env.d/ (only the secrets granted in [secrets]),
 # never by reading the file directly. This prevents any agent from
 # reading secrets belonging to other agents.
 tr '\0' '\n' < /proc/1/environ | grep -E '^(CON_|TS_)' > /etc/con/env 2>/dev/null
 chmod 600 /etc/con/env
 chown root:root /etc/con/env
+
+# Re-split into per-agent scoped env files (/etc/con/env.d/<agent>).
+# Skipped on first boot — bootstrap writes them once the config is in place.
+if [ -f /etc/con/con.toml ] && [ -d /etc/con/env.d ]; then
+    /usr/local/bin/con scope-env
+fi
@@ -20,6 +20,18 @@ if ls "$SKILLS_DIR"/*.md >/dev/null 2>&1; then
     check "skills contain step markers" grep -rlq -E "(Step [0-9]|## )" "$SKILLS_DIR"/*.md
 fi
 
+echo ""
+echo "--- 9b2. Verify skills are protected from the agent ---"
+if ls "$SKILLS_DIR"/*.md >/dev/null 2>&1; then
+    FIRST_SKILL=$(ls "$SKILLS_DIR"/*.md | head -1)
+    check "skills are root-owned" [ "$(stat -c %U "$FIRST_SKILL")" = "root" ]
+    check "skills are read-only" [ "$(stat -c %a "$FIRST_SKILL")" = "444" ]
+    check_fail "sysadmin cannot rewrite a skill" su -s /bin/sh a-sysadmin -c "echo injected >> '$FIRST_SKILL'"
+    check "skill manifest exists outside agent dir" test -f /srv/con/skills/sysadmin.sha256
+    check "skill manifest matches" sh -c "cd '$SKILLS_DIR' && sha256sum -c --quiet /srv/con/skills/sysadmin.sha256"
+fi
+check "sysadmin has a writable notes dir" test -d /srv/con/agents/sysadmin/workspace/notes
+
 echo ""
 echo "--- 9c. Verify concierge AGENTS.md has base content ---"
 check "AGENTS.md references ConspiracyOS" grep -q "ConspiracyOS" /home/a-concierge/AGENTS.md
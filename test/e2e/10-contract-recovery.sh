@@ -26,7 +26,12 @@ checks:
     command:
       run: "test -w /srv/con/logs/audit/NONEXISTENT_DIR_FOR_TEST"
       test: exit_code_zero
-    on_fail: escalate
+    on_fail:
+      action: alert
+      escalate: sysadmin
+      message: "CON-SYS-005 FAILED: audit log not writable (E2E TEST)"
+    on_recover:
+      action: notify
 YAML
 
 echo ""
@@ -61,4 +66,8 @@ echo "--- 10e. Verify contract passes after restoration ---"
 con healthcheck 2>/dev/null
 check "contract passes after restore" con healthcheck 2>/dev/null
 
+echo ""
+echo "--- 10f. Verify recovery was detected without manual intervention ---"
+check "recovery transition logged" grep -q "CON-SYS-005 PASS audit_log_writable.*\[recovered\]" /srv/con/logs/audit/contracts.log
+
 finish